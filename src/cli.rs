@@ -1,7 +1,32 @@
-use clap::{Parser, ValueEnum};
+use crate::content::ext_limit::ExtLineLimit;
+use crate::content::range::ContentRange;
+use crate::content::replace::ContentReplace;
+use crate::matcher::spec::{parse_content_glob, ExtAlias};
+use crate::util::duration::parse_duration;
+use clap::builder::{BoolishValueParser, TypedValueParser};
+use clap::parser::ValueSource;
+use clap::{CommandFactory, Parser, ValueEnum};
+use globset::Glob;
+use regex::Regex;
+use std::ffi::OsString;
+use std::path::PathBuf;
+use std::time::Duration;
 
 pub const VERSION: &str = "0.9.2";
 
+/// Environment variables that set option defaults: (variable, argument id,
+/// long flag)
+const ENV_DEFAULTS: &[(&str, &str, &str)] = &[
+    ("TREE2MD_LEVEL", "level", "--level"),
+    ("TREE2MD_CONTENTS", "contents", "--contents"),
+    ("TREE2MD_MAX_CHARS", "max_chars", "--max-chars"),
+    ("TREE2MD_CONTENTS_MODE", "contents_mode", "--contents-mode"),
+    ("TREE2MD_USE_GITIGNORE", "use_gitignore", "--use-gitignore"),
+    ("TREE2MD_STATS", "stats", "--stats"),
+    ("TREE2MD_FUN", "fun", "--fun"),
+    ("TREE2MD_INCLUDE_EXT", "include_ext", "--include-ext"),
+];
+
 #[derive(Debug, Clone, ValueEnum)]
 pub enum UseGitignoreMode {
     /// Use .gitignore if in a git repository
@@ -50,6 +75,56 @@ pub enum ContentsMode {
     Nest,
 }
 
+#[derive(Debug, Clone, PartialEq, ValueEnum)]
+pub enum HeadingStyle {
+    /// Full path: "## src/main.rs"
+    Path,
+    /// File name only: "## main.rs"
+    Name,
+    /// File name with the path in a code span: "## main.rs `src/main.rs`"
+    NameWithPath,
+}
+
+#[derive(Debug, Clone, PartialEq, ValueEnum)]
+pub enum SortMode {
+    /// Alphabetically by name
+    Name,
+    /// By file extension, then name (directories stay first)
+    #[value(alias = "extension")]
+    Ext,
+    /// Directories by aggregate size, largest first; files by name
+    Dirsize,
+    /// Directories by recursive file count, most first; files by name
+    Filecount,
+    /// By name, comparing embedded numbers numerically (img2 before img10)
+    Natural,
+}
+
+#[derive(Debug, Clone, Copy, PartialEq, ValueEnum)]
+pub enum OutputEncoding {
+    /// UTF-8 (default)
+    #[value(name = "utf-8", alias = "utf8")]
+    Utf8,
+    /// UTF-16 little-endian with a byte order mark
+    #[value(name = "utf-16le")]
+    Utf16Le,
+    /// UTF-16 big-endian with a byte order mark
+    #[value(name = "utf-16be")]
+    Utf16Be,
+}
+
+#[derive(Debug, Clone, PartialEq, ValueEnum)]
+pub enum FormatMode {
+    /// Auto-detect: pretty tree on a TTY, plain tree when piped
+    Auto,
+    /// Flattened TOML manifest of [[file]] tables
+    Toml,
+    /// Nested JSON tree (includes contents and truncation metadata with -c)
+    Json,
+    /// Nested XML <directory>/<file> elements (contents as CDATA with -c)
+    Xml,
+}
+
 #[derive(Parser, Clone)]
 #[command(name = "tree2md")]
 #[command(version = VERSION)]
@@ -71,6 +146,7 @@ OUTPUT MODES (auto-detected):
 
 FILTERING:
   -L N                 Limit depth to N levels
+  -R, --no-recurse     List only direct children of the root
   -I "*.rs"            Include only matching files
   -X "*.log"           Exclude matching files
   --use-gitignore      Respect .gitignore (auto|never|always)
@@ -78,13 +154,45 @@ FILTERING:
 SAFETY:
   Safe by default: excludes .env, private keys, node_modules, etc.
   Use --unsafe to disable safety filters (not recommended)
-  Use -I patterns to selectively include filtered items"#
+  Use -I patterns to selectively include filtered items
+  Scanning $HOME or / requires --force
+
+ENVIRONMENT:
+  TREE2MD_LEVEL, TREE2MD_CONTENTS, TREE2MD_MAX_CHARS, TREE2MD_CONTENTS_MODE,
+  TREE2MD_USE_GITIGNORE, TREE2MD_STATS, TREE2MD_FUN, TREE2MD_INCLUDE_EXT
+  set defaults; explicit flags take precedence"#
 )]
 pub struct Args {
-    /// Target directory to scan
+    /// Target directory (or single file) to scan
     #[arg(default_value = ".", value_name = "TARGET")]
     pub target: String,
 
+    /// Render a tree read from FILE (written by --format json, "-" for stdin)
+    /// instead of scanning TARGET; contents embedded in the JSON are used
+    /// as-is. Walk filters and options that read the files themselves
+    /// cannot be combined.
+    #[arg(
+        long = "from-json",
+        value_name = "FILE",
+        conflicts_with_all = [
+            "level",
+            "no_recurse",
+            "include",
+            "include_ext",
+            "exclude",
+            "exclude_path",
+            "filter_file",
+            "ignore",
+            "changed_in",
+            "show_mtime",
+            "git_status",
+            "duplicates_report",
+            "git_blame",
+            "sniff_content",
+        ]
+    )]
+    pub from_json: Option<PathBuf>,
+
     // ==================== Filtering Options ====================
     /// Limit traversal depth (e.g., -L 3 for max 3 levels deep)
     #[arg(
@@ -95,6 +203,33 @@ pub struct Args {
     )]
     pub level: Option<usize>,
 
+    /// List only the root's direct children (same as -L 1)
+    #[arg(
+        short = 'R',
+        long = "no-recurse",
+        conflicts_with = "level",
+        help_heading = "Filtering"
+    )]
+    pub no_recurse: bool,
+
+    /// Show directories N levels down as "dir/ […]" with a count of the
+    /// entries hidden beneath them, instead of expanding them
+    #[arg(
+        long = "collapse-below",
+        value_name = "N",
+        value_parser = clap::builder::RangedU64ValueParser::<usize>::new().range(1..),
+        help_heading = "Filtering"
+    )]
+    pub collapse_below: Option<usize>,
+
+    /// Walk VCS metadata directories (.git, .svn, .hg, .bzr), which are skipped by default
+    #[arg(long = "include-vcs", help_heading = "Filtering")]
+    pub include_vcs: bool,
+
+    /// Keep directories left empty after filtering (e.g. by -I)
+    #[arg(long = "keep-empty-dirs", help_heading = "Filtering")]
+    pub keep_empty_dirs: bool,
+
     /// Include patterns (e.g., -I "*.rs" -I "src/**")
     #[arg(
         short = 'I',
@@ -104,6 +239,25 @@ pub struct Args {
     )]
     pub include: Vec<String>,
 
+    /// Include files by extension (e.g., --include-ext ts,go); expands aliases like ts -> ts,tsx.
+    /// `*` allows every extension and `!ext` excludes one (e.g., '*,!md')
+    #[arg(
+        long = "include-ext",
+        value_name = "EXT",
+        value_delimiter = ',',
+        help_heading = "Filtering"
+    )]
+    pub include_ext: Vec<String>,
+
+    /// Define an extension family for --include-ext (e.g., --ext-alias "web=html,css,js")
+    #[arg(
+        long = "ext-alias",
+        value_name = "NAME=EXTS",
+        value_parser = ExtAlias::parse,
+        help_heading = "Filtering"
+    )]
+    pub ext_alias: Vec<ExtAlias>,
+
     /// Exclude patterns (e.g., -X "*.log" -X "temp/**")
     #[arg(
         short = 'X',
@@ -113,6 +267,31 @@ pub struct Args {
     )]
     pub exclude: Vec<String>,
 
+    /// Exclude one exact path relative to the root (e.g., --exclude-path
+    /// src/generated/big.go); unlike -X it never matches anything else
+    #[arg(long = "exclude-path", value_name = "PATH", help_heading = "Filtering")]
+    pub exclude_path: Vec<String>,
+
+    /// Read ordered "+ GLOB" (include) and "- GLOB" (exclude) lines from FILE;
+    /// the last rule matching a path decides, like rsync filter rules
+    #[arg(long = "filter-file", value_name = "FILE", help_heading = "Filtering")]
+    pub filter_file: Option<PathBuf>,
+
+    /// List symlinked files and read their contents from the link target
+    /// (symlinked directories are still skipped)
+    #[arg(long = "follow-symlinks", help_heading = "Filtering")]
+    pub follow_symlinks: bool,
+
+    /// Gitignore-style patterns applied after the ignore files, in order
+    /// (e.g., --ignore 'reports/*' --ignore '!reports/keep.md'); comma-separated lists work too
+    #[arg(
+        long = "ignore",
+        value_name = "PATTERN",
+        value_delimiter = ',',
+        help_heading = "Filtering"
+    )]
+    pub ignore: Vec<String>,
+
     /// Respect .gitignore (default: auto)
     #[arg(
         long = "use-gitignore",
@@ -123,6 +302,138 @@ pub struct Args {
     )]
     pub use_gitignore: UseGitignoreMode,
 
+    /// Only show files changed in a git revision range (e.g. main..HEAD)
+    #[arg(long = "changed-in", value_name = "RANGE", help_heading = "Filtering")]
+    pub changed_in: Option<String>,
+
+    /// Respect .npmignore files like `npm publish` (.gitignore is used where no .npmignore exists)
+    #[arg(long = "respect-npmignore", help_heading = "Filtering")]
+    pub respect_npmignore: bool,
+
+    /// Log to stderr which .gitignore pattern excluded (or re-included) each path
+    #[arg(long = "gitignore-debug", help_heading = "Filtering")]
+    pub gitignore_debug: bool,
+
+    // ==================== Display ====================
+    /// Output format: auto|toml|json|xml (default: auto)
+    #[arg(
+        long = "format",
+        value_enum,
+        default_value = "auto",
+        help_heading = "Display"
+    )]
+    pub format: FormatMode,
+
+    /// Omit null and empty fields from --format json nodes (e.g. `children` on files)
+    #[arg(long = "compact-json", help_heading = "Display")]
+    pub compact_json: bool,
+
+    /// Render .md files as Obsidian wikilinks, e.g. `[[notes]]` for notes.md
+    #[arg(long = "wikilinks", help_heading = "Display")]
+    pub wikilinks: bool,
+
+    /// Order of entries within each directory (directories always come first)
+    #[arg(
+        long = "sort",
+        value_enum,
+        default_value = "name",
+        help_heading = "Display"
+    )]
+    pub sort: SortMode,
+
+    /// Compare names case-insensitively when sorting
+    #[arg(long = "sort-ignorecase", help_heading = "Display")]
+    pub sort_ignorecase: bool,
+
+    /// Reverse the sort order among directories and among files
+    /// (directories still come first)
+    #[arg(long = "sort-reverse", help_heading = "Display")]
+    pub sort_reverse: bool,
+
+    /// Line emitted before the whole output, e.g. "<!-- BEGIN TREE2MD -->"
+    #[arg(long = "content-prefix", value_name = "TEXT", help_heading = "Display")]
+    pub content_prefix: Option<String>,
+
+    /// Line emitted after the whole output, e.g. "<!-- END TREE2MD -->"
+    #[arg(long = "content-suffix", value_name = "TEXT", help_heading = "Display")]
+    pub content_suffix: Option<String>,
+
+    /// String prepended to every output line, e.g. "> " to embed in a blockquote
+    #[arg(long = "prefix", value_name = "STR", help_heading = "Display")]
+    pub prefix: Option<String>,
+
+    /// Character encoding of the output
+    #[arg(
+        long = "output-encoding",
+        value_enum,
+        default_value = "utf-8",
+        conflicts_with = "update",
+        help_heading = "Display"
+    )]
+    pub output_encoding: OutputEncoding,
+
+    /// Shorten names longer than N characters in the tree with "…" (headings keep full paths)
+    #[arg(long = "max-name-length", value_name = "N", help_heading = "Display")]
+    pub max_name_length: Option<usize>,
+
+    /// Annotate each file with its size, e.g. `main.go  (12 lines, 1.2 KB)`
+    #[arg(long = "show-size", help_heading = "Display")]
+    pub show_size: bool,
+
+    /// Annotate each file with its modification date (UTC), e.g. `main.go  (2024-01-02)`
+    #[arg(long = "show-mtime", help_heading = "Display")]
+    pub show_mtime: bool,
+
+    /// Annotate each file with its detected language, e.g. `main.go  (12 lines, go)`
+    #[arg(long = "show-lang", help_heading = "Display")]
+    pub show_lang: bool,
+
+    /// Print every recognized file extension and its language, then exit
+    #[arg(long = "list-languages", help_heading = "Display")]
+    pub list_languages: bool,
+
+    /// Label each directory with the language of most files below it,
+    /// e.g. `services/ [mostly go]`
+    #[arg(long = "dir-language", help_heading = "Display")]
+    pub dir_language: bool,
+
+    /// Show a line beneath each directory with its file count, total size
+    /// and most common language, e.g. `(3 files, 4.2 KB, mostly go)`
+    #[arg(long = "dir-summary", help_heading = "Display")]
+    pub dir_summary: bool,
+
+    /// Label the root with its full path instead of "."
+    #[arg(long = "root-full-path", help_heading = "Display")]
+    pub root_full_path: bool,
+
+    /// Mark entries with their `git status` code (M modified, A added, ? untracked, ...)
+    #[arg(long = "git-status", help_heading = "Display")]
+    pub git_status: bool,
+
+    /// Replace the BEGIN/END TREE2MD block in FILE with the output instead of
+    /// printing it (appends a block if none exists; markers follow --content-prefix/--content-suffix)
+    #[arg(long = "update", value_name = "FILE", help_heading = "Display")]
+    pub update: Option<PathBuf>,
+
+    /// Page the output through $PAGER (default `less`) when stdout is a
+    /// terminal; redirected output is printed as usual
+    #[arg(long = "pager", help_heading = "Display")]
+    pub pager: bool,
+
+    /// Write one Markdown page per file into DIR, mirroring the source tree,
+    /// plus DIR/index.md linking to them, instead of printing
+    #[arg(
+        long = "export-dir",
+        value_name = "DIR",
+        conflicts_with = "update",
+        help_heading = "Display"
+    )]
+    pub export_dir: Option<PathBuf>,
+
+    /// Prefix each tree line with its depth, e.g. "[2] main.rs"
+    #[arg(long = "depth-markers", help_heading = "Display")]
+    pub depth_markers: bool,
+
     // ==================== Fun & Emojis ====================
     /// Custom emoji mappings (e.g., --emoji ".rs=🚀" --emoji "test=🧪")
     #[arg(long = "emoji", value_name = "MAPPING", help_heading = "Fun & Style")]
@@ -164,9 +475,42 @@ pub struct Args {
     )]
     pub loc: LocMode,
 
+    /// Also write a JSON summary (counts, sizes, extensions, max depth) to FILE
+    #[arg(
+        long = "summary-json",
+        value_name = "FILE",
+        help_heading = "Statistics"
+    )]
+    pub summary_json: Option<PathBuf>,
+
+    /// Append a table of file counts and bytes per detected language
+    #[arg(long = "lang-stats", help_heading = "Statistics")]
+    pub lang_stats: bool,
+
+    /// Append a list of groups of identical files, most wasted space first
+    #[arg(long = "duplicates-report", help_heading = "Statistics")]
+    pub duplicates_report: bool,
+
+    /// Append a list of text files that are not valid UTF-8, with the offset
+    /// of the first bad byte; with -c they are shown with U+FFFD replacements
+    #[arg(long = "validate-utf8", help_heading = "Statistics")]
+    pub validate_utf8: bool,
+
+    /// Print only the stats, without the tree or file contents
+    #[arg(long = "summary-only", help_heading = "Statistics")]
+    pub summary_only: bool,
+
+    /// After rendering, print a one-line count and size summary to stderr
+    #[arg(long = "stderr-summary", help_heading = "Statistics")]
+    pub stderr_summary: bool,
+
     // ==================== Contents ====================
     /// Include file contents as code blocks (for AI context)
-    #[arg(short = 'c', long = "contents")]
+    #[arg(
+        short = 'c',
+        long = "contents",
+        value_parser = clap::builder::BoolishValueParser::new()
+    )]
     pub contents: bool,
 
     /// Limit total content to N characters — controls AI context budget (only with -c)
@@ -178,6 +522,80 @@ pub struct Args {
     )]
     pub max_chars: Option<usize>,
 
+    /// Stop emitting file contents once their estimated token count would exceed N (only with -c)
+    #[arg(
+        long = "max-tokens",
+        value_name = "N",
+        requires = "contents",
+        help_heading = "Contents"
+    )]
+    pub max_tokens: Option<usize>,
+
+    /// Show only the first --preview-lines lines of files larger than BYTES (only with -c)
+    #[arg(
+        long = "preview-larger-than",
+        value_name = "BYTES",
+        requires = "contents",
+        help_heading = "Contents"
+    )]
+    pub preview_larger_than: Option<u64>,
+
+    /// Lines shown for files over --preview-larger-than
+    #[arg(
+        long = "preview-lines",
+        value_name = "N",
+        default_value = "20",
+        requires = "preview_larger_than",
+        help_heading = "Contents"
+    )]
+    pub preview_lines: usize,
+
+    /// Per-extension line limits, e.g. ".json=20,.lock=5"; listed
+    /// extensions are cut to N lines whatever their size, others follow
+    /// --preview-larger-than
+    #[arg(
+        long = "truncate-ext",
+        value_name = "EXT=N",
+        value_delimiter = ',',
+        value_parser = ExtLineLimit::parse,
+        requires = "contents",
+        help_heading = "Contents"
+    )]
+    pub truncate_ext: Vec<ExtLineLimit>,
+
+    /// Move line truncation (--max-chars head mode, previews, --truncate-ext)
+    /// in recognized languages to the nearest clean boundary, a closing
+    /// brace at column 0 or a blank line, within 10 lines of the cut
+    /// (only earlier under --max-chars, so the budget holds)
+    #[arg(
+        long = "truncate-at-boundary",
+        requires = "contents",
+        help_heading = "Contents"
+    )]
+    pub truncate_at_boundary: bool,
+
+    /// Draw a progress bar on stderr while file contents are read, e.g.
+    /// "[#####---------------] 42/160 files"; stdout is unaffected
+    #[arg(
+        long = "progress-bar",
+        requires = "contents",
+        help_heading = "Contents"
+    )]
+    pub progress_bar: bool,
+
+    /// Give up reading a file after DURATION (e.g. 2s) and note
+    /// "[Read timed out]" in its place (or the --content-placeholder /
+    /// --show-omitted-content note), so one hung read cannot stall -c.
+    /// Line counts and --sniff-content give up on the file the same way
+    #[arg(
+        long = "read-timeout",
+        value_name = "DURATION",
+        value_parser = parse_duration,
+        requires = "contents",
+        help_heading = "Contents"
+    )]
+    pub read_timeout: Option<Duration>,
+
     /// Truncation strategy: head = first N lines, nest = collapse deep indentation (only with --max-chars)
     #[arg(
         long = "contents-mode",
@@ -187,6 +605,204 @@ pub struct Args {
     )]
     pub contents_mode: ContentsMode,
 
+    /// Placeholder for files whose contents are skipped, e.g. binaries ({path} = file path)
+    #[arg(
+        long = "content-placeholder",
+        value_name = "TEXT",
+        requires = "contents",
+        help_heading = "Contents"
+    )]
+    pub content_placeholder: Option<String>,
+
+    /// Add a section with the reason for every file whose contents are left
+    /// out (binary, special, unreadable, over --max-tokens or filtered)
+    #[arg(
+        long = "show-omitted-content",
+        requires = "contents",
+        help_heading = "Contents"
+    )]
+    pub show_omitted_content: bool,
+
+    /// Emit only the leading `---` front matter block of markdown files;
+    /// files without one get no section
+    #[arg(
+        long = "frontmatter-only",
+        requires = "contents",
+        help_heading = "Contents"
+    )]
+    pub frontmatter_only: bool,
+
+    /// Emit .json files without insignificant whitespace (invalid JSON is
+    /// emitted as-is with a warning)
+    #[arg(long = "minify-json", requires = "contents", help_heading = "Contents")]
+    pub minify_json: bool,
+
+    /// Emit .json files re-indented with 2 spaces (invalid JSON is emitted
+    /// as-is with a warning)
+    #[arg(
+        long = "pretty-json",
+        requires = "contents",
+        conflicts_with = "minify_json",
+        help_heading = "Contents"
+    )]
+    pub pretty_json: bool,
+
+    /// How each file section heading is formed
+    #[arg(
+        long = "heading-style",
+        value_enum,
+        default_value = "path",
+        requires = "contents",
+        help_heading = "Contents"
+    )]
+    pub heading_style: HeadingStyle,
+
+    /// Append each file's line count and size to its content heading, e.g. "## src/main.go (312 lines, 8.4 KB)"
+    #[arg(
+        long = "heading-meta",
+        requires = "contents",
+        help_heading = "Contents"
+    )]
+    pub heading_meta: bool,
+
+    /// Regex substitution applied to contents, e.g. "/home/me/=$ROOT/" (repeatable, in order)
+    #[arg(
+        long = "content-replace",
+        value_name = "REGEX=TEXT",
+        value_parser = ContentReplace::parse,
+        requires = "contents",
+        help_heading = "Contents"
+    )]
+    pub content_replace: Vec<ContentReplace>,
+
+    /// Only emit the contents of files that contain a match for REGEX
+    #[arg(
+        long = "content-if-matches",
+        value_name = "REGEX",
+        value_parser = Regex::new,
+        requires = "contents",
+        help_heading = "Contents"
+    )]
+    pub content_if_matches: Option<Regex>,
+
+    /// Split each code block at lines matching REGEX (e.g. "^\s*// MARK:"),
+    /// with the marker lines as subheadings
+    #[arg(
+        long = "split-content-on",
+        value_name = "REGEX",
+        value_parser = Regex::new,
+        requires = "contents",
+        help_heading = "Contents"
+    )]
+    pub split_content_on: Option<Regex>,
+
+    /// Only emit contents for files matching GLOB (repeatable; the tree is unaffected)
+    #[arg(
+        long = "content-match",
+        value_name = "GLOB",
+        value_parser = parse_content_glob,
+        requires = "contents",
+        help_heading = "Contents"
+    )]
+    pub content_match: Vec<Glob>,
+
+    /// Only emit contents for files at most N levels deep (files in the root are level 1);
+    /// independent of -L, which limits the tree itself
+    #[arg(
+        long = "content-depth",
+        value_name = "N",
+        requires = "contents",
+        help_heading = "Contents"
+    )]
+    pub content_depth: Option<usize>,
+
+    /// Only emit contents for exactly these paths, relative to the root
+    /// (comma-separated or repeatable; overrides the other content filters)
+    #[arg(
+        long = "content-paths",
+        value_name = "PATHS",
+        value_delimiter = ',',
+        requires = "contents",
+        help_heading = "Contents"
+    )]
+    pub content_paths: Vec<PathBuf>,
+
+    /// Prefix each content line with its last commit and author from `git blame`
+    #[arg(long = "git-blame", requires = "contents", help_heading = "Contents")]
+    pub git_blame: bool,
+
+    /// Skip --git-blame for files larger than BYTES
+    #[arg(
+        long = "blame-max-size",
+        value_name = "BYTES",
+        default_value_t = 1024 * 1024,
+        requires = "git_blame",
+        help_heading = "Contents"
+    )]
+    pub blame_max_size: u64,
+
+    /// Emit only lines START-END of the file at PATH, e.g. "src/main.rs:40-80" (repeatable)
+    #[arg(
+        long = "content-range",
+        value_name = "PATH:START-END",
+        value_parser = ContentRange::parse,
+        requires = "contents",
+        help_heading = "Contents"
+    )]
+    pub content_range: Vec<ContentRange>,
+
+    /// Detect the language of files with unknown extensions from vim/emacs modelines
+    #[arg(long = "sniff-content", help_heading = "Contents")]
+    pub sniff_content: bool,
+
+    /// Normalize leading whitespace to 4 spaces per level (skips Python, YAML, Makefiles, ...)
+    #[arg(
+        long = "normalize-indent",
+        requires = "contents",
+        help_heading = "Contents"
+    )]
+    pub normalize_indent: bool,
+
+    /// Convert CRLF and CR line endings in contents to LF
+    #[arg(
+        long = "normalize-eol",
+        requires = "contents",
+        help_heading = "Contents"
+    )]
+    pub normalize_eol: bool,
+
+    /// Note "[UTF-8 BOM detected]" at the end of the code block of files
+    /// starting with a byte order mark; the contents keep the BOM
+    #[arg(long = "note-bom", requires = "contents", help_heading = "Contents")]
+    pub note_bom: bool,
+
+    /// Strip leading and trailing blank lines from each file's contents
+    #[arg(
+        long = "trim-blank-lines",
+        requires = "contents",
+        help_heading = "Contents"
+    )]
+    pub trim_blank_lines: bool,
+
+    /// Cut content lines longer than N characters, marking them "… [line truncated]"
+    #[arg(
+        long = "max-line-length",
+        value_name = "N",
+        requires = "contents",
+        help_heading = "Contents"
+    )]
+    pub max_line_length: Option<usize>,
+
+    /// Template for the truncation message under --max-chars.
+    /// Tokens: {shownLines} {totalLines} {omittedLines} {shownBytes} {totalBytes} {type}
+    #[arg(
+        long = "truncation-format",
+        value_name = "TEMPLATE",
+        requires = "contents",
+        help_heading = "Contents"
+    )]
+    pub truncation_format: Option<String>,
+
     // ==================== Safety & Security ====================
     /// Apply safety filters (enabled by default)
     #[arg(long = "safe", help_heading = "Safety")]
@@ -195,14 +811,60 @@ pub struct Args {
     /// Disable all safety filters (not recommended)
     #[arg(long = "unsafe", conflicts_with = "safe", help_heading = "Safety")]
     pub unsafe_mode: bool,
+
+    /// Allow scanning a home directory or filesystem root
+    #[arg(long = "force", help_heading = "Safety")]
+    pub force: bool,
+
+    /// Stop walking after DURATION (e.g. 500ms, 30s, 2m), even inside a
+    /// hung directory read, and print the partial tree built so far
+    #[arg(
+        long = "timeout",
+        value_name = "DURATION",
+        value_parser = parse_duration,
+        help_heading = "Safety"
+    )]
+    pub timeout: Option<Duration>,
+
+    /// Stop walking once N directories have been visited and print the
+    /// partial tree built so far
+    #[arg(long = "max-total-dirs", value_name = "N", help_heading = "Safety")]
+    pub max_total_dirs: Option<usize>,
+
+    /// Stop printing at a line boundary before the output exceeds N bytes
+    /// and end with a truncation note
+    #[arg(long = "max-output-bytes", value_name = "N", help_heading = "Safety")]
+    pub max_output_bytes: Option<usize>,
+
+    /// Exit nonzero if any directory or file could not be read, after
+    /// printing the partial output (by default they are silently skipped)
+    #[arg(long = "strict", help_heading = "Safety")]
+    pub strict: bool,
 }
 
 impl Args {
+    /// Parse the process arguments, with TREE2MD_* variables filling in
+    /// options the command line leaves unset
+    pub fn parse_with_env() -> Self {
+        let argv = with_env_defaults(std::env::args_os().collect(), |key| std::env::var_os(key))
+            .unwrap_or_else(|e| e.exit());
+        Self::parse_from(argv)
+    }
+
     /// Determine if safe mode is enabled (default: true)
     pub fn is_safe_mode(&self) -> bool {
         !self.unsafe_mode
     }
 
+    /// Effective traversal depth, taking --no-recurse into account
+    pub fn effective_level(&self) -> Option<usize> {
+        if self.no_recurse {
+            Some(1)
+        } else {
+            self.level
+        }
+    }
+
     /// Check if stats should be shown
     pub fn should_show_stats(&self) -> bool {
         self.stats != StatsMode::Off
@@ -217,3 +879,113 @@ impl Args {
         }
     }
 }
+
+/// Insert `--flag=value` for each TREE2MD_* variable whose option is absent
+/// from `argv`. Env values are defaults, not explicit flags: TREE2MD_LEVEL
+/// yields to -R, TREE2MD_MAX_CHARS is dropped unless contents end up
+/// enabled, walk filters are dropped for --from-json, and a false
+/// TREE2MD_CONTENTS does not satisfy `requires`.
+fn with_env_defaults(
+    argv: Vec<OsString>,
+    var: impl Fn(&str) -> Option<OsString>,
+) -> Result<Vec<OsString>, clap::Error> {
+    let command = Args::command();
+    // Parse errors and --help are reported by the real parse
+    let Ok(matches) = command
+        .clone()
+        .ignore_errors(true)
+        .try_get_matches_from(&argv)
+    else {
+        return Ok(argv);
+    };
+    let on_command_line = |id: &str| matches.value_source(id) == Some(ValueSource::CommandLine);
+
+    let env_contents = match var("TREE2MD_CONTENTS").filter(|v| !v.is_empty()) {
+        Some(value) => BoolishValueParser::new()
+            .parse_ref(&command, None, &value)
+            .map_err(|_| {
+                command.clone().error(
+                    clap::error::ErrorKind::InvalidValue,
+                    format!(
+                        "invalid value '{}' for TREE2MD_CONTENTS",
+                        value.to_string_lossy()
+                    ),
+                )
+            })?,
+        None => false,
+    };
+    let contents = if on_command_line("contents") {
+        matches.get_flag("contents")
+    } else {
+        env_contents
+    };
+
+    let mut defaults = Vec::new();
+    for &(key, id, flag) in ENV_DEFAULTS {
+        let Some(value) = var(key).filter(|v| !v.is_empty()) else {
+            continue;
+        };
+        let skip = on_command_line(id)
+            || (id == "level" && on_command_line("no_recurse"))
+            || (id == "max_chars" && !contents)
+            || (matches!(id, "level" | "include_ext") && on_command_line("from_json"));
+        if skip {
+            continue;
+        }
+        if id == "contents" {
+            if env_contents {
+                defaults.push(OsString::from(flag));
+            }
+            continue;
+        }
+        let mut arg = OsString::from(flag);
+        arg.push("=");
+        arg.push(&value);
+        defaults.push(arg);
+    }
+
+    let mut argv = argv.into_iter();
+    Ok(argv
+        .next()
+        .into_iter()
+        .chain(defaults)
+        .chain(argv)
+        .collect())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::ffi::OsStr;
+
+    fn expand(args: &[&str], env: &[(&str, &str)]) -> Vec<String> {
+        let argv = args.iter().map(OsString::from).collect();
+        with_env_defaults(argv, |key| {
+            env.iter()
+                .find(|(k, _)| *k == key)
+                .map(|(_, v)| OsStr::new(v).to_os_string())
+        })
+        .unwrap()
+        .into_iter()
+        .map(|a| a.to_string_lossy().to_string())
+        .collect()
+    }
+
+    #[test]
+    fn test_command_line_wins_over_env() {
+        let argv = expand(&["tree2md", "-L", "3"], &[("TREE2MD_LEVEL", "2")]);
+        assert_eq!(argv, ["tree2md", "-L", "3"]);
+        let argv = expand(&["tree2md", "-R"], &[("TREE2MD_LEVEL", "2")]);
+        assert_eq!(argv, ["tree2md", "-R"]);
+    }
+
+    #[test]
+    fn test_contents_only_defaults_need_contents() {
+        let env = [("TREE2MD_MAX_CHARS", "8000"), ("TREE2MD_CONTENTS", "0")];
+        assert_eq!(expand(&["tree2md"], &env), ["tree2md"]);
+        assert_eq!(
+            expand(&["tree2md", "-c"], &env),
+            ["tree2md", "--max-chars=8000", "-c"]
+        );
+    }
+}
@@ -0,0 +1,155 @@
+use crate::fs_tree::git_status::git_output;
+use std::path::Path;
+
+/// Last commit to touch one line of a file
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct BlameLine {
+    /// Abbreviated commit hash
+    pub commit: String,
+    pub author: String,
+}
+
+/// Run `git blame` on `path`, one entry per line of the file. Returns None
+/// for untracked files or paths outside a git work tree.
+pub fn blame_lines(path: &Path) -> Option<Vec<BlameLine>> {
+    let dir = path.parent()?;
+    let name = path.file_name()?.to_str()?;
+    let output = git_output(dir, &["blame", "--line-porcelain", "--", name])?;
+    Some(parse_line_porcelain(&String::from_utf8_lossy(&output)))
+}
+
+/// Parse `git blame --line-porcelain`: every line is a header
+/// (`<hash> <orig> <final> ...`), `key value` lines, then the tab-prefixed
+/// source line.
+fn parse_line_porcelain(output: &str) -> Vec<BlameLine> {
+    let mut lines = Vec::new();
+    let mut commit = String::new();
+    let mut author = String::new();
+    let mut expect_header = true;
+
+    for line in output.lines() {
+        if line.starts_with('\t') {
+            lines.push(BlameLine {
+                commit: std::mem::take(&mut commit),
+                author: std::mem::take(&mut author),
+            });
+            expect_header = true;
+        } else if expect_header {
+            let hash = line.split(' ').next().unwrap_or("");
+            commit = hash.chars().take(7).collect();
+            expect_header = false;
+        } else if let Some(name) = line.strip_prefix("author ") {
+            author = name.to_string();
+        }
+    }
+    lines
+}
+
+/// Width of the prefix `annotate` puts before each line, at most: the
+/// abbreviated hash, the widest author in `blame` and the separators
+pub fn prefix_width(blame: &[BlameLine]) -> usize {
+    let author = blame
+        .iter()
+        .map(|b| b.author.chars().count())
+        .max()
+        .unwrap_or(0);
+    7 + 1 + author + 3
+}
+
+/// Prefix each line of `content` with the commit and author of the file
+/// line it shows, e.g. `1a2b3c4 Alice | fn main() {`. `sources` holds the
+/// 1-based file line of each line of `content`; lines without one (such as
+/// `... (N lines)` markers) get a blank prefix of the same width.
+pub fn annotate(content: &str, blame: &[BlameLine], sources: &[Option<usize>]) -> String {
+    let blame_of = |i: usize| {
+        sources
+            .get(i)
+            .copied()
+            .flatten()
+            .and_then(|line| blame.get(line.checked_sub(1)?))
+    };
+    let line_count = content.lines().count();
+    let width = (0..line_count)
+        .filter_map(blame_of)
+        .map(|b| b.author.chars().count())
+        .max()
+        .unwrap_or(0);
+
+    let mut annotated = String::with_capacity(content.len() + line_count * (width + 12));
+    for (i, line) in content.split_inclusive('\n').enumerate() {
+        match blame_of(i) {
+            Some(b) => annotated.push_str(&format!("{} {:<width$} | ", b.commit, b.author)),
+            None => annotated.push_str(&format!("{:7} {:width$} | ", "", "")),
+        }
+        annotated.push_str(line);
+    }
+    annotated
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const PORCELAIN: &str = "\
+1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b 1 1 2
+author Alice
+author-mail <alice@example.com>
+summary first
+filename main.rs
+\tfn main() {
+1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b 2 2
+author Alice
+summary first
+filename main.rs
+\t}
+0000000000000000000000000000000000000000 3 3 1
+author Not Committed Yet
+summary Version of main.rs from main.rs
+filename main.rs
+\t// wip
+";
+
+    fn blame(commit: &str, author: &str) -> BlameLine {
+        BlameLine {
+            commit: commit.to_string(),
+            author: author.to_string(),
+        }
+    }
+
+    #[test]
+    fn test_parse_line_porcelain() {
+        assert_eq!(
+            parse_line_porcelain(PORCELAIN),
+            vec![
+                blame("1a2b3c4", "Alice"),
+                blame("1a2b3c4", "Alice"),
+                blame("0000000", "Not Committed Yet"),
+            ]
+        );
+    }
+
+    #[test]
+    fn test_annotate_aligns_authors() {
+        let lines = vec![blame("aaaaaaa", "Al"), blame("bbbbbbb", "Beatrice")];
+        assert_eq!(
+            annotate("one\ntwo\n", &lines, &[Some(1), Some(2)]),
+            "aaaaaaa Al       | one\nbbbbbbb Beatrice | two\n"
+        );
+    }
+
+    #[test]
+    fn test_annotate_from_range_start() {
+        let lines = vec![blame("aaaaaaa", "A"), blame("bbbbbbb", "B")];
+        assert_eq!(annotate("two", &lines, &[Some(2)]), "bbbbbbb B | two");
+    }
+
+    #[test]
+    fn test_annotate_leaves_markers_unattributed() {
+        let lines = vec![blame("aaaaaaa", "Al"), blame("bbbbbbb", "Beatrice")];
+        assert_eq!(
+            annotate("one\n... (1 lines)", &lines, &[Some(1), None]),
+            format!("aaaaaaa Al | one\n{}| ... (1 lines)", " ".repeat(11))
+        );
+        assert_eq!(prefix_width(&lines), 7 + 1 + 8 + 3);
+    }
+}
@@ -0,0 +1,21 @@
+/// Line added at the end of a file's code block when its contents start
+/// with a UTF-8 byte order mark (--note-bom)
+pub const BOM_NOTE: &str = "[UTF-8 BOM detected]";
+
+/// Whether `content` starts with a UTF-8 byte order mark
+pub fn has_bom(content: &str) -> bool {
+    content.starts_with('\u{feff}')
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_has_bom() {
+        assert!(has_bom("\u{feff}id,name\n"));
+        assert!(!has_bom("id,name\n"));
+        // Only a leading mark counts
+        assert!(!has_bom("id,\u{feff}name\n"));
+    }
+}
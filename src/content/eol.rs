@@ -0,0 +1,19 @@
+/// Convert CRLF and lone CR line endings in `content` to LF (--normalize-eol)
+pub fn normalize_eol(content: &str) -> String {
+    content.replace("\r\n", "\n").replace('\r', "\n")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_mixed_line_endings_become_lf() {
+        assert_eq!(normalize_eol("a\r\nb\nc\rd\r\n"), "a\nb\nc\nd\n");
+    }
+
+    #[test]
+    fn test_lf_content_is_unchanged() {
+        assert_eq!(normalize_eol("fn a() {}\n"), "fn a() {}\n");
+    }
+}
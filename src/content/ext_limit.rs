@@ -0,0 +1,67 @@
+use std::path::Path;
+
+/// A per-extension line limit from --truncate-ext, e.g. `.json=20`
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct ExtLineLimit {
+    /// Extension without the leading dot, lowercased
+    pub ext: String,
+    /// Lines shown before the rest is omitted
+    pub lines: usize,
+}
+
+impl ExtLineLimit {
+    /// Parse an `EXT=N` spec; the leading dot on EXT is optional
+    pub fn parse(spec: &str) -> Result<Self, String> {
+        let (ext, lines) = spec
+            .split_once('=')
+            .ok_or_else(|| format!("expected EXT=N, got '{}'", spec))?;
+        let ext = ext.trim().trim_start_matches('.').to_ascii_lowercase();
+        if ext.is_empty() {
+            return Err(format!("missing extension in '{}'", spec));
+        }
+        let lines = lines
+            .trim()
+            .parse::<usize>()
+            .map_err(|_| format!("invalid line count '{}' in '{}'", lines, spec))?;
+        Ok(Self { ext, lines })
+    }
+}
+
+/// The limit for `path`'s extension, if one was given (last one wins)
+pub fn find_limit(limits: &[ExtLineLimit], path: &Path) -> Option<usize> {
+    let ext = path.extension()?.to_string_lossy().to_ascii_lowercase();
+    limits.iter().rev().find(|l| l.ext == ext).map(|l| l.lines)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_parse_limit() {
+        let limit = ExtLineLimit::parse(".json=20").unwrap();
+        assert_eq!((limit.ext.as_str(), limit.lines), ("json", 20));
+        let bare = ExtLineLimit::parse("LOCK=5").unwrap();
+        assert_eq!((bare.ext.as_str(), bare.lines), ("lock", 5));
+    }
+
+    #[test]
+    fn test_parse_limit_errors() {
+        assert!(ExtLineLimit::parse(".json").is_err());
+        assert!(ExtLineLimit::parse(".=5").is_err());
+        assert!(ExtLineLimit::parse(".json=many").is_err());
+    }
+
+    #[test]
+    fn test_find_limit() {
+        let limits = vec![
+            ExtLineLimit::parse(".json=20").unwrap(),
+            ExtLineLimit::parse(".lock=5").unwrap(),
+            ExtLineLimit::parse(".json=10").unwrap(),
+        ];
+        assert_eq!(find_limit(&limits, Path::new("a/data.JSON")), Some(10));
+        assert_eq!(find_limit(&limits, Path::new("Cargo.lock")), Some(5));
+        assert_eq!(find_limit(&limits, Path::new("main.go")), None);
+        assert_eq!(find_limit(&limits, Path::new("Makefile")), None);
+    }
+}
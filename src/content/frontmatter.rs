@@ -0,0 +1,59 @@
+use std::path::Path;
+
+/// Whether `path` is a markdown file (.md or .markdown)
+pub fn is_markdown(path: &Path) -> bool {
+    path.extension()
+        .and_then(|e| e.to_str())
+        .is_some_and(|e| e.eq_ignore_ascii_case("md") || e.eq_ignore_ascii_case("markdown"))
+}
+
+/// The leading `---`-delimited front matter block of `content`, delimiters
+/// included (--frontmatter-only). Empty when the file does not open with
+/// one or the block is never closed.
+pub fn front_matter(content: &str) -> &str {
+    let mut lines = content.split_inclusive('\n');
+    if lines.next().map(str::trim_end) != Some("---") {
+        return "";
+    }
+    let mut end = content.find('\n').map_or(content.len(), |i| i + 1);
+    for line in lines {
+        end += line.len();
+        if line.trim_end() == "---" {
+            return &content[..end];
+        }
+    }
+    ""
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_front_matter_block() {
+        let doc = "---\ntitle: Home\ntags: [a]\n---\n# Home\n\nBody text\n";
+        assert_eq!(front_matter(doc), "---\ntitle: Home\ntags: [a]\n---\n");
+    }
+
+    #[test]
+    fn test_no_front_matter() {
+        assert_eq!(front_matter("# Title\n---\nx\n---\n"), "");
+        assert_eq!(front_matter("---\ntitle: never closed\n"), "");
+        assert_eq!(front_matter(""), "");
+    }
+
+    #[test]
+    fn test_crlf_front_matter() {
+        assert_eq!(
+            front_matter("---\r\na: 1\r\n---\r\nbody\r\n"),
+            "---\r\na: 1\r\n---\r\n"
+        );
+    }
+
+    #[test]
+    fn test_is_markdown() {
+        assert!(is_markdown(Path::new("docs/index.md")));
+        assert!(is_markdown(Path::new("README.MARKDOWN")));
+        assert!(!is_markdown(Path::new("main.go")));
+    }
+}
@@ -0,0 +1,165 @@
+use std::collections::HashMap;
+use std::path::Path;
+
+/// Indentation width used when normalizing leading whitespace
+pub const NORMALIZED_INDENT_WIDTH: usize = 4;
+
+/// Predominant indentation style of a file
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum IndentStyle {
+    Tabs,
+    Spaces(usize),
+}
+
+/// Files whose leading whitespace carries meaning (or where tabs are
+/// mandatory) are never re-indented.
+fn is_whitespace_sensitive(path: &Path) -> bool {
+    let file_name = path
+        .file_name()
+        .and_then(|n| n.to_str())
+        .unwrap_or_default();
+    if matches!(file_name, "Makefile" | "makefile" | "GNUmakefile") {
+        return true;
+    }
+
+    let ext = path
+        .extension()
+        .and_then(|e| e.to_str())
+        .map(|e| e.to_lowercase())
+        .unwrap_or_default();
+    matches!(
+        ext.as_str(),
+        "py" | "pyi" | "pyw" | "yaml" | "yml" | "mk" | "md" | "sass" | "pug" | "haml" | "coffee"
+    )
+}
+
+/// Split a line into its leading whitespace and the rest
+fn split_indent(line: &str) -> (&str, &str) {
+    let end = line.len() - line.trim_start_matches([' ', '\t']).len();
+    line.split_at(end)
+}
+
+/// Detect the predominant indentation style.
+/// Space width is the most common change of two or more columns in
+/// indentation between consecutive non-blank lines; one-column steps come
+/// from alignment such as the ` * ` lines of a doc comment, not nesting.
+/// Returns None for unindented content.
+pub fn detect_indent(content: &str) -> Option<IndentStyle> {
+    let mut tab_lines = 0;
+    let mut space_lines = 0;
+    let mut deltas: HashMap<usize, usize> = HashMap::new();
+    let mut prev_width = 0;
+
+    for line in content.lines() {
+        let (indent, rest) = split_indent(line);
+        if rest.is_empty() {
+            continue;
+        }
+
+        if indent.starts_with('\t') {
+            tab_lines += 1;
+        } else if indent.starts_with(' ') {
+            space_lines += 1;
+        }
+
+        if !indent.contains('\t') {
+            let width = indent.len();
+            let delta = width.abs_diff(prev_width);
+            if delta > 1 {
+                *deltas.entry(delta).or_default() += 1;
+            }
+            prev_width = width;
+        }
+    }
+
+    if tab_lines == 0 && space_lines == 0 {
+        return None;
+    }
+    if tab_lines >= space_lines {
+        return Some(IndentStyle::Tabs);
+    }
+
+    // Most frequent delta; prefer the smaller width on ties
+    let width = deltas
+        .into_iter()
+        .max_by(|a, b| a.1.cmp(&b.1).then(b.0.cmp(&a.0)))
+        .map(|(delta, _)| delta)
+        .unwrap_or(NORMALIZED_INDENT_WIDTH);
+    Some(IndentStyle::Spaces(width))
+}
+
+/// Normalize leading whitespace to `NORMALIZED_INDENT_WIDTH` spaces per level.
+/// Tabs count as one level each; leftover spaces narrower than a level are
+/// kept as alignment. Whitespace-sensitive files are returned unchanged.
+pub fn normalize_indent(content: &str, path: &Path) -> String {
+    if is_whitespace_sensitive(path) {
+        return content.to_string();
+    }
+
+    let width = match detect_indent(content) {
+        Some(IndentStyle::Spaces(w)) => w,
+        Some(IndentStyle::Tabs) => NORMALIZED_INDENT_WIDTH,
+        None => return content.to_string(),
+    };
+
+    let mut result = String::with_capacity(content.len());
+    for line in content.split_inclusive('\n') {
+        let (indent, rest) = split_indent(line);
+        let tabs = indent.matches('\t').count();
+        let spaces = indent.len() - tabs;
+        let levels = tabs + spaces / width;
+        let align = spaces % width;
+
+        result.push_str(&" ".repeat(levels * NORMALIZED_INDENT_WIDTH + align));
+        result.push_str(rest);
+    }
+    result
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_detect_indent() {
+        assert_eq!(detect_indent("a\n\tb\n\t\tc\n"), Some(IndentStyle::Tabs));
+        assert_eq!(
+            detect_indent("a {\n  b {\n    c\n  }\n}\n"),
+            Some(IndentStyle::Spaces(2))
+        );
+        assert_eq!(detect_indent("a\nb\n"), None);
+    }
+
+    #[test]
+    fn test_detect_indent_ignores_doc_comment_alignment() {
+        let content = "class A {\n  /**\n   * One.\n   * Two.\n   */\n  f() {\n    \
+                       /**\n     * Three.\n     */\n    g();\n  }\n}\n";
+        assert_eq!(detect_indent(content), Some(IndentStyle::Spaces(2)));
+        // Only one-column steps: no width to detect, so the default applies
+        assert_eq!(
+            detect_indent("/**\n * a\n */\n"),
+            Some(IndentStyle::Spaces(NORMALIZED_INDENT_WIDTH))
+        );
+    }
+
+    #[test]
+    fn test_normalize_tabs_and_two_spaces() {
+        let path = Path::new("main.js");
+        assert_eq!(
+            normalize_indent("a {\n\tb;\n\t\tc;\n}\n", path),
+            "a {\n    b;\n        c;\n}\n"
+        );
+        assert_eq!(
+            normalize_indent("a {\n  b {\n    c;\n  }\n}", path),
+            "a {\n    b {\n        c;\n    }\n}"
+        );
+    }
+
+    #[test]
+    fn test_whitespace_sensitive_files_unchanged() {
+        let content = "def f():\n  return 1\n";
+        assert_eq!(normalize_indent(content, Path::new("a.py")), content);
+        let content = "all:\n\tcc main.c\n";
+        assert_eq!(normalize_indent(content, Path::new("Makefile")), content);
+    }
+}
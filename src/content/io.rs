@@ -1,6 +1,9 @@
 use std::fs::File;
 use std::io::{self, Read};
 use std::path::Path;
+use std::sync::mpsc;
+use std::thread;
+use std::time::Duration;
 
 /// Result of probing a file for binary/text characteristics
 #[derive(Debug)]
@@ -37,8 +40,79 @@ pub fn probe_file(path: &Path, max_probe: usize) -> io::Result<ProbeResult> {
     })
 }
 
+/// Name the kind of a special file (FIFO, socket or device), or None for
+/// regular files. Reading a special file can block forever or never end,
+/// so callers must not open one.
+#[cfg(unix)]
+pub fn special_file_kind(meta: &std::fs::Metadata) -> Option<&'static str> {
+    use std::os::unix::fs::FileTypeExt;
+    let file_type = meta.file_type();
+    if file_type.is_fifo() {
+        Some("fifo")
+    } else if file_type.is_socket() {
+        Some("socket")
+    } else if file_type.is_block_device() || file_type.is_char_device() {
+        Some("device")
+    } else {
+        None
+    }
+}
+
+/// Name the kind of a special file; only regular files exist here
+#[cfg(not(unix))]
+pub fn special_file_kind(_meta: &std::fs::Metadata) -> Option<&'static str> {
+    None
+}
+
+/// Read `path` to a string, giving up after `timeout` (--read-timeout) with
+/// an `ErrorKind::TimedOut` error. `None` reads with no limit.
+pub fn read_to_string_timeout(path: &Path, timeout: Option<Duration>) -> io::Result<String> {
+    match timeout {
+        Some(timeout) => {
+            let path = path.to_path_buf();
+            run_with_timeout(timeout, move || std::fs::read_to_string(path))
+        }
+        None => std::fs::read_to_string(path),
+    }
+}
+
+/// Read a text file that is not valid UTF-8, replacing each invalid
+/// sequence with U+FFFD (--validate-utf8). None when the file holds NUL
+/// bytes and is really binary.
+pub fn read_lossy_text(path: &Path) -> io::Result<Option<String>> {
+    let bytes = std::fs::read(path)?;
+    if bytes.contains(&0) {
+        return Ok(None);
+    }
+    Ok(Some(String::from_utf8_lossy(&bytes).into_owned()))
+}
+
+/// Run `op` on its own thread and wait at most `timeout` for it. A read that
+/// hangs (e.g. on a stale network mount) cannot be cancelled, so its thread
+/// is left behind and the caller moves on.
+pub fn run_with_timeout<T, F>(timeout: Duration, op: F) -> io::Result<T>
+where
+    T: Send + 'static,
+    F: FnOnce() -> io::Result<T> + Send + 'static,
+{
+    let (tx, rx) = mpsc::channel();
+    thread::spawn(move || {
+        // The receiver is gone once we have timed out; nothing to report
+        let _ = tx.send(op());
+    });
+    match rx.recv_timeout(timeout) {
+        Ok(result) => result,
+        Err(mpsc::RecvTimeoutError::Timeout) => Err(io::Error::new(
+            io::ErrorKind::TimedOut,
+            format!("read timed out after {:?}", timeout),
+        )),
+        Err(mpsc::RecvTimeoutError::Disconnected) => {
+            Err(io::Error::other("reader thread panicked"))
+        }
+    }
+}
+
 /// Check if a file is too large based on size limit
-#[allow(dead_code)]
 pub fn is_too_large(path: &Path, max_size: u64) -> bool {
     match path.metadata() {
         Ok(meta) => meta.len() > max_size,
@@ -72,6 +146,33 @@ mod tests {
     use std::fs;
     use tempfile::tempdir;
 
+    #[test]
+    fn test_run_with_timeout_gives_up_on_slow_reader() {
+        let slow = run_with_timeout(Duration::from_millis(20), || {
+            thread::sleep(Duration::from_secs(2));
+            Ok("late".to_string())
+        });
+        assert_eq!(slow.unwrap_err().kind(), io::ErrorKind::TimedOut);
+
+        let fast = run_with_timeout(Duration::from_secs(2), || Ok("on time".to_string()));
+        assert_eq!(fast.unwrap(), "on time");
+    }
+
+    #[test]
+    fn test_read_to_string_timeout() {
+        let dir = tempdir().unwrap();
+        let path = dir.path().join("a.txt");
+        fs::write(&path, "hello\n").unwrap();
+
+        let limited = read_to_string_timeout(&path, Some(Duration::from_secs(5))).unwrap();
+        assert_eq!(limited, "hello\n");
+        assert_eq!(read_to_string_timeout(&path, None).unwrap(), "hello\n");
+        assert!(
+            read_to_string_timeout(&dir.path().join("missing"), Some(Duration::from_secs(5)))
+                .is_err()
+        );
+    }
+
     #[test]
     fn test_probe_text_file() {
         let dir = tempdir().unwrap();
@@ -0,0 +1,141 @@
+use std::path::Path;
+
+/// Whether `path` is a JSON file by extension
+pub fn is_json(path: &Path) -> bool {
+    path.extension()
+        .and_then(|e| e.to_str())
+        .is_some_and(|e| e.eq_ignore_ascii_case("json"))
+}
+
+/// Re-encode JSON without insignificant whitespace (--minify-json). Keys
+/// keep their order and numbers their spelling, since only whitespace
+/// outside strings is dropped. Returns None for invalid JSON.
+pub fn minify_json(content: &str) -> Option<String> {
+    serde_json::from_str::<serde_json::Value>(content).ok()?;
+
+    let mut out = String::with_capacity(content.len());
+    let mut in_string = false;
+    let mut escaped = false;
+    for c in content.chars() {
+        if in_string {
+            out.push(c);
+            if escaped {
+                escaped = false;
+            } else if c == '\\' {
+                escaped = true;
+            } else if c == '"' {
+                in_string = false;
+            }
+        } else if c == '"' {
+            in_string = true;
+            out.push(c);
+        } else if !matches!(c, ' ' | '\t' | '\n' | '\r') {
+            out.push(c);
+        }
+    }
+    out.push('\n');
+    Some(out)
+}
+
+/// Re-encode JSON with 2-space indentation (--pretty-json), one value per
+/// line, keeping key order and number spelling. Returns None for invalid
+/// JSON.
+pub fn prettify_json(content: &str) -> Option<String> {
+    let compact = minify_json(content)?;
+    let chars: Vec<char> = compact.trim_end().chars().collect();
+
+    let mut out = String::with_capacity(compact.len() * 2);
+    let mut depth = 0usize;
+    let mut in_string = false;
+    let mut escaped = false;
+    let newline = |out: &mut String, depth: usize| {
+        out.push('\n');
+        out.push_str(&"  ".repeat(depth));
+    };
+    let mut i = 0;
+    while i < chars.len() {
+        let c = chars[i];
+        if in_string {
+            out.push(c);
+            if escaped {
+                escaped = false;
+            } else if c == '\\' {
+                escaped = true;
+            } else if c == '"' {
+                in_string = false;
+            }
+        } else {
+            match c {
+                '"' => {
+                    in_string = true;
+                    out.push(c);
+                }
+                '{' | '[' => {
+                    out.push(c);
+                    // Keep empty containers on one line: {} and []
+                    if matches!(chars.get(i + 1), Some('}' | ']')) {
+                        out.push(chars[i + 1]);
+                        i += 1;
+                    } else {
+                        depth += 1;
+                        newline(&mut out, depth);
+                    }
+                }
+                '}' | ']' => {
+                    depth = depth.saturating_sub(1);
+                    newline(&mut out, depth);
+                    out.push(c);
+                }
+                ',' => {
+                    out.push(c);
+                    newline(&mut out, depth);
+                }
+                ':' => out.push_str(": "),
+                _ => out.push(c),
+            }
+        }
+        i += 1;
+    }
+    out.push('\n');
+    Some(out)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_minify_keeps_order_and_strings() {
+        let pretty =
+            "{\n  \"name\": \"my app\",\n  \"tags\": [ \"a\\\" b\", \"c\" ],\n  \"n\": 1.50\n}\n";
+        assert_eq!(
+            minify_json(pretty).as_deref(),
+            Some("{\"name\":\"my app\",\"tags\":[\"a\\\" b\",\"c\"],\"n\":1.50}\n")
+        );
+    }
+
+    #[test]
+    fn test_invalid_json_is_rejected() {
+        assert_eq!(minify_json("{ \"a\": 1, }"), None);
+        assert_eq!(minify_json("// comment\n{}"), None);
+        assert_eq!(prettify_json("{\"a\":}"), None);
+    }
+
+    #[test]
+    fn test_prettify_nested() {
+        let compact = "{\"name\":\"a, b: {c}\",\"list\":[1,{\"x\":true}],\"empty\":{},\"none\":[]}";
+        assert_eq!(
+            prettify_json(compact).as_deref(),
+            Some(
+                "{\n  \"name\": \"a, b: {c}\",\n  \"list\": [\n    1,\n    {\n      \"x\": true\n    }\n  ],\n  \"empty\": {},\n  \"none\": []\n}\n"
+            )
+        );
+        assert_eq!(prettify_json("42").as_deref(), Some("42\n"));
+    }
+
+    #[test]
+    fn test_is_json() {
+        assert!(is_json(Path::new("config/app.JSON")));
+        assert!(!is_json(Path::new("app.jsonc")));
+    }
+}
@@ -1,2 +1,14 @@
+pub mod blame;
+pub mod bom;
+pub mod eol;
+pub mod ext_limit;
+pub mod frontmatter;
+pub mod indent;
 pub mod io;
+pub mod json;
+pub mod range;
+pub mod replace;
+pub mod split;
+pub mod tokens;
+pub mod trim;
 pub mod truncate;
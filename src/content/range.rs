@@ -0,0 +1,117 @@
+use std::path::Path;
+
+/// A line range to emit for one file instead of its whole contents
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct ContentRange {
+    /// Path relative to the target, as shown in section headings
+    pub path: String,
+    /// First line to emit (1-based, inclusive)
+    pub start: usize,
+    /// Last line to emit (inclusive), or None for the end of the file
+    pub end: Option<usize>,
+}
+
+impl ContentRange {
+    /// Parse a `PATH:START-END` spec, splitting at the last `:`.
+    /// END may be omitted (`PATH:40-`) to run to the end of the file.
+    pub fn parse(spec: &str) -> Result<Self, String> {
+        let (path, range) = spec
+            .rsplit_once(':')
+            .ok_or_else(|| format!("expected PATH:START-END, got '{}'", spec))?;
+        let (start, end) = range
+            .split_once('-')
+            .ok_or_else(|| format!("expected START-END line range, got '{}'", range))?;
+
+        let parse_line = |s: &str| {
+            s.parse::<usize>()
+                .ok()
+                .filter(|&n| n > 0)
+                .ok_or_else(|| format!("invalid line number '{}' in '{}'", s, spec))
+        };
+        let start = parse_line(start)?;
+        let end = if end.is_empty() {
+            None
+        } else {
+            Some(parse_line(end)?)
+        };
+        if end.is_some_and(|end| end < start) {
+            return Err(format!("line range '{}' ends before it starts", range));
+        }
+
+        let path = path.trim_start_matches("./").replace('\\', "/");
+        if path.is_empty() {
+            return Err(format!("missing path in '{}'", spec));
+        }
+
+        Ok(Self { path, start, end })
+    }
+
+    /// Human-readable range for headings, e.g. "40-80" or "40-end"
+    pub fn label(&self) -> String {
+        match self.end {
+            Some(end) => format!("{}-{}", self.start, end),
+            None => format!("{}-end", self.start),
+        }
+    }
+
+    /// Keep only the lines in range, preserving their line endings
+    pub fn apply(&self, content: &str) -> String {
+        let count = self.end.map_or(usize::MAX, |end| end - self.start + 1);
+        content
+            .split_inclusive('\n')
+            .skip(self.start - 1)
+            .take(count)
+            .collect()
+    }
+}
+
+/// The first range whose path matches `display_path`
+pub fn find_range<'a>(ranges: &'a [ContentRange], display_path: &Path) -> Option<&'a ContentRange> {
+    let path = display_path.to_string_lossy().replace('\\', "/");
+    ranges.iter().find(|r| r.path == path)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_parse_range() {
+        let range = ContentRange::parse("src/main.rs:40-80").unwrap();
+        assert_eq!(range.path, "src/main.rs");
+        assert_eq!((range.start, range.end), (40, Some(80)));
+
+        let open = ContentRange::parse("./lib.rs:3-").unwrap();
+        assert_eq!(open.path, "lib.rs");
+        assert_eq!((open.start, open.end), (3, None));
+    }
+
+    #[test]
+    fn test_parse_range_errors() {
+        assert!(ContentRange::parse("main.rs").is_err());
+        assert!(ContentRange::parse("main.rs:40").is_err());
+        assert!(ContentRange::parse("main.rs:0-5").is_err());
+        assert!(ContentRange::parse("main.rs:9-5").is_err());
+        assert!(ContentRange::parse(":1-5").is_err());
+    }
+
+    #[test]
+    fn test_apply_range() {
+        let content = "one\ntwo\nthree\nfour\n";
+        let range = ContentRange::parse("f:2-3").unwrap();
+        assert_eq!(range.apply(content), "two\nthree\n");
+
+        let open = ContentRange::parse("f:3-").unwrap();
+        assert_eq!(open.apply(content), "three\nfour\n");
+
+        let past_end = ContentRange::parse("f:9-12").unwrap();
+        assert_eq!(past_end.apply(content), "");
+    }
+
+    #[test]
+    fn test_find_range_by_display_path() {
+        let ranges = vec![ContentRange::parse("src/main.rs:1-2").unwrap()];
+        assert!(find_range(&ranges, Path::new("src/main.rs")).is_some());
+        assert!(find_range(&ranges, Path::new("main.rs")).is_none());
+    }
+}
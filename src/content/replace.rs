@@ -0,0 +1,71 @@
+use regex::{NoExpand, Regex};
+
+/// A regex substitution applied to file contents before they are emitted
+#[derive(Debug, Clone)]
+pub struct ContentReplace {
+    pub pattern: Regex,
+    pub replacement: String,
+}
+
+impl ContentReplace {
+    /// Parse a `REGEX=REPLACEMENT` spec, splitting at the first `=`.
+    /// The replacement is inserted literally (`$` has no special meaning).
+    pub fn parse(spec: &str) -> Result<Self, String> {
+        let (pattern, replacement) = spec
+            .split_once('=')
+            .ok_or_else(|| format!("expected REGEX=REPLACEMENT, got '{}'", spec))?;
+
+        if pattern.is_empty() {
+            return Err("regex must not be empty".to_string());
+        }
+
+        let pattern =
+            Regex::new(pattern).map_err(|e| format!("invalid regex '{}': {}", pattern, e))?;
+
+        Ok(Self {
+            pattern,
+            replacement: replacement.to_string(),
+        })
+    }
+}
+
+/// Apply all substitutions to `content` in order
+pub fn apply_replacements(content: &str, rules: &[ContentReplace]) -> String {
+    let mut result = content.to_string();
+    for rule in rules {
+        result = rule
+            .pattern
+            .replace_all(&result, NoExpand(&rule.replacement))
+            .into_owned();
+    }
+    result
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_parse_spec() {
+        let rule = ContentReplace::parse("/home/[a-z]+/=$ROOT/").unwrap();
+        assert_eq!(rule.pattern.as_str(), "/home/[a-z]+/");
+        assert_eq!(rule.replacement, "$ROOT/");
+    }
+
+    #[test]
+    fn test_parse_invalid_spec() {
+        assert!(ContentReplace::parse("no-separator").is_err());
+        assert!(ContentReplace::parse("=empty").is_err());
+        assert!(ContentReplace::parse("([a-z]=x").is_err());
+    }
+
+    #[test]
+    fn test_apply_replacements_in_order() {
+        let rules = vec![
+            ContentReplace::parse("/home/alice/=$ROOT/").unwrap(),
+            ContentReplace::parse(r"\$ROOT=<root>").unwrap(),
+        ];
+        let result = apply_replacements("path = \"/home/alice/src\"", &rules);
+        assert_eq!(result, "path = \"<root>/src\"");
+    }
+}
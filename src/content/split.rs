@@ -0,0 +1,99 @@
+use regex::Regex;
+
+/// One part of a file split by --split-content-on: the marker line that
+/// opens it (None for text before the first marker) and the lines after it
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct Section<'a> {
+    pub marker: Option<&'a str>,
+    pub body: &'a str,
+}
+
+/// Split `content` at every line matching `marker`. The marker lines become
+/// section labels and are left out of the bodies. Text before the first
+/// marker is kept as an unlabeled section unless it is blank. Content with
+/// no marker comes back as a single unlabeled section.
+pub fn split_on_markers<'a>(content: &'a str, marker: &Regex) -> Vec<Section<'a>> {
+    let mut sections = Vec::new();
+    let mut current: Option<&str> = None;
+    let mut start = 0;
+    let mut offset = 0;
+
+    for line in content.split_inclusive('\n') {
+        let text = line.trim_end_matches(['\n', '\r']);
+        if marker.is_match(text) {
+            let body = &content[start..offset];
+            if current.is_some() || !body.trim().is_empty() {
+                sections.push(Section {
+                    marker: current,
+                    body,
+                });
+            }
+            current = Some(text.trim());
+            start = offset + line.len();
+        }
+        offset += line.len();
+    }
+
+    let body = &content[start..];
+    if current.is_some() || !body.trim().is_empty() || sections.is_empty() {
+        sections.push(Section {
+            marker: current,
+            body,
+        });
+    }
+    sections
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn marker() -> Regex {
+        Regex::new(r"^\s*(#|//) (---|MARK:)").unwrap()
+    }
+
+    #[test]
+    fn test_split_with_preamble() {
+        let content = "import os\n# --- Setup\nx = 1\n# --- Run\nrun(x)\n";
+        let sections = split_on_markers(content, &marker());
+        assert_eq!(
+            sections,
+            vec![
+                Section {
+                    marker: None,
+                    body: "import os\n"
+                },
+                Section {
+                    marker: Some("# --- Setup"),
+                    body: "x = 1\n"
+                },
+                Section {
+                    marker: Some("# --- Run"),
+                    body: "run(x)\n"
+                },
+            ]
+        );
+    }
+
+    #[test]
+    fn test_blank_preamble_is_dropped() {
+        let content = "\n// MARK: Views\nlet v = 1\n";
+        let sections = split_on_markers(content, &marker());
+        assert_eq!(sections.len(), 1);
+        assert_eq!(sections[0].marker, Some("// MARK: Views"));
+        assert_eq!(sections[0].body, "let v = 1\n");
+    }
+
+    #[test]
+    fn test_no_markers_is_one_section() {
+        let sections = split_on_markers("a\nb\n", &marker());
+        assert_eq!(
+            sections,
+            vec![Section {
+                marker: None,
+                body: "a\nb\n"
+            }]
+        );
+        assert_eq!(split_on_markers("", &marker()).len(), 1);
+    }
+}
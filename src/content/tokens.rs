@@ -0,0 +1,21 @@
+/// Estimates how many LLM tokens a piece of text costs (used for --max-tokens)
+pub type TokenEstimator = fn(&str) -> usize;
+
+/// Rough token estimate: one token per 4 bytes, rounded up. Real
+/// tokenizers vary by model; this only needs to be in the right ballpark.
+pub fn estimate_tokens(text: &str) -> usize {
+    text.len().div_ceil(4)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_estimate_tokens() {
+        assert_eq!(estimate_tokens(""), 0);
+        assert_eq!(estimate_tokens("abc"), 1);
+        assert_eq!(estimate_tokens("abcd"), 1);
+        assert_eq!(estimate_tokens("abcde"), 2);
+    }
+}
@@ -0,0 +1,56 @@
+/// Strip leading and trailing blank (whitespace-only) lines from `content`,
+/// keeping blank lines between other lines. A final newline is preserved.
+/// Returns the trimmed content and the number of lines removed.
+pub fn trim_blank_lines(content: &str) -> (String, usize) {
+    let lines: Vec<&str> = content.split_inclusive('\n').collect();
+    let Some(first) = lines.iter().position(|l| !l.trim().is_empty()) else {
+        return (String::new(), lines.len());
+    };
+    let last = lines
+        .iter()
+        .rposition(|l| !l.trim().is_empty())
+        .expect("a non-blank line exists");
+
+    // Slice the original so line endings (CRLF included) are kept as-is
+    let start: usize = lines[..first].iter().map(|l| l.len()).sum();
+    let mut end: usize = start + lines[first..=last].iter().map(|l| l.len()).sum::<usize>();
+    if !content.ends_with('\n') {
+        end -= lines[last].len() - lines[last].trim_end_matches(['\r', '\n']).len();
+    }
+    (
+        content[start..end].to_string(),
+        lines.len() - (last + 1 - first),
+    )
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_trims_outer_blank_lines_only() {
+        assert_eq!(
+            trim_blank_lines("\n  \nfn a() {}\n\nfn b() {}\n\n\t\n"),
+            ("fn a() {}\n\nfn b() {}\n".to_string(), 4)
+        );
+    }
+
+    #[test]
+    fn test_untouched_without_outer_blanks() {
+        assert_eq!(trim_blank_lines("a\n\nb"), ("a\n\nb".to_string(), 0));
+    }
+
+    #[test]
+    fn test_all_blank() {
+        assert_eq!(trim_blank_lines("\n\n  \n"), (String::new(), 3));
+    }
+
+    #[test]
+    fn test_keeps_crlf_line_endings() {
+        assert_eq!(
+            trim_blank_lines("\r\n\r\nfn a() {}\r\n\r\nfn b() {}\r\n  \r\n"),
+            ("fn a() {}\r\n\r\nfn b() {}\r\n".to_string(), 3)
+        );
+        assert_eq!(trim_blank_lines("a\r\n\r\n  "), ("a".to_string(), 2));
+    }
+}
@@ -10,21 +10,74 @@ pub fn truncate_head_lines(content: &str, n: usize) -> (String, usize) {
     (kept, omitted)
 }
 
+/// How far (in lines) --truncate-at-boundary looks either side of a cut
+pub const BOUNDARY_WINDOW: usize = 10;
+
+/// The line count nearest to `n`, within `window` lines either way, at
+/// which code can be cut cleanly: just after a closing brace at column 0,
+/// or just before a blank line. Ties go to the shorter cut, and only
+/// shorter cuts are considered unless `allow_longer`. None when no such
+/// boundary is near or `n` keeps the whole content anyway.
+pub fn nearest_boundary(
+    content: &str,
+    n: usize,
+    window: usize,
+    allow_longer: bool,
+) -> Option<usize> {
+    let lines: Vec<&str> = content.lines().collect();
+    if n >= lines.len() {
+        return None;
+    }
+    let is_boundary = |k: usize| {
+        k > 0
+            && k < lines.len()
+            && (lines[k - 1].starts_with('}')
+                || (lines[k].trim().is_empty() && !lines[k - 1].trim().is_empty()))
+    };
+    (0..=window).find_map(|distance| {
+        [
+            n.checked_sub(distance),
+            allow_longer.then_some(n + distance),
+        ]
+        .into_iter()
+        .flatten()
+        .find(|&k| is_boundary(k))
+    })
+}
+
+/// Marker appended to lines cut by `truncate_long_lines`
+pub const LINE_TRUNCATED_MARKER: &str = "… [line truncated]";
+
+/// Cut every line longer than `max` characters down to `max` characters
+/// followed by `LINE_TRUNCATED_MARKER`. Line endings are kept.
+pub fn truncate_long_lines(content: &str, max: usize) -> String {
+    let mut result = String::with_capacity(content.len());
+    for line in content.split_inclusive('\n') {
+        let text = line.trim_end_matches(['\n', '\r']);
+        match text.char_indices().nth(max) {
+            Some((cut, _)) => {
+                result.push_str(&text[..cut]);
+                result.push_str(LINE_TRUNCATED_MARKER);
+                result.push_str(&line[text.len()..]);
+            }
+            None => result.push_str(line),
+        }
+    }
+    result
+}
+
 /// Find the largest n such that taking the first n lines of each file
-/// keeps total chars <= max_chars. Uses binary search.
-pub fn find_head_n(file_contents: &[&str], max_chars: usize) -> usize {
-    if file_contents.is_empty() {
+/// keeps total chars <= max_chars. Uses binary search over line profiles,
+/// so the file contents need not be held in memory.
+pub fn find_head_n(profiles: &[LineProfile], max_chars: usize) -> usize {
+    if profiles.is_empty() {
         return 0;
     }
 
-    let max_lines = file_contents
-        .iter()
-        .map(|c| c.lines().count())
-        .max()
-        .unwrap_or(0);
+    let max_lines = profiles.iter().map(|p| p.line_count()).max().unwrap_or(0);
 
     // Check if n=max_lines already fits
-    if total_chars_at_head_n(file_contents, max_lines) <= max_chars {
+    if total_chars_at_head_n(profiles, max_lines) <= max_chars {
         return max_lines;
     }
 
@@ -34,7 +87,7 @@ pub fn find_head_n(file_contents: &[&str], max_chars: usize) -> usize {
 
     while lo < hi {
         let mid = lo + (hi - lo).div_ceil(2);
-        if total_chars_at_head_n(file_contents, mid) <= max_chars {
+        if total_chars_at_head_n(profiles, mid) <= max_chars {
             lo = mid;
         } else {
             hi = mid - 1;
@@ -44,19 +97,16 @@ pub fn find_head_n(file_contents: &[&str], max_chars: usize) -> usize {
     lo
 }
 
-fn total_chars_at_head_n(file_contents: &[&str], n: usize) -> usize {
-    file_contents
-        .iter()
-        .map(|content| {
-            let (truncated, _) = truncate_head_lines(content, n);
-            truncated.len()
-        })
-        .sum()
+fn total_chars_at_head_n(profiles: &[LineProfile], n: usize) -> usize {
+    profiles.iter().map(|p| p.head_len(n)).sum()
 }
 
 /// Collapse lines with indent > threshold into `... (N lines)` markers.
-pub fn collapse_at_indent(lines: &[&str], threshold: usize) -> (String, usize) {
+/// Also returns the number of lines omitted and the index in `lines` of
+/// each output line, or None for a marker.
+pub fn collapse_at_indent(lines: &[&str], threshold: usize) -> (String, usize, Vec<Option<usize>>) {
     let mut result = String::new();
+    let mut sources = Vec::new();
     let mut total_omitted = 0;
     let mut i = 0;
 
@@ -65,10 +115,11 @@ pub fn collapse_at_indent(lines: &[&str], threshold: usize) -> (String, usize) {
 
         // Empty lines are always kept (they don't have meaningful indent)
         if line.trim().is_empty() || indent_level(line) <= threshold {
-            if !result.is_empty() {
+            if !sources.is_empty() {
                 result.push('\n');
             }
             result.push_str(line);
+            sources.push(Some(i));
             i += 1;
         } else {
             // Count consecutive lines that exceed threshold
@@ -81,43 +132,32 @@ pub fn collapse_at_indent(lines: &[&str], threshold: usize) -> (String, usize) {
             }
             let count = i - start;
             total_omitted += count;
-            if !result.is_empty() {
+            if !sources.is_empty() {
                 result.push('\n');
             }
             result.push_str(&format!("... ({} lines)", count));
+            sources.push(None);
         }
     }
 
-    (result, total_omitted)
+    (result, total_omitted, sources)
 }
 
 /// Find the largest indent threshold such that collapsing lines with
 /// indent > threshold across all files keeps total chars <= max_chars.
 /// Returns the threshold, or None if even threshold=0 doesn't fit
 /// (in which case caller should fall back to head mode).
-pub fn find_nest_threshold(file_contents: &[&str], max_chars: usize) -> Option<usize> {
-    if file_contents.is_empty() {
+pub fn find_nest_threshold(profiles: &[LineProfile], max_chars: usize) -> Option<usize> {
+    if profiles.is_empty() {
         return Some(usize::MAX);
     }
 
-    // Collect all lines per file
-    let file_lines: Vec<Vec<&str>> = file_contents.iter().map(|c| c.lines().collect()).collect();
-
     // Find the max indent across all files
-    let max_indent = file_lines
-        .iter()
-        .flat_map(|lines| lines.iter())
-        .filter(|l| !l.trim().is_empty())
-        .map(|l| indent_level(l))
-        .max()
-        .unwrap_or(0);
+    let max_indent = profiles.iter().map(|p| p.max_indent()).max().unwrap_or(0);
 
     // Try thresholds from high to low (high = less collapsing)
     for threshold in (0..=max_indent).rev() {
-        let total: usize = file_lines
-            .iter()
-            .map(|lines| collapse_at_indent(lines, threshold).0.len())
-            .sum();
+        let total: usize = profiles.iter().map(|p| p.collapsed_len(threshold)).sum();
         if total <= max_chars {
             return Some(threshold);
         }
@@ -127,14 +167,165 @@ pub fn find_nest_threshold(file_contents: &[&str], max_chars: usize) -> Option<u
     None
 }
 
+/// Per-line lengths and indents of a file, enough to compute the size of
+/// `truncate_head_lines` and `collapse_at_indent` output without keeping
+/// the content itself.
+#[derive(Debug, Clone, Default)]
+pub struct LineProfile {
+    total_len: usize,
+    /// (line length, indent level or None for blank lines)
+    lines: Vec<(usize, Option<usize>)>,
+    /// Width of a prefix put before every emitted line (--git-blame)
+    prefix: usize,
+}
+
+impl LineProfile {
+    pub fn from_content(content: &str) -> Self {
+        let lines = content
+            .lines()
+            .map(|line| {
+                let indent = if line.trim().is_empty() {
+                    None
+                } else {
+                    Some(indent_level(line))
+                };
+                (line.len(), indent)
+            })
+            .collect();
+        Self {
+            total_len: content.len(),
+            lines,
+            prefix: 0,
+        }
+    }
+
+    /// Count `width` more characters for every emitted line, markers
+    /// included, as --git-blame annotations add
+    pub fn with_line_prefix(mut self, width: usize) -> Self {
+        self.total_len += width * self.lines.len();
+        self.prefix = width;
+        self
+    }
+
+    pub fn total_len(&self) -> usize {
+        self.total_len
+    }
+
+    pub fn line_count(&self) -> usize {
+        self.lines.len()
+    }
+
+    fn max_indent(&self) -> usize {
+        self.lines
+            .iter()
+            .filter_map(|&(_, indent)| indent)
+            .max()
+            .unwrap_or(0)
+    }
+
+    /// Length of `truncate_head_lines(content, n)`
+    pub fn head_len(&self, n: usize) -> usize {
+        if n >= self.lines.len() {
+            return self.total_len;
+        }
+        let kept: usize = self.lines[..n].iter().map(|&(len, _)| len).sum();
+        kept + n * self.prefix + n.saturating_sub(1)
+    }
+
+    /// Length of `collapse_at_indent(lines, threshold)`
+    pub fn collapsed_len(&self, threshold: usize) -> usize {
+        let mut len = 0;
+        let mut pieces = 0;
+        let mut i = 0;
+        while i < self.lines.len() {
+            let piece = match self.lines[i].1 {
+                Some(indent) if indent > threshold => {
+                    let start = i;
+                    while i < self.lines.len() && self.lines[i].1.is_some_and(|d| d > threshold) {
+                        i += 1;
+                    }
+                    format!("... ({} lines)", i - start).len()
+                }
+                _ => {
+                    i += 1;
+                    self.lines[i - 1].0
+                }
+            };
+            // Mirrors collapse_at_indent: a newline before every piece but
+            // the first
+            if pieces > 0 {
+                len += 1;
+            }
+            pieces += 1;
+            len += piece + self.prefix;
+        }
+        len
+    }
+}
+
 fn indent_level(line: &str) -> usize {
     line.len() - line.trim_start().len()
 }
 
+/// Default template for the truncation message (see `truncation_message`)
+pub const DEFAULT_TRUNCATION_FORMAT: &str = "... ({omittedLines} lines omitted)";
+
+/// Details about a truncated file section
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct TruncationInfo {
+    pub shown_lines: usize,
+    pub total_lines: usize,
+    pub shown_bytes: usize,
+    pub total_bytes: usize,
+    /// Truncation applied to the section ("head", "nest", "preview" for
+    /// --preview-larger-than, or "none" when everything fit)
+    pub kind: &'static str,
+}
+
+impl TruncationInfo {
+    /// Describe a truncation of `original` down to `shown`, where `omitted_lines`
+    /// original lines were dropped or collapsed.
+    pub fn new(original: &str, shown: &str, omitted_lines: usize, kind: &'static str) -> Self {
+        let total_lines = original.lines().count();
+        Self {
+            shown_lines: total_lines.saturating_sub(omitted_lines),
+            total_lines,
+            shown_bytes: shown.len(),
+            total_bytes: original.len(),
+            kind,
+        }
+    }
+
+    pub fn omitted_lines(&self) -> usize {
+        self.total_lines - self.shown_lines
+    }
+
+    pub fn is_truncated(&self) -> bool {
+        self.omitted_lines() > 0
+    }
+}
+
+/// Render the truncation message from a template.
+/// Supported tokens: `{shownLines}`, `{totalLines}`, `{omittedLines}`,
+/// `{shownBytes}`, `{totalBytes}`, `{type}`.
+pub fn truncation_message(info: &TruncationInfo, template: &str) -> String {
+    template
+        .replace("{shownLines}", &info.shown_lines.to_string())
+        .replace("{totalLines}", &info.total_lines.to_string())
+        .replace("{omittedLines}", &info.omitted_lines().to_string())
+        .replace("{shownBytes}", &info.shown_bytes.to_string())
+        .replace("{totalBytes}", &info.total_bytes.to_string())
+        .replace("{type}", info.kind)
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
 
+    fn profiles(files: &[&str]) -> Vec<LineProfile> {
+        files.iter().map(|c| LineProfile::from_content(c)).collect()
+    }
+
     #[test]
     fn test_truncate_head_lines_no_truncation() {
         let content = "line1\nline2\nline3";
@@ -151,6 +342,29 @@ mod tests {
         assert_eq!(omitted, 2);
     }
 
+    #[test]
+    fn test_truncate_long_lines() {
+        assert_eq!(
+            truncate_long_lines("short\r\nabcdefghij\nébcdéfgh", 5),
+            "short\r\nabcde… [line truncated]\nébcdé… [line truncated]"
+        );
+    }
+
+    #[test]
+    fn test_nearest_boundary_prefers_closest_cut() {
+        let content = "package main\n\nfunc a() {\n\treturn\n}\nfunc b() {\n\tx()\n\ty()\n}\n";
+        // Cutting at 4 would end inside a(); its closing brace is one line on
+        assert_eq!(nearest_boundary(content, 4, 10, true), Some(5));
+        // Equally far from the blank line (1) and the brace (5): the shorter cut wins
+        assert_eq!(nearest_boundary(content, 3, 10, true), Some(1));
+        // Nothing within reach
+        assert_eq!(nearest_boundary(content, 7, 1, true), None);
+        // Keeping everything needs no boundary
+        assert_eq!(nearest_boundary(content, 9, 10, true), None);
+        // Without longer cuts, the brace one line on is out of reach
+        assert_eq!(nearest_boundary(content, 4, 10, false), Some(1));
+    }
+
     #[test]
     fn test_truncate_head_lines_zero() {
         let content = "line1\nline2";
@@ -162,7 +376,7 @@ mod tests {
     #[test]
     fn test_find_head_n_all_fit() {
         let files = vec!["aaa\nbbb", "ccc"];
-        let n = find_head_n(&files, 1000);
+        let n = find_head_n(&profiles(&files), 1000);
         assert_eq!(n, 2); // max lines across files
     }
 
@@ -172,7 +386,7 @@ mod tests {
         // file2: "dddd\neeee\nffff"
         // n=3 => 14+14=28, n=2 => 9+9=18, n=1 => 4+4=8
         let files = vec!["aaaa\nbbbb\ncccc", "dddd\neeee\nffff"];
-        let n = find_head_n(&files, 20);
+        let n = find_head_n(&profiles(&files), 20);
         assert_eq!(n, 2); // n=2 => 18 <= 20, n=3 => 28 > 20
     }
 
@@ -180,7 +394,7 @@ mod tests {
     fn test_find_head_n_uniform() {
         // All files get the same n
         let files = vec!["a\nb\nc\nd", "e\nf\ng\nh"];
-        let n = find_head_n(&files, 6);
+        let n = find_head_n(&profiles(&files), 6);
         // n=2 => "a\nb"(3) + "e\nf"(3) = 6 <= 6
         assert_eq!(n, 2);
 
@@ -196,23 +410,24 @@ mod tests {
     #[test]
     fn test_find_head_n_empty() {
         let files: Vec<&str> = vec![];
-        assert_eq!(find_head_n(&files, 100), 0);
+        assert_eq!(find_head_n(&profiles(&files), 100), 0);
     }
 
     #[test]
     fn test_collapse_at_indent() {
         let lines = vec!["fn main() {", "    let x = 1;", "    let y = 2;", "}"];
-        let (result, omitted) = collapse_at_indent(&lines, 0);
+        let (result, omitted, sources) = collapse_at_indent(&lines, 0);
         assert!(result.contains("fn main() {"));
         assert!(result.contains("... (2 lines)"));
         assert!(result.contains("}"));
         assert_eq!(omitted, 2);
+        assert_eq!(sources, vec![Some(0), None, Some(3)]);
     }
 
     #[test]
     fn test_find_nest_threshold_all_fit() {
         let files = vec!["fn main() {\n    hello();\n}"];
-        let threshold = find_nest_threshold(&files, 1000);
+        let threshold = find_nest_threshold(&profiles(&files), 1000);
         // Max indent is 4, so threshold should be >= max indent (everything kept)
         assert!(threshold.is_some());
         assert!(threshold.unwrap() >= 4);
@@ -225,15 +440,15 @@ mod tests {
         let files = vec![file1, file2];
 
         // Find threshold with tight budget
-        let threshold = find_nest_threshold(&files, 80);
+        let threshold = find_nest_threshold(&profiles(&files), 80);
         assert!(threshold.is_some());
         let t = threshold.unwrap();
 
         // Both files should be collapsed at the same threshold
         let lines1: Vec<&str> = file1.lines().collect();
         let lines2: Vec<&str> = file2.lines().collect();
-        let (_, o1) = collapse_at_indent(&lines1, t);
-        let (_, o2) = collapse_at_indent(&lines2, t);
+        let (_, o1, _) = collapse_at_indent(&lines1, t);
+        let (_, o2, _) = collapse_at_indent(&lines2, t);
         // Same threshold means symmetric collapsing for symmetric files
         assert_eq!(o1, o2);
     }
@@ -243,7 +458,50 @@ mod tests {
         // Even with threshold=0, content is too large
         let big = "a".repeat(1000);
         let files = vec![big.as_str()];
-        let threshold = find_nest_threshold(&files, 10);
+        let threshold = find_nest_threshold(&profiles(&files), 10);
         assert!(threshold.is_none());
     }
+
+    #[test]
+    fn test_line_profile_matches_string_lengths() {
+        let samples = [
+            "",
+            "\n\nfoo",
+            "fn a() {\n    if x {\n        deep();\n\n        more();\n    }\n}\n",
+            "  \n\tindented\r\nplain\n      \n  x",
+        ];
+        for content in samples {
+            let profile = LineProfile::from_content(content);
+            let lines: Vec<&str> = content.lines().collect();
+            for n in 0..=lines.len() + 1 {
+                assert_eq!(profile.head_len(n), truncate_head_lines(content, n).0.len());
+            }
+            for t in 0..=9 {
+                let (collapsed, _, sources) = collapse_at_indent(&lines, t);
+                assert_eq!(profile.collapsed_len(t), collapsed.len());
+                assert_eq!(collapsed.lines().count().max(1), sources.len().max(1));
+            }
+        }
+    }
+
+    #[test]
+    fn test_truncation_message_default_format() {
+        let info = TruncationInfo::new("a\nb\nc\nd", "a\nb", 2, "head");
+        assert_eq!(
+            truncation_message(&info, DEFAULT_TRUNCATION_FORMAT),
+            "... (2 lines omitted)"
+        );
+    }
+
+    #[test]
+    fn test_truncation_message_custom_format() {
+        let info = TruncationInfo::new("a\nb\nc\nd", "a\nb", 2, "head");
+        assert_eq!(
+            truncation_message(
+                &info,
+                "<!-- {type}: {shownLines}/{totalLines} lines, {shownBytes}/{totalBytes} bytes -->"
+            ),
+            "<!-- head: 2/4 lines, 3/7 bytes -->"
+        );
+    }
 }
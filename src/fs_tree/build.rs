@@ -1,12 +1,28 @@
+use super::git_status::changed_files;
 use super::node::Node;
-use crate::cli::Args;
-use crate::matcher::{MatchSpec, MatcherEngine, RelPath, Selection};
+use super::sort::{compare_nodes, sort_dirs_by_file_count, sort_dirs_by_size, SortOptions};
+use crate::cli::{Args, SortMode};
+use crate::matcher::{is_vcs_dir_name, MatchSpec, MatcherEngine, RelPath, Selection};
 use crate::util::path::calculate_display_path;
-use ignore::WalkBuilder;
-use std::collections::HashMap;
+use ignore::{DirEntry, WalkBuilder};
+use std::collections::{HashMap, HashSet};
 use std::fs;
 use std::io;
 use std::path::{Path, PathBuf};
+use std::sync::mpsc;
+use std::thread;
+use std::time::Instant;
+
+/// How a walk ended
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct WalkReport {
+    /// The walk was cut short by --timeout; the tree is partial
+    pub timed_out: bool,
+    /// Directories and files the walk could not read (permission denied, ...)
+    pub errors: Vec<String>,
+    /// The walk stopped at the --max-total-dirs limit; the tree is partial
+    pub dir_limit_reached: bool,
+}
 
 /// Build tree using WalkBuilder for unified gitignore support with MatcherEngine
 pub fn build_tree(
@@ -14,20 +30,69 @@ pub fn build_tree(
     args: &Args,
     root_path: &Path,
     display_root: &Path,
-) -> io::Result<Node> {
+) -> io::Result<(Node, WalkReport)> {
     // Create MatchSpec from CLI arguments
     let spec = MatchSpec::from_args(args, Path::new(path));
     build_tree_with_spec(path, args, &spec, root_path, display_root)
 }
 
-/// Build tree using the MatcherEngine architecture
+/// Build tree using the MatcherEngine architecture, stopping at the
+/// --timeout deadline if one is set
 pub fn build_tree_with_spec(
     path: &str,
     args: &Args,
     spec: &MatchSpec,
     root_path: &Path,
     display_root: &Path,
-) -> io::Result<Node> {
+) -> io::Result<(Node, WalkReport)> {
+    // A deadline past what Instant can represent is no deadline at all
+    let deadline = args
+        .timeout
+        .and_then(|timeout| Instant::now().checked_add(timeout));
+    let mut expired = || deadline.is_some_and(|d| Instant::now() >= d);
+    build_tree_until(
+        path,
+        args,
+        spec,
+        root_path,
+        display_root,
+        deadline,
+        &mut expired,
+    )
+}
+
+/// Walk `walker` on a worker thread, handing entries over a bounded
+/// channel. A `read_dir` that hangs (e.g. on a stale network mount) then
+/// only blocks that thread, which is left behind once the receiver gives
+/// up; a dropped receiver also ends the walk at its next entry.
+fn spawn_walk(walker: WalkBuilder) -> mpsc::Receiver<Result<DirEntry, ignore::Error>> {
+    let (tx, rx) = mpsc::sync_channel(WALK_CHANNEL_CAPACITY);
+    thread::spawn(move || {
+        for entry in walker.build() {
+            if tx.send(entry).is_err() {
+                break;
+            }
+        }
+    });
+    rx
+}
+
+/// Entries the walk thread may run ahead of tree construction
+const WALK_CHANNEL_CAPACITY: usize = 1024;
+
+/// Build tree, checking `should_stop` before each entry and waiting for the
+/// next one no later than `deadline`. Once either says stop, the walk ends
+/// and the tree built so far is returned.
+fn build_tree_until(
+    path: &str,
+    args: &Args,
+    spec: &MatchSpec,
+    root_path: &Path,
+    display_root: &Path,
+    deadline: Option<Instant>,
+    should_stop: &mut dyn FnMut() -> bool,
+) -> io::Result<(Node, WalkReport)> {
+    let mut report = WalkReport::default();
     let path_buf = Path::new(path);
     let metadata = fs::metadata(path_buf)?;
     let name = path_buf
@@ -59,17 +124,54 @@ pub fn build_tree_with_spec(
             .parents(false)
             .ignore(false)
             .follow_links(false) // Skip symlinks as per spec
-            .max_depth(args.level); // Use level directly
+            .max_depth(args.effective_level());
+        // Never read VCS metadata directories such as .git/objects; the
+        // matcher prunes them too, this keeps the walker out of them
+        if !args.include_vcs {
+            walker.filter_entry(|entry| {
+                !(entry.depth() > 0
+                    && entry.file_type().is_some_and(|ft| ft.is_dir())
+                    && is_vcs_dir_name(&entry.file_name().to_string_lossy()))
+            });
+        }
 
         // Build a map of paths to nodes for efficient tree construction
         let mut nodes_map: HashMap<PathBuf, Node> = HashMap::new();
         let mut pruned_dirs: std::collections::HashSet<PathBuf> = std::collections::HashSet::new();
         let mut has_nested_repo_pruning = false;
+        let mut dirs_visited = 0;
+
+        let entries = spawn_walk(walker);
+        loop {
+            if should_stop() {
+                report.timed_out = true;
+                break;
+            }
+            let entry = match deadline {
+                Some(deadline) => {
+                    match entries.recv_timeout(deadline.saturating_duration_since(Instant::now())) {
+                        Ok(entry) => entry,
+                        Err(mpsc::RecvTimeoutError::Timeout) => {
+                            report.timed_out = true;
+                            break;
+                        }
+                        Err(mpsc::RecvTimeoutError::Disconnected) => break,
+                    }
+                }
+                None => match entries.recv() {
+                    Ok(entry) => entry,
+                    Err(_) => break,
+                },
+            };
 
-        for entry in walker.build() {
             let entry = match entry {
                 Ok(e) => e,
-                Err(_) => continue,
+                // Unreadable entries are left out; --strict fails the run
+                // once the partial output is printed
+                Err(e) => {
+                    report.errors.push(e.to_string());
+                    continue;
+                }
             };
 
             let entry_path = entry.path();
@@ -79,8 +181,13 @@ pub fn build_tree_with_spec(
                 continue;
             }
 
-            // Skip symlinks entirely (per spec: "Symlinks are always skipped")
-            if entry.file_type().map(|ft| ft.is_symlink()).unwrap_or(false) {
+            // Skip symlinks unless --follow-symlinks lists symlinked files.
+            // Symlinked directories are always skipped, so the walk cannot loop.
+            let is_symlink = entry.file_type().map(|ft| ft.is_symlink()).unwrap_or(false);
+            if is_symlink
+                && (!args.follow_symlinks
+                    || std::fs::metadata(entry_path).is_ok_and(|m| m.is_dir()))
+            {
                 continue;
             }
 
@@ -99,17 +206,30 @@ pub fn build_tree_with_spec(
                 continue;
             }
 
-            let entry_metadata = match entry.metadata() {
-                Ok(m) => m,
-                Err(_) => continue,
+            // The file type comes from the directory listing, so no stat
+            // is needed per entry
+            let is_dir = match entry.file_type() {
+                Some(ft) => ft.is_dir(),
+                None => continue,
             };
 
+            // Guard against huge fan-out: stop once --max-total-dirs
+            // directories have been visited, whether or not the filters
+            // below keep them
+            if is_dir {
+                dirs_visited += 1;
+                if args.max_total_dirs.is_some_and(|max| dirs_visited > max) {
+                    report.dir_limit_reached = true;
+                    break;
+                }
+            }
+
             // Prune nested git repositories / worktrees / submodules.
             // If a subdirectory contains a `.git` entry (file or directory),
             // it represents a separate repository boundary and should not be
             // traversed. This prevents worktrees, submodules, and nested repos
             // from leaking into the output.
-            if entry_metadata.is_dir() && entry_path.join(".git").exists() {
+            if is_dir && entry_path.join(".git").exists() {
                 pruned_dirs.insert(entry_path.to_path_buf());
                 has_nested_repo_pruning = true;
                 continue;
@@ -122,7 +242,7 @@ pub fn build_tree_with_spec(
             };
 
             // Apply matcher engine selection
-            let selection = if entry_metadata.is_dir() {
+            let selection = if is_dir {
                 matcher.select_dir(&rel_path)
             } else {
                 matcher.select_file(&rel_path)
@@ -148,36 +268,97 @@ pub fn build_tree_with_spec(
                 .to_string_lossy()
                 .to_string();
 
-            let resolved_entry_path = entry_path
-                .canonicalize()
-                .unwrap_or_else(|_| entry_path.to_path_buf());
+            // Symlinks are skipped or kept as links, so joining onto the
+            // canonical root gives the canonical path without resolving
+            // every entry on disk
+            let resolved_entry_path = match entry_path.strip_prefix(path_buf) {
+                Ok(rel) => resolved_path.join(rel),
+                Err(_) => entry_path
+                    .canonicalize()
+                    .unwrap_or_else(|_| entry_path.to_path_buf()),
+            };
 
             let entry_display_path = calculate_display_path(&resolved_entry_path, display_root);
 
-            let node = Node::new(entry_name, resolved_entry_path, entry_metadata.is_dir())
-                .with_display_path(entry_display_path);
+            let link_target = if is_symlink {
+                std::fs::read_link(entry_path).ok()
+            } else {
+                None
+            };
+            let node = Node::new(entry_name, resolved_entry_path, is_dir)
+                .with_display_path(entry_display_path)
+                .with_link_target(link_target);
 
             nodes_map.insert(entry_path.to_path_buf(), node);
         }
 
         // Build the tree structure from the flat map
-        build_tree_from_map(&mut root_node, &nodes_map, path_buf)?;
+        let sort = SortOptions::from_args(args);
+        build_tree_from_map(&mut root_node, &nodes_map, path_buf, &sort)?;
+
+        // Keep only files changed in the --changed-in range
+        if let Some(range) = &args.changed_in {
+            match changed_files(&resolved_path, range) {
+                Some(changed) => retain_changed(&mut root_node, &changed),
+                None => eprintln!(
+                    "Warning: --changed-in ignored: '{}' is not a valid range in a git repository",
+                    range
+                ),
+            }
+        }
 
         // Remove directories left empty after pruning (include filtering,
         // nested-repo detection, etc.). Not run unconditionally because
         // empty dirs at --level boundary should remain visible.
-        if spec.has_includes() || has_nested_repo_pruning {
-            remove_empty_directories(&mut root_node);
+        // --keep-empty-dirs opts out to preserve the full directory layout.
+        if !args.keep_empty_dirs {
+            if spec.has_includes() || has_nested_repo_pruning {
+                remove_empty_directories(&mut root_node);
+            } else {
+                // --exclude-path only empties the directories above it
+                for path in &spec.exclude_paths {
+                    let parents: Vec<&str> = path.split('/').collect();
+                    remove_emptied_parents(&mut root_node, &parents[..parents.len() - 1]);
+                }
+            }
         }
+
+        // Directory sizes and file counts are only known once the whole
+        // tree is built, and before --collapse-below hides the contents
+        match sort.mode {
+            SortMode::Dirsize => {
+                sort_dirs_by_size(&mut root_node, &sort);
+            }
+            SortMode::Filecount => {
+                sort_dirs_by_file_count(&mut root_node, &sort);
+            }
+            SortMode::Name | SortMode::Ext | SortMode::Natural => {}
+        }
+
+        if let Some(levels) = args.collapse_below {
+            collapse_below(&mut root_node, levels);
+        }
+    } else {
+        // Single-file target: render it as the only entry under its parent
+        // so renderers treat it as a file rather than a directory root
+        let parent = resolved_path
+            .parent()
+            .map(Path::to_path_buf)
+            .unwrap_or_default();
+        let parent_display_path = calculate_display_path(&parent, display_root);
+        let file_node = root_node;
+        root_node = Node::new(".".to_string(), parent, true).with_display_path(parent_display_path);
+        root_node.children.push(file_node);
     }
 
-    Ok(root_node)
+    Ok((root_node, report))
 }
 
 fn build_tree_from_map(
     parent: &mut Node,
     nodes_map: &HashMap<PathBuf, Node>,
     base_path: &Path,
+    sort: &SortOptions,
 ) -> io::Result<()> {
     let mut direct_children: Vec<PathBuf> = Vec::new();
 
@@ -190,24 +371,15 @@ fn build_tree_from_map(
         }
     }
 
-    // Sort children: directories first, then files, alphabetically within each group
-    direct_children.sort_by(|a, b| {
-        let a_node = nodes_map.get(a).unwrap();
-        let b_node = nodes_map.get(b).unwrap();
-
-        match (a_node.is_dir, b_node.is_dir) {
-            (true, false) => std::cmp::Ordering::Less,
-            (false, true) => std::cmp::Ordering::Greater,
-            _ => a_node.name.cmp(&b_node.name),
-        }
-    });
+    // Sort children: directories first, then files, ordered by --sort within each group
+    direct_children.sort_by(|a, b| compare_nodes(&nodes_map[a], &nodes_map[b], sort));
 
     // Add children to parent and recursively build their subtrees
     for child_path in direct_children {
         if let Some(child_node) = nodes_map.get(&child_path) {
             let mut child = child_node.clone();
             if child.is_dir {
-                build_tree_from_map(&mut child, nodes_map, &child_path)?;
+                build_tree_from_map(&mut child, nodes_map, &child_path, sort)?;
             }
             parent.children.push(child);
         }
@@ -235,6 +407,61 @@ fn remove_empty_directories(node: &mut Node) {
         .retain(|child| !child.is_dir || !child.children.is_empty());
 }
 
+/// Remove the directories along `parents` (path components below `node`)
+/// that no longer have children, deepest first
+fn remove_emptied_parents(node: &mut Node, parents: &[&str]) {
+    let Some((first, rest)) = parents.split_first() else {
+        return;
+    };
+    if let Some(index) = node
+        .children
+        .iter()
+        .position(|child| child.is_dir && child.name == *first)
+    {
+        remove_emptied_parents(&mut node.children[index], rest);
+        if node.children[index].children.is_empty() {
+            node.children.remove(index);
+        }
+    }
+}
+
+/// Collapse the directories `levels` below `node` (--collapse-below):
+/// their children are dropped and counted in `collapsed`
+fn collapse_below(node: &mut Node, levels: usize) {
+    for child in node.children.iter_mut().filter(|c| c.is_dir) {
+        if levels > 1 {
+            collapse_below(child, levels - 1);
+        } else if !child.children.is_empty() {
+            child.collapsed = Some(count_entries(child));
+            child.children.clear();
+        }
+    }
+}
+
+/// Number of files and directories beneath `node`
+fn count_entries(node: &Node) -> usize {
+    node.children
+        .iter()
+        .map(|child| 1 + count_entries(child))
+        .sum()
+}
+
+/// Drop files not in `changed`, along with directories left without any
+fn retain_changed(node: &mut Node, changed: &HashSet<PathBuf>) {
+    for child in &mut node.children {
+        if child.is_dir {
+            retain_changed(child, changed);
+        }
+    }
+    node.children.retain(|child| {
+        if child.is_dir {
+            !child.children.is_empty()
+        } else {
+            changed.contains(&child.path)
+        }
+    });
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -267,8 +494,9 @@ mod tests {
         let spec = MatchSpec::new().with_include_ext(vec![".rs".to_string()]);
 
         let display_root = root.to_path_buf();
-        let tree = build_tree_with_spec(root.to_str().unwrap(), &args, &spec, root, &display_root)
-            .unwrap();
+        let (tree, _) =
+            build_tree_with_spec(root.to_str().unwrap(), &args, &spec, root, &display_root)
+                .unwrap();
 
         // Should have src directory
         let src = tree.children.iter().find(|n| n.name == "src");
@@ -308,8 +536,9 @@ mod tests {
         let spec = MatchSpec::new().with_gitignore(true);
 
         let display_root = root.to_path_buf();
-        let tree = build_tree_with_spec(root.to_str().unwrap(), &args, &spec, root, &display_root)
-            .unwrap();
+        let (tree, _) =
+            build_tree_with_spec(root.to_str().unwrap(), &args, &spec, root, &display_root)
+                .unwrap();
 
         // Should have src and data.txt, but not target or temp.tmp
         assert!(tree.children.iter().any(|n| n.name == "src"));
@@ -337,8 +566,9 @@ mod tests {
             MatchSpec::new().with_include_glob(vec!["src/**/*.rs".to_string(), "*.md".to_string()]);
 
         let display_root = root.to_path_buf();
-        let tree = build_tree_with_spec(root.to_str().unwrap(), &args, &spec, root, &display_root)
-            .unwrap();
+        let (tree, _) =
+            build_tree_with_spec(root.to_str().unwrap(), &args, &spec, root, &display_root)
+                .unwrap();
 
         // Should have README.md at root
         assert!(tree.children.iter().any(|n| n.name == "README.md"));
@@ -353,4 +583,106 @@ mod tests {
         let module = src.children.iter().find(|n| n.name == "module").unwrap();
         assert!(module.children.iter().any(|n| n.name == "lib.rs"));
     }
+
+    #[test]
+    fn test_walk_stops_when_cancelled_mid_walk() {
+        let temp_dir = TempDir::new().unwrap();
+        let root = temp_dir.path();
+        for i in 0..20 {
+            fs::write(root.join(format!("file{:02}.txt", i)), "x").unwrap();
+        }
+
+        let args = Args::parse_from(&["tree2md", root.to_str().unwrap()]);
+        let spec = MatchSpec::new();
+        let display_root = root.to_path_buf();
+
+        // Stand-in for a slow mount: the deadline passes after five entries
+        let mut visited = 0;
+        let mut should_stop = || {
+            visited += 1;
+            visited > 5
+        };
+        let (tree, report) = build_tree_until(
+            root.to_str().unwrap(),
+            &args,
+            &spec,
+            root,
+            &display_root,
+            None,
+            &mut should_stop,
+        )
+        .unwrap();
+
+        assert!(report.timed_out);
+        // The root itself is the first entry walked
+        assert_eq!(tree.children.len(), 4);
+
+        let (full, report) =
+            build_tree_with_spec(root.to_str().unwrap(), &args, &spec, root, &display_root)
+                .unwrap();
+        assert!(!report.timed_out);
+        assert_eq!(full.children.len(), 20);
+    }
+
+    fn wide_tree(root: &Path, dirs: usize, files_per_dir: usize) {
+        for d in 0..dirs {
+            let dir = root.join(format!("dir{:03}", d));
+            fs::create_dir_all(&dir).unwrap();
+            for f in 0..files_per_dir {
+                fs::write(dir.join(format!("file{:03}.txt", f)), "x").unwrap();
+            }
+        }
+    }
+
+    fn collect_nodes<'a>(node: &'a Node, out: &mut Vec<&'a Node>) {
+        for child in &node.children {
+            out.push(child);
+            collect_nodes(child, out);
+        }
+    }
+
+    #[test]
+    fn test_entry_paths_match_canonical_paths() {
+        let temp_dir = TempDir::new().unwrap();
+        let root = temp_dir.path();
+        wide_tree(root, 3, 4);
+        fs::create_dir_all(root.join("dir000/nested/deeper")).unwrap();
+        fs::write(root.join("dir000/nested/deeper/leaf.rs"), "fn leaf() {}").unwrap();
+
+        // Relative targets are resolved the same way as absolute ones
+        let target = root.join("dir000/..");
+        let args = Args::parse_from(["tree2md", target.to_str().unwrap()]);
+        let (tree, _) = build_tree(target.to_str().unwrap(), &args, root, root).unwrap();
+
+        let mut nodes = Vec::new();
+        collect_nodes(&tree, &mut nodes);
+        assert_eq!(nodes.len(), 3 + 3 * 4 + 3);
+        for node in nodes {
+            assert_eq!(node.path, node.path.canonicalize().unwrap());
+            assert_eq!(node.is_dir, node.path.is_dir(), "{}", node.path.display());
+        }
+    }
+
+    /// Benchmark for walking a wide directory:
+    /// `cargo test --release bench_wide_directory -- --ignored --nocapture`
+    #[test]
+    #[ignore]
+    fn bench_wide_directory() {
+        let temp_dir = TempDir::new().unwrap();
+        let root = temp_dir.path();
+        wide_tree(root, 50, 400);
+
+        let args = Args::parse_from(["tree2md", root.to_str().unwrap()]);
+        let runs = 5;
+        let start = Instant::now();
+        for _ in 0..runs {
+            let (tree, _) = build_tree(root.to_str().unwrap(), &args, root, root).unwrap();
+            assert_eq!(tree.children.len(), 50);
+        }
+        println!(
+            "walked {} entries in {:?} per run",
+            50 * 401,
+            start.elapsed() / runs
+        );
+    }
 }
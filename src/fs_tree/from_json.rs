@@ -0,0 +1,172 @@
+//! Rebuild a tree from `--format json` output (--from-json), so it can be
+//! rendered again without walking the filesystem.
+
+use crate::fs_tree::node::{EmbeddedFile, Node};
+use serde_json::Value;
+use std::io::{self, Read};
+use std::path::{Component, Path, PathBuf};
+
+/// Read a JSON tree from `source`, or from stdin when it is `-`
+pub fn load_tree(source: &Path) -> io::Result<Node> {
+    let text = if source == Path::new("-") {
+        let mut text = String::new();
+        io::stdin().read_to_string(&mut text)?;
+        text
+    } else {
+        std::fs::read_to_string(source)?
+    };
+    parse_tree(&text)
+}
+
+/// Parse the nested tree written by the JSON renderer. Both the full and
+/// the --compact-json shapes are accepted; `stats` and other extra fields
+/// are ignored.
+pub fn parse_tree(text: &str) -> io::Result<Node> {
+    let value: Value =
+        serde_json::from_str(text).map_err(|e| invalid(format!("not valid JSON: {}", e)))?;
+    node_from_value(&value, true)
+}
+
+/// `path` as a relative path ending in `name`. The JSON may come from
+/// anywhere and paths end up in --export-dir file names, so absolute paths
+/// and `..` are refused.
+fn checked_path(path: &str, name: &str, is_root: bool) -> io::Result<PathBuf> {
+    let path = PathBuf::from(path);
+    if path
+        .components()
+        .any(|c| !matches!(c, Component::Normal(_) | Component::CurDir))
+    {
+        return Err(invalid(format!(
+            "path '{}' must be relative, without '..'",
+            path.display()
+        )));
+    }
+    if !is_root && path.file_name().and_then(|n| n.to_str()) != Some(name) {
+        return Err(invalid(format!(
+            "path '{}' does not end with the node name '{}'",
+            path.display(),
+            name
+        )));
+    }
+    Ok(path)
+}
+
+fn node_from_value(value: &Value, is_root: bool) -> io::Result<Node> {
+    let name = value
+        .get("name")
+        .and_then(Value::as_str)
+        .ok_or_else(|| invalid("a node is missing its \"name\"".to_string()))?;
+    let display_path = match value.get("path").and_then(Value::as_str) {
+        None | Some(".") if is_root => PathBuf::new(),
+        None => return Err(invalid(format!("node '{}' is missing its \"path\"", name))),
+        Some(path) => checked_path(path, name, is_root)?,
+    };
+    let is_dir = match value.get("type").and_then(Value::as_str) {
+        Some("dir") => true,
+        Some("file") => false,
+        other => {
+            return Err(invalid(format!(
+                "node '{}' has unknown type {:?}",
+                name,
+                other.unwrap_or("(none)")
+            )))
+        }
+    };
+
+    // Paths stay relative: nothing under a JSON tree is read from disk
+    let mut node =
+        Node::new(name.to_string(), display_path.clone(), is_dir).with_display_path(display_path);
+    if is_dir {
+        node.collapsed = value
+            .get("collapsed")
+            .and_then(Value::as_u64)
+            .map(|n| n as usize);
+        if let Some(children) = value.get("children").and_then(Value::as_array) {
+            node.children = children
+                .iter()
+                .map(|child| node_from_value(child, false))
+                .collect::<io::Result<_>>()?;
+        }
+    } else {
+        node.embedded = Some(EmbeddedFile {
+            lines: value
+                .get("lines")
+                .and_then(Value::as_u64)
+                .map(|n| n as usize),
+            size: value.get("size").and_then(Value::as_u64).unwrap_or(0),
+            content: value
+                .get("content")
+                .and_then(Value::as_str)
+                .map(str::to_string),
+        });
+    }
+    Ok(node)
+}
+
+fn invalid(message: String) -> io::Error {
+    io::Error::new(
+        io::ErrorKind::InvalidData,
+        format!("invalid JSON tree: {}", message),
+    )
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_parse_tree() {
+        let root = parse_tree(
+            r#"{"name": ".", "path": ".", "type": "dir", "children": [
+                {"name": "src", "path": "src", "type": "dir", "children": [
+                    {"name": "main.rs", "path": "src/main.rs", "type": "file",
+                     "lines": 1, "size": 13, "content": "fn main() {}\n"}
+                ]},
+                {"name": "logo.png", "path": "logo.png", "type": "file", "size": 42}
+            ]}"#,
+        )
+        .unwrap();
+
+        assert!(root.is_dir);
+        assert_eq!(root.display_path, PathBuf::new());
+        let main = &root.children[0].children[0];
+        assert_eq!(main.display_path, PathBuf::from("src/main.rs"));
+        assert_eq!(
+            main.embedded,
+            Some(EmbeddedFile {
+                lines: Some(1),
+                size: 13,
+                content: Some("fn main() {}\n".to_string()),
+            })
+        );
+        let logo = root.children[1].embedded.as_ref().unwrap();
+        assert_eq!(
+            (logo.lines, logo.size, logo.content.as_deref()),
+            (None, 42, None)
+        );
+    }
+
+    #[test]
+    fn test_parse_tree_rejects_unknown_type() {
+        let err = parse_tree(r#"{"name": "x", "type": "link"}"#).unwrap_err();
+        assert_eq!(err.kind(), io::ErrorKind::InvalidData);
+        assert!(err.to_string().contains("unknown type"), "{}", err);
+    }
+
+    #[test]
+    fn test_parse_tree_rejects_paths_outside_the_tree() {
+        let tree = |path: &str| {
+            format!(
+                r#"{{"name": ".", "path": ".", "type": "dir", "children": [
+                    {{"name": "x", "path": "{}", "type": "file", "size": 1}}
+                ]}}"#,
+                path
+            )
+        };
+        assert!(parse_tree(&tree("src/x")).is_ok());
+        for path in ["/tmp/x", "../../x", "src/../x", "src/y"] {
+            let err = parse_tree(&tree(path)).unwrap_err();
+            assert_eq!(err.kind(), io::ErrorKind::InvalidData, "{}", path);
+        }
+    }
+}
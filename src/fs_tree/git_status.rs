@@ -0,0 +1,127 @@
+use std::collections::{HashMap, HashSet};
+use std::path::{Path, PathBuf};
+use std::process::Command;
+
+/// Working-tree status of the repository containing the rendered root,
+/// as reported by `git status --porcelain` (used for --git-status).
+#[derive(Debug, Default)]
+pub struct GitStatus {
+    /// Status code per changed path (absolute)
+    entries: HashMap<PathBuf, char>,
+    /// Untracked directories, reported by git as a single entry
+    untracked_dirs: Vec<PathBuf>,
+}
+
+impl GitStatus {
+    /// Run `git status` in `root`. Returns None when git is unavailable or
+    /// `root` is not inside a work tree.
+    pub fn load(root: &Path) -> Option<Self> {
+        let toplevel = git_toplevel(root)?;
+        let porcelain = git_output(
+            root,
+            &["status", "--porcelain", "-z", "--untracked-files=normal"],
+        )?;
+        Some(Self::parse(&toplevel, &String::from_utf8_lossy(&porcelain)))
+    }
+
+    /// Parse `git status --porcelain -z` output whose paths are relative
+    /// to `toplevel`.
+    fn parse(toplevel: &Path, porcelain: &str) -> Self {
+        let mut status = Self::default();
+        let mut records = porcelain.split('\0');
+        while let Some(record) = records.next() {
+            let (Some(xy), Some(path)) = (record.get(..2), record.get(3..)) else {
+                continue;
+            };
+            let mut codes = xy.chars();
+            let (x, y) = (codes.next().unwrap_or(' '), codes.next().unwrap_or(' '));
+            // Renames and copies are followed by their original path
+            if matches!(x, 'R' | 'C') {
+                records.next();
+            }
+            if x == '!' {
+                continue;
+            }
+            let code = if x == ' ' { y } else { x };
+
+            if let Some(dir) = path.strip_suffix('/') {
+                status.untracked_dirs.push(toplevel.join(dir));
+            } else {
+                status.entries.insert(toplevel.join(path), code);
+            }
+        }
+        status
+    }
+
+    /// Status code for `path`: `M` modified, `A` added, `D` deleted,
+    /// `R` renamed, `?` untracked, ...
+    pub fn code_for(&self, path: &Path) -> Option<char> {
+        if let Some(&code) = self.entries.get(path) {
+            return Some(code);
+        }
+        self.untracked_dirs
+            .iter()
+            .any(|dir| path.starts_with(dir))
+            .then_some('?')
+    }
+}
+
+/// Files changed in the git revision `range` (e.g. `main..HEAD`), as
+/// absolute paths (used for --changed-in). Deleted files are left out and
+/// renamed files appear at their new path. Returns None when `root` is not
+/// inside a work tree or git rejects the range.
+pub fn changed_files(root: &Path, range: &str) -> Option<HashSet<PathBuf>> {
+    let toplevel = git_toplevel(root)?;
+    let names = git_output(
+        root,
+        &["diff", "--name-only", "-z", "--diff-filter=d", range, "--"],
+    )?;
+    Some(
+        String::from_utf8_lossy(&names)
+            .split('\0')
+            .filter(|name| !name.is_empty())
+            .map(|name| toplevel.join(name))
+            .collect(),
+    )
+}
+
+/// Canonical root of the work tree containing `dir`
+fn git_toplevel(dir: &Path) -> Option<PathBuf> {
+    let toplevel = git_output(dir, &["rev-parse", "--show-toplevel"])?;
+    let toplevel = PathBuf::from(String::from_utf8(toplevel).ok()?.trim_end());
+    Some(toplevel.canonicalize().unwrap_or(toplevel))
+}
+
+/// Stdout of `git -C dir ARGS`, or None if git is missing or fails
+pub fn git_output(dir: &Path, args: &[&str]) -> Option<Vec<u8>> {
+    let output = Command::new("git")
+        .arg("-C")
+        .arg(dir)
+        .args(args)
+        .output()
+        .ok()?;
+    output.status.success().then_some(output.stdout)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_parse_porcelain() {
+        let top = Path::new("/repo");
+        let status = GitStatus::parse(
+            top,
+            " M src/main.rs\0A  new.rs\0R  renamed.rs\0old.rs\0?? scratch/\0?? notes.txt\0",
+        );
+
+        assert_eq!(status.code_for(&top.join("src/main.rs")), Some('M'));
+        assert_eq!(status.code_for(&top.join("new.rs")), Some('A'));
+        assert_eq!(status.code_for(&top.join("renamed.rs")), Some('R'));
+        assert_eq!(status.code_for(&top.join("old.rs")), None);
+        assert_eq!(status.code_for(&top.join("notes.txt")), Some('?'));
+        assert_eq!(status.code_for(&top.join("scratch")), Some('?'));
+        assert_eq!(status.code_for(&top.join("scratch/a/b.rs")), Some('?'));
+        assert_eq!(status.code_for(&top.join("src/lib.rs")), None);
+    }
+}
@@ -3,11 +3,15 @@ use crate::content::io;
 use std::fs::File;
 use std::io::{BufRead, BufReader};
 use std::path::Path;
+use std::time::Duration;
 
 /// Line of Code counter
+#[derive(Clone)]
 pub struct LocCounter {
     mode: LocMode,
     max_file_size: u64,
+    /// --read-timeout: give up on a file that takes longer to count
+    read_timeout: Option<Duration>,
 }
 
 impl LocCounter {
@@ -16,14 +20,33 @@ impl LocCounter {
             mode,
             // Don't count files larger than 10MB
             max_file_size: 10 * 1024 * 1024,
+            read_timeout: None,
         }
     }
 
+    /// Leave a file uncounted when reading it takes longer than `timeout`
+    pub fn with_read_timeout(mut self, timeout: Option<Duration>) -> Self {
+        self.read_timeout = timeout;
+        self
+    }
+
+    /// Whether --loc counting is on at all
+    pub fn is_enabled(&self) -> bool {
+        self.mode != LocMode::Off
+    }
+
     /// Count lines in a file
     pub fn count_lines(&self, path: &Path) -> Option<usize> {
         if self.mode == LocMode::Off {
             return None;
         }
+        if let Some(timeout) = self.read_timeout {
+            let counter = self.clone().with_read_timeout(None);
+            let path = path.to_path_buf();
+            return io::run_with_timeout(timeout, move || Ok(counter.count_lines(&path)))
+                .ok()
+                .flatten();
+        }
 
         // Check if file exists and is readable
         if !path.is_file() {
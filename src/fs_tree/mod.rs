@@ -1,9 +1,13 @@
 pub mod build;
+pub mod from_json;
+pub mod git_status;
 pub mod loc;
 pub mod node;
 pub mod progress;
+pub mod sort;
 
 pub use build::build_tree;
+pub use git_status::GitStatus;
 pub use loc::LocCounter;
 pub use node::Node;
 pub use progress::ProgressTracker;
@@ -7,6 +7,23 @@ pub struct Node {
     pub display_path: PathBuf,
     pub is_dir: bool,
     pub children: Vec<Node>,
+    /// Where a symlinked file points, as written in the link (--follow-symlinks)
+    pub link_target: Option<PathBuf>,
+    /// Entries hidden beneath a directory collapsed by --collapse-below
+    pub collapsed: Option<usize>,
+    /// Metadata and contents carried by a --from-json tree; the file is
+    /// never read from disk
+    pub embedded: Option<EmbeddedFile>,
+}
+
+/// A file described by a JSON tree rather than found on disk
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct EmbeddedFile {
+    pub lines: Option<usize>,
+    pub size: u64,
+    /// None when the JSON tree was written without contents (or the file
+    /// had none to show)
+    pub content: Option<String>,
 }
 
 impl Node {
@@ -18,6 +35,9 @@ impl Node {
             display_path,
             is_dir,
             children: Vec::new(),
+            link_target: None,
+            collapsed: None,
+            embedded: None,
         }
     }
 
@@ -25,4 +45,9 @@ impl Node {
         self.display_path = display_path;
         self
     }
+
+    pub fn with_link_target(mut self, link_target: Option<PathBuf>) -> Self {
+        self.link_target = link_target;
+        self
+    }
 }
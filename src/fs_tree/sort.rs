@@ -0,0 +1,372 @@
+use super::node::Node;
+use crate::cli::{Args, SortMode};
+use std::cmp::Ordering;
+use std::path::Path;
+
+/// How siblings are ordered within a directory
+#[derive(Debug, Clone, PartialEq)]
+pub struct SortOptions {
+    pub mode: SortMode,
+    /// Compare names case-insensitively (--sort-ignorecase)
+    pub ignore_case: bool,
+    /// Reverse the order among directories and among files (--sort-reverse)
+    pub reverse: bool,
+}
+
+impl SortOptions {
+    pub fn from_args(args: &Args) -> Self {
+        Self {
+            mode: args.sort.clone(),
+            ignore_case: args.sort_ignorecase,
+            reverse: args.sort_reverse,
+        }
+    }
+
+    /// Apply --sort-reverse to an ordering between siblings of the same kind
+    fn directed(&self, ordering: Ordering) -> Ordering {
+        if self.reverse {
+            ordering.reverse()
+        } else {
+            ordering
+        }
+    }
+
+    /// Compare two names, falling back to exact order for case-insensitive
+    /// or natural-order ties
+    fn compare_names(&self, a: &str, b: &str) -> Ordering {
+        let compare = |a: &str, b: &str| {
+            if self.mode == SortMode::Natural {
+                natural_cmp(a, b)
+            } else {
+                a.cmp(b)
+            }
+        };
+        if self.ignore_case {
+            compare(&a.to_lowercase(), &b.to_lowercase()).then_with(|| a.cmp(b))
+        } else {
+            compare(a, b).then_with(|| a.cmp(b))
+        }
+    }
+}
+
+/// Compare two sibling nodes: directories first, then by the chosen mode.
+pub fn compare_nodes(a: &Node, b: &Node, options: &SortOptions) -> Ordering {
+    match (a.is_dir, b.is_dir) {
+        (true, false) => Ordering::Less,
+        (false, true) => Ordering::Greater,
+        (false, false) if options.mode == SortMode::Ext => options.directed(
+            options
+                .compare_names(extension(&a.name), extension(&b.name))
+                .then_with(|| options.compare_names(&a.name, &b.name)),
+        ),
+        _ => options.directed(options.compare_names(&a.name, &b.name)),
+    }
+}
+
+/// Reorder directories at every level by their aggregate size, largest
+/// first (--sort dirsize). Files keep their name order. Returns the total
+/// size of `node` in bytes.
+pub fn sort_dirs_by_size(node: &mut Node, options: &SortOptions) -> u64 {
+    sort_dirs_by_total(node, options, &|file| {
+        std::fs::metadata(&file.path).map(|m| m.len()).unwrap_or(0)
+    })
+}
+
+/// Reorder directories at every level by their recursive file count, most
+/// files first (--sort filecount). Files keep their name order. Returns the
+/// number of files under `node`.
+pub fn sort_dirs_by_file_count(node: &mut Node, options: &SortOptions) -> u64 {
+    sort_dirs_by_total(node, options, &|_| 1)
+}
+
+/// Reorder directories at every level by the sum of `weight` over their
+/// files, largest first, breaking ties by name. Returns the total for `node`.
+fn sort_dirs_by_total(
+    node: &mut Node,
+    options: &SortOptions,
+    weight: &dyn Fn(&Node) -> u64,
+) -> u64 {
+    if !node.is_dir {
+        return weight(node);
+    }
+
+    let mut weighted: Vec<(u64, Node)> = std::mem::take(&mut node.children)
+        .into_iter()
+        .map(|mut child| (sort_dirs_by_total(&mut child, options, weight), child))
+        .collect();
+    let total = weighted.iter().map(|(total, _)| total).sum();
+
+    weighted.sort_by(|(total_a, a), (total_b, b)| match (a.is_dir, b.is_dir) {
+        (true, true) => options.directed(
+            total_b
+                .cmp(total_a)
+                .then_with(|| options.compare_names(&a.name, &b.name)),
+        ),
+        _ => compare_nodes(a, b, options),
+    });
+    node.children = weighted.into_iter().map(|(_, child)| child).collect();
+    total
+}
+
+/// Compare names with runs of ASCII digits ordered by numeric value, so
+/// "img2" sorts before "img10". Equal numbers with more leading zeros sort
+/// later ("a1" < "a01"); everything else compares by character.
+pub fn natural_cmp(a: &str, b: &str) -> Ordering {
+    let (mut a, mut b) = (a, b);
+    loop {
+        let (Some(ca), Some(cb)) = (a.chars().next(), b.chars().next()) else {
+            return a.len().cmp(&b.len());
+        };
+        if ca.is_ascii_digit() && cb.is_ascii_digit() {
+            let (num_a, rest_a) = split_digits(a);
+            let (num_b, rest_b) = split_digits(b);
+            let (trim_a, trim_b) = (num_a.trim_start_matches('0'), num_b.trim_start_matches('0'));
+            let ordering = trim_a
+                .len()
+                .cmp(&trim_b.len())
+                .then_with(|| trim_a.cmp(trim_b))
+                .then_with(|| num_a.len().cmp(&num_b.len()));
+            if ordering != Ordering::Equal {
+                return ordering;
+            }
+            (a, b) = (rest_a, rest_b);
+        } else {
+            if ca != cb {
+                return ca.cmp(&cb);
+            }
+            (a, b) = (&a[ca.len_utf8()..], &b[cb.len_utf8()..]);
+        }
+    }
+}
+
+/// Split `s` after its leading run of ASCII digits
+fn split_digits(s: &str) -> (&str, &str) {
+    let end = s.find(|c: char| !c.is_ascii_digit()).unwrap_or(s.len());
+    s.split_at(end)
+}
+
+/// File extension without the dot; empty for names without one
+fn extension(name: &str) -> &str {
+    Path::new(name)
+        .extension()
+        .and_then(|e| e.to_str())
+        .unwrap_or("")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::path::PathBuf;
+
+    fn node(name: &str, is_dir: bool) -> Node {
+        Node::new(name.to_string(), PathBuf::from(name), is_dir)
+    }
+
+    fn sorted(mut nodes: Vec<Node>, mode: SortMode) -> Vec<String> {
+        let options = SortOptions {
+            mode,
+            ignore_case: false,
+            reverse: false,
+        };
+        nodes.sort_by(|a, b| compare_nodes(a, b, &options));
+        nodes.into_iter().map(|n| n.name).collect()
+    }
+
+    #[test]
+    fn test_sort_by_name_dirs_first() {
+        let nodes = vec![
+            node("b.go", false),
+            node("zeta", true),
+            node("a.md", false),
+            node("alpha", true),
+        ];
+        assert_eq!(
+            sorted(nodes, SortMode::Name),
+            vec!["alpha", "zeta", "a.md", "b.go"]
+        );
+    }
+
+    #[test]
+    fn test_sort_by_extension() {
+        let nodes = vec![
+            node("b.md", false),
+            node("Makefile", false),
+            node("z.go", false),
+            node("docs", true),
+            node("a.md", false),
+            node("a.go", false),
+        ];
+        assert_eq!(
+            sorted(nodes, SortMode::Ext),
+            vec!["docs", "Makefile", "a.go", "z.go", "a.md", "b.md"]
+        );
+    }
+
+    #[test]
+    fn test_sort_dirs_by_size() {
+        let temp = tempfile::TempDir::new().unwrap();
+        let file = |name: &str, bytes: usize| {
+            let path = temp.path().join(name);
+            std::fs::write(&path, "x".repeat(bytes)).unwrap();
+            Node::new(name.to_string(), path, false)
+        };
+        let dir = |name: &str, children: Vec<Node>| {
+            let mut dir = node(name, true);
+            dir.children = children;
+            dir
+        };
+
+        let mut root = dir(
+            ".",
+            vec![
+                dir("small", vec![file("s.txt", 10)]),
+                file("z.txt", 500),
+                dir("big", vec![file("b1.txt", 300), file("b2.txt", 300)]),
+                file("a.txt", 1),
+                dir("medium", vec![dir("inner", vec![file("m.txt", 400)])]),
+            ],
+        );
+        let options = SortOptions {
+            mode: SortMode::Dirsize,
+            ignore_case: false,
+            reverse: false,
+        };
+
+        assert_eq!(sort_dirs_by_size(&mut root, &options), 1511);
+        let names: Vec<&str> = root.children.iter().map(|n| n.name.as_str()).collect();
+        assert_eq!(names, vec!["big", "medium", "small", "a.txt", "z.txt"]);
+    }
+
+    #[test]
+    fn test_sort_dirs_by_size_ties_break_by_name() {
+        let temp = tempfile::TempDir::new().unwrap();
+        let sized_dir = |name: &str| {
+            let path = temp.path().join(format!("{}.txt", name));
+            std::fs::write(&path, "x".repeat(100)).unwrap();
+            let mut dir = node(name, true);
+            dir.children = vec![Node::new(format!("{}.txt", name), path, false)];
+            dir
+        };
+        let equal_file = |name: &str| {
+            let path = temp.path().join(name);
+            std::fs::write(&path, "x".repeat(100)).unwrap();
+            Node::new(name.to_string(), path, false)
+        };
+
+        let mut root = node(".", true);
+        root.children = vec![
+            equal_file("c.txt"),
+            sized_dir("delta"),
+            equal_file("a.txt"),
+            sized_dir("alpha"),
+            sized_dir("charlie"),
+            equal_file("b.txt"),
+            sized_dir("bravo"),
+        ];
+        let options = SortOptions {
+            mode: SortMode::Dirsize,
+            ignore_case: false,
+            reverse: false,
+        };
+
+        sort_dirs_by_size(&mut root, &options);
+        let names: Vec<&str> = root.children.iter().map(|n| n.name.as_str()).collect();
+        assert_eq!(
+            names,
+            vec!["alpha", "bravo", "charlie", "delta", "a.txt", "b.txt", "c.txt"]
+        );
+    }
+
+    #[test]
+    fn test_natural_cmp() {
+        assert_eq!(natural_cmp("img2.png", "img10.png"), Ordering::Less);
+        assert_eq!(natural_cmp("img10.png", "img9.png"), Ordering::Greater);
+        assert_eq!(natural_cmp("v1.10.0", "v1.9.3"), Ordering::Greater);
+        assert_eq!(natural_cmp("a1", "a01"), Ordering::Less);
+        assert_eq!(natural_cmp("a", "a1"), Ordering::Less);
+        assert_eq!(natural_cmp("x007", "x7b"), Ordering::Greater);
+        assert_eq!(natural_cmp("same", "same"), Ordering::Equal);
+    }
+
+    #[test]
+    fn test_sort_natural() {
+        let nodes = vec![
+            node("img10.png", false),
+            node("img2.png", false),
+            node("chapter10", true),
+            node("img1.png", false),
+            node("chapter9", true),
+        ];
+        assert_eq!(
+            sorted(nodes, SortMode::Natural),
+            vec!["chapter9", "chapter10", "img1.png", "img2.png", "img10.png"]
+        );
+    }
+
+    #[test]
+    fn test_sort_ignore_case() {
+        let options = SortOptions {
+            mode: SortMode::Name,
+            ignore_case: true,
+            reverse: false,
+        };
+        let mut nodes = vec![
+            node("Zebra.txt", false),
+            node("apple.txt", false),
+            node("Apple.txt", false),
+            node("banana.txt", false),
+        ];
+        nodes.sort_by(|a, b| compare_nodes(a, b, &options));
+        let names: Vec<&str> = nodes.iter().map(|n| n.name.as_str()).collect();
+        assert_eq!(
+            names,
+            vec!["Apple.txt", "apple.txt", "banana.txt", "Zebra.txt"]
+        );
+    }
+
+    #[test]
+    fn test_sort_dirs_by_file_count() {
+        let dir = |name: &str, children: Vec<Node>| {
+            let mut dir = node(name, true);
+            dir.children = children;
+            dir
+        };
+        let files = |n: usize| {
+            (0..n)
+                .map(|i| node(&format!("f{}.txt", i), false))
+                .collect()
+        };
+
+        let mut root = dir(
+            ".",
+            vec![
+                dir("one", files(1)),
+                node("b.txt", false),
+                dir("nested", vec![dir("inner", files(2)), node("x.txt", false)]),
+                node("a.txt", false),
+                dir("many", files(5)),
+                dir("also_one", files(1)),
+            ],
+        );
+        let mut options = SortOptions {
+            mode: SortMode::Filecount,
+            ignore_case: false,
+            reverse: false,
+        };
+
+        assert_eq!(sort_dirs_by_file_count(&mut root, &options), 12);
+        let names: Vec<&str> = root.children.iter().map(|n| n.name.as_str()).collect();
+        assert_eq!(
+            names,
+            vec!["many", "nested", "also_one", "one", "a.txt", "b.txt"]
+        );
+
+        options.reverse = true;
+        sort_dirs_by_file_count(&mut root, &options);
+        let names: Vec<&str> = root.children.iter().map(|n| n.name.as_str()).collect();
+        assert_eq!(
+            names,
+            vec!["one", "also_one", "nested", "many", "b.txt", "a.txt"]
+        );
+    }
+}
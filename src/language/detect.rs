@@ -44,6 +44,16 @@ pub static LANG_BY_EXT: Lazy<HashMap<&'static str, Lang>> = Lazy::new(|| {
     m
 });
 
+/// Every recognized extension with its language name, sorted by extension
+pub fn known_languages() -> Vec<(&'static str, &'static str)> {
+    let mut langs: Vec<_> = LANG_BY_EXT
+        .iter()
+        .map(|(ext, lang)| (*ext, lang.name))
+        .collect();
+    langs.sort_unstable();
+    langs
+}
+
 pub fn detect_lang(filename: &str) -> Option<&'static Lang> {
     let ext = Path::new(filename)
         .extension()
@@ -67,6 +77,14 @@ mod tests {
         assert_eq!(detect_lang("TEST.RS").map(|l| l.name), Some("rust"));
     }
 
+    #[test]
+    fn test_known_languages_sorted() {
+        let langs = known_languages();
+        assert_eq!(langs.len(), LANG_BY_EXT.len());
+        assert!(langs.windows(2).all(|w| w[0].0 < w[1].0));
+        assert!(langs.contains(&("yml", "yaml")));
+    }
+
     #[test]
     fn test_lang_equality() {
         let lang1 = &LANG_BY_EXT["rs"];
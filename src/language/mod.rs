@@ -1,3 +1,4 @@
 pub mod detect;
+pub mod sniff;
 
-pub use detect::detect_lang;
+pub use detect::{detect_lang, known_languages};
@@ -0,0 +1,182 @@
+use super::detect::{Lang, LANG_BY_EXT};
+use super::detect_lang;
+use crate::cli::Args;
+use crate::content::io::run_with_timeout;
+use std::fs::File;
+use std::io::{self, Read, Seek, SeekFrom};
+use std::path::Path;
+use std::time::Duration;
+
+/// Number of lines at each end of a file searched for a modeline (vim's default)
+const MODELINE_LINES: usize = 5;
+
+/// Bytes read from each end of a file when sniffing
+const SNIFF_BYTES: u64 = 4096;
+
+/// Filetype names used in modelines that aren't an extension or language name
+const FILETYPE_ALIASES: &[(&str, &str)] =
+    &[("bash", "shell"), ("zsh", "shell"), ("js", "javascript")];
+
+/// Whether file contents are read for modelines (--sniff-content)
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum Sniff {
+    Off,
+    /// Sniff, giving up on a file after --read-timeout, if set
+    On(Option<Duration>),
+}
+
+impl Sniff {
+    pub fn from_args(args: &Args) -> Self {
+        if args.sniff_content {
+            Sniff::On(args.read_timeout)
+        } else {
+            Sniff::Off
+        }
+    }
+}
+
+/// Detect a file's language from its name, falling back to editor
+/// modelines in its contents under --sniff-content.
+pub fn detect_file_lang(path: &Path, sniff: Sniff) -> Option<&'static Lang> {
+    let name = path.file_name()?.to_string_lossy();
+    detect_lang(&name).or_else(|| {
+        let Sniff::On(timeout) = sniff else {
+            return None;
+        };
+        // Only sniff regular files: opening a FIFO would block
+        if !path.is_file() {
+            return None;
+        }
+        let text = match timeout {
+            Some(timeout) => {
+                let path = path.to_path_buf();
+                run_with_timeout(timeout, move || read_ends(&path))
+            }
+            None => read_ends(path),
+        };
+        text.ok().and_then(|text| sniff_lang(&text))
+    })
+}
+
+/// Find a vim or emacs modeline in the first or last few lines of
+/// `content` and map its filetype to a language.
+pub fn sniff_lang(content: &str) -> Option<&'static Lang> {
+    let lines: Vec<&str> = content.lines().collect();
+    let tail_start = lines
+        .len()
+        .saturating_sub(MODELINE_LINES)
+        .max(MODELINE_LINES);
+    lines
+        .iter()
+        .take(MODELINE_LINES)
+        .chain(lines.iter().skip(tail_start))
+        .filter_map(|line| vim_filetype(line).or_else(|| emacs_mode(line)))
+        .find_map(|filetype| lang_by_name(&filetype))
+}
+
+/// Filetype from a vim modeline, e.g. `# vim: set ft=yaml:` or `vi: filetype=sh`
+fn vim_filetype(line: &str) -> Option<String> {
+    let start = ["vim:", "vi:", "ex:"]
+        .iter()
+        .filter_map(|marker| line.find(marker).map(|i| i + marker.len()))
+        .min()?;
+    line[start..]
+        .split(|c: char| c == ':' || c.is_whitespace())
+        .find_map(|option| {
+            option
+                .strip_prefix("ft=")
+                .or_else(|| option.strip_prefix("filetype="))
+        })
+        .map(str::to_string)
+}
+
+/// Mode from an emacs modeline, e.g. `-*- mode: yaml -*-` or `-*- yaml -*-`
+fn emacs_mode(line: &str) -> Option<String> {
+    let start = line.find("-*-")? + 3;
+    let end = start + line[start..].find("-*-")?;
+    let vars = line[start..end].trim();
+    if !vars.contains(':') {
+        return Some(vars.to_string());
+    }
+    vars.split(';').find_map(|var| {
+        let (key, value) = var.split_once(':')?;
+        (key.trim().eq_ignore_ascii_case("mode")).then(|| value.trim().to_string())
+    })
+}
+
+/// Language for a modeline filetype: an extension ("yml"), a language
+/// name ("python"), or an alias ("bash")
+fn lang_by_name(filetype: &str) -> Option<&'static Lang> {
+    let filetype = filetype.to_lowercase();
+    let filetype = FILETYPE_ALIASES
+        .iter()
+        .find(|(alias, _)| *alias == filetype)
+        .map_or(filetype.as_str(), |(_, name)| name);
+    LANG_BY_EXT
+        .get(filetype)
+        .or_else(|| LANG_BY_EXT.values().find(|lang| lang.name == filetype))
+}
+
+/// The first and last few KiB of a file, which is where modelines live
+fn read_ends(path: &Path) -> io::Result<String> {
+    let mut file = File::open(path)?;
+    let len = file.metadata()?.len();
+
+    let mut buf = Vec::new();
+    (&mut file).take(SNIFF_BYTES).read_to_end(&mut buf)?;
+    if len > SNIFF_BYTES * 2 {
+        buf.push(b'\n');
+        file.seek(SeekFrom::End(-(SNIFF_BYTES as i64)))?;
+        file.read_to_end(&mut buf)?;
+    } else if len > SNIFF_BYTES {
+        file.read_to_end(&mut buf)?;
+    }
+    Ok(String::from_utf8_lossy(&buf).into_owned())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn sniffed(content: &str) -> Option<&'static str> {
+        sniff_lang(content).map(|l| l.name)
+    }
+
+    #[test]
+    fn test_vim_modelines() {
+        assert_eq!(sniffed("# vim: set ft=yaml:\nkey: value\n"), Some("yaml"));
+        assert_eq!(sniffed("key = 1\n# vi: filetype=toml\n"), Some("toml"));
+        assert_eq!(
+            sniffed("echo hi\n# vim: ts=4 sw=4 ft=bash\n"),
+            Some("shell")
+        );
+    }
+
+    #[test]
+    fn test_emacs_modelines() {
+        assert_eq!(
+            sniffed("# -*- mode: python; coding: utf-8 -*-\n"),
+            Some("python")
+        );
+        assert_eq!(sniffed("# -*- yaml -*-\na: 1\n"), Some("yaml"));
+    }
+
+    #[test]
+    fn test_modeline_only_at_file_ends() {
+        let mut content = String::from("start\n");
+        for _ in 0..20 {
+            content.push_str("filler\n");
+        }
+        let middle = content.clone() + "# vim: set ft=yaml:\n" + &content;
+        assert_eq!(sniffed(&middle), None);
+
+        let tail = content + "# vim: set ft=yaml:\n";
+        assert_eq!(sniffed(&tail), Some("yaml"));
+    }
+
+    #[test]
+    fn test_unknown_filetype() {
+        assert_eq!(sniffed("# vim: set ft=cobol:\n"), None);
+        assert_eq!(sniffed("no modeline here\n"), None);
+    }
+}
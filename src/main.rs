@@ -10,10 +10,9 @@ mod safety;
 mod terminal;
 mod util;
 
-use clap::Parser;
-use cli::Args;
+use cli::{Args, FormatMode};
 use fs_tree::{build_tree, ProgressTracker};
-use std::io;
+use std::io::{self, Write};
 use std::path::Path;
 use terminal::animation::AnimationRunner;
 use terminal::capabilities::TerminalCapabilities;
@@ -36,18 +35,53 @@ fn main() -> io::Result<()> {
     // Restore default SIGPIPE behavior so piping to head/less doesn't panic
     reset_sigpipe();
 
-    let args = Args::parse();
+    let args = Args::parse_with_env();
 
-    // Determine display root
-    let display_root = Path::new(&args.target)
+    if args.list_languages {
+        for (ext, name) in language::known_languages() {
+            println!("{:<8} {}", format!(".{}", ext), name);
+        }
+        return Ok(());
+    }
+
+    // Determine display root (a single-file target is displayed relative to its parent)
+    let mut display_root = Path::new(&args.target)
         .canonicalize()
         .unwrap_or_else(|_| std::path::PathBuf::from(&args.target));
+    if display_root.is_file() {
+        if let Some(parent) = display_root.parent() {
+            display_root = parent.to_path_buf();
+        }
+    }
+
+    if args.summary_only && matches!(args.format, FormatMode::Toml | FormatMode::Xml) {
+        eprintln!("Error: --summary-only is not supported with --format toml or xml.");
+        std::process::exit(2);
+    }
+
+    if matcher::spec::parse_ext_list(&args.include_ext, &args.ext_alias).cancelled {
+        eprintln!(
+            "Error: --include-ext '{}' excludes every extension it lists, so nothing would match.",
+            args.include_ext.join(",")
+        );
+        std::process::exit(2);
+    }
 
     // Get the root path for pattern matching
     let root_path = Path::new(&args.target)
         .canonicalize()
         .unwrap_or_else(|_| Path::new(&args.target).to_path_buf());
 
+    // Refuse to scan $HOME or a filesystem root unless explicitly forced
+    if !args.force && args.from_json.is_none() && safety::is_sensitive_root(&root_path) {
+        eprintln!(
+            "Warning: '{}' is a home directory or filesystem root; scanning it is likely unintended.",
+            root_path.display()
+        );
+        eprintln!("Use --force to proceed anyway.");
+        std::process::exit(1);
+    }
+
     // Set up progress tracking and animation
     let detector = TerminalDetector::new();
     let is_tty = detector.is_tty();
@@ -61,26 +95,189 @@ fn main() -> io::Result<()> {
 
     let mut animation_runner = AnimationRunner::new(show_animation, progress_tracker.clone());
 
-    // Build tree using unified WalkBuilder approach
-    let root_node = build_tree(&args.target, &args, &root_path, &display_root)?;
+    // Build tree using unified WalkBuilder approach, or take it from --from-json
+    let (root_node, mut walk_report) = match &args.from_json {
+        Some(source) => match fs_tree::from_json::load_tree(source) {
+            Ok(root) => (root, fs_tree::build::WalkReport::default()),
+            Err(e) => {
+                eprintln!(
+                    "Error: failed to read JSON tree from '{}': {}",
+                    source.display(),
+                    e
+                );
+                std::process::exit(1);
+            }
+        },
+        None => build_tree(&args.target, &args, &root_path, &display_root)?,
+    };
 
     // Stop animation once tree is built
     animation_runner.complete();
 
+    // Notes for the end of the output, so a partial tree shows as one
+    // even when stderr is not seen
+    let mut notes = Vec::new();
+    if walk_report.timed_out {
+        if let Some(timeout) = args.timeout {
+            eprintln!(
+                "Warning: walk timed out after {:?}; the tree is partial.",
+                timeout
+            );
+            notes.push(format!(
+                "_[Partial tree: walk timed out after {:?}]_",
+                timeout
+            ));
+        }
+    }
+    if walk_report.dir_limit_reached {
+        if let Some(max) = args.max_total_dirs {
+            eprintln!(
+                "Warning: walk stopped at the --max-total-dirs limit of {} directories; the tree is partial.",
+                max
+            );
+            notes.push(format!(
+                "_[Partial tree: walk stopped at --max-total-dirs {}]_",
+                max
+            ));
+        }
+    }
+
+    // Write the sidecar summary before rendering the main output
+    if let Some(summary_path) = &args.summary_json {
+        let summary = output::summary::TreeSummary::from_tree(&root_node);
+        if let Err(e) = summary.write_json(summary_path) {
+            eprintln!(
+                "Error: failed to write summary to '{}': {}",
+                summary_path.display(),
+                e
+            );
+            std::process::exit(1);
+        }
+    }
+
+    // Export pages instead of printing
+    if let Some(export_dir) = &args.export_dir {
+        match render::export::export_dir(&root_node, &args, export_dir) {
+            Ok((pages, read_errors)) => {
+                eprintln!("Exported {} page(s) to '{}'", pages, export_dir.display());
+                walk_report.errors.extend(read_errors);
+            }
+            Err(e) => {
+                eprintln!(
+                    "Error: failed to export to '{}': {}",
+                    export_dir.display(),
+                    e
+                );
+                std::process::exit(1);
+            }
+        }
+        print_stderr_summary(&args, &root_node);
+        exit_on_scan_errors(&args, &walk_report);
+        return Ok(());
+    }
+
     // Create terminal capabilities and renderer
     let capabilities = TerminalCapabilities::new();
     let mut renderer = render::create_renderer(&args, &capabilities);
-    let output = renderer.render_tree(&root_node);
+    let prefix = args.prefix.as_deref().unwrap_or("");
+
+    // Splice into an existing document instead of printing
+    if let Some(update_path) = &args.update {
+        let begin = args
+            .content_prefix
+            .as_deref()
+            .unwrap_or(output::update::DEFAULT_BEGIN_MARKER);
+        let end = args
+            .content_suffix
+            .as_deref()
+            .unwrap_or(output::update::DEFAULT_END_MARKER);
+        let mut rendered = Vec::new();
+        renderer.write_tree(
+            &root_node,
+            &mut output::writer::LinePrefixWriter::new(&mut rendered, prefix),
+        )?;
+        let rendered = String::from_utf8_lossy(&rendered);
+        if let Err(e) = output::update::update_file(update_path, &rendered, begin, end) {
+            eprintln!("Error: failed to update '{}': {}", update_path.display(), e);
+            std::process::exit(1);
+        }
+        print_stderr_summary(&args, &root_node);
+        walk_report.errors.extend_from_slice(renderer.read_errors());
+        exit_on_scan_errors(&args, &walk_report);
+        return Ok(());
+    }
 
-    // Print to stdout
-    print!("{}", output);
+    // Stream to stdout, or to the pager when --pager applies
+    let mut pager = if args.pager && is_tty {
+        let command = output::pager::pager_command(std::env::var("PAGER").ok());
+        match output::pager::Pager::spawn(&command) {
+            Ok(pager) => Some(pager),
+            Err(e) => {
+                eprintln!("Warning: could not start pager '{}': {}", command, e);
+                None
+            }
+        }
+    } else {
+        None
+    };
+    let stdout = io::stdout();
+    let sink: Box<dyn Write> = match pager.as_mut() {
+        Some(pager) => Box::new(pager),
+        None => Box::new(stdout.lock()),
+    };
+    let encoded =
+        output::writer::EncodingWriter::new(io::BufWriter::new(sink), args.output_encoding);
+    let mut capped =
+        output::writer::CappedWriter::new(encoded, args.max_output_bytes, args.output_encoding);
+    // A Markdown note would break a structured document
+    if !matches!(args.format, FormatMode::Auto) {
+        capped = capped.with_footer_on_stderr();
+    }
+    let mut out = output::writer::LinePrefixWriter::new(capped, prefix);
+    render::write_wrapped(&args, renderer.as_mut(), &root_node, &notes, &mut out)?;
+    out.flush()?;
+    drop(out);
+    if let Some(pager) = pager {
+        pager.finish()?;
+    }
+    print_stderr_summary(&args, &root_node);
+    walk_report.errors.extend_from_slice(renderer.read_errors());
+    exit_on_scan_errors(&args, &walk_report);
 
     Ok(())
 }
 
+/// Print the --stderr-summary line once the output is complete
+fn print_stderr_summary(args: &Args, root: &fs_tree::Node) {
+    if args.stderr_summary {
+        eprintln!(
+            "{}",
+            output::summary::TreeSummary::from_tree(root).to_line()
+        );
+    }
+}
+
+/// Under --strict, list the paths the walk or -c could not read and exit
+/// nonzero.
+/// Called once the partial output is complete.
+fn exit_on_scan_errors(args: &Args, report: &fs_tree::build::WalkReport) {
+    if !args.strict || report.errors.is_empty() {
+        return;
+    }
+    for error in &report.errors {
+        eprintln!("Error: {}", error);
+    }
+    eprintln!(
+        "Error: {} path(s) could not be read; the output is incomplete.",
+        report.errors.len()
+    );
+    std::process::exit(1);
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
+    use clap::Parser;
     use language::detect_lang;
     use std::fs;
     use tempfile::TempDir;
@@ -105,7 +302,7 @@ mod tests {
 
         let args = Args::parse_from(&["tree2md", temp_path.to_str().unwrap()]);
         let display_root = temp_path.to_path_buf();
-        let tree =
+        let (tree, _) =
             build_tree(temp_path.to_str().unwrap(), &args, temp_path, &display_root).unwrap();
 
         assert!(tree.is_dir);
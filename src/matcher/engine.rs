@@ -1,12 +1,23 @@
+use super::filter_file::FilterRules;
+use super::tree2mdignore::Tree2mdIgnore;
 use super::{MatchSpec, RelPath};
 use crate::safety::SafetyPreset;
 use globset::{Glob, GlobSet, GlobSetBuilder};
-use ignore::gitignore::{Gitignore, GitignoreBuilder};
+use ignore::gitignore::{self, Gitignore, GitignoreBuilder};
+use ignore::Match;
 use std::collections::HashSet;
 use std::io;
 use std::path::Path;
 use std::path::PathBuf;
 
+/// Version control metadata directories, skipped unless --include-vcs
+const VCS_DIRS: &[&str] = &[".git", ".svn", ".hg", ".bzr"];
+
+/// Whether `name` is a VCS metadata directory name such as `.git`
+pub fn is_vcs_dir_name(name: &str) -> bool {
+    VCS_DIRS.contains(&name)
+}
+
 /// Selection decision for a path
 #[derive(Debug, Clone, Copy, PartialEq, Eq)]
 pub enum Selection {
@@ -18,11 +29,35 @@ pub enum Selection {
     PruneDir,
 }
 
+/// The gitignore pattern that decided whether a path is ignored
+#[derive(Debug, Clone, PartialEq)]
+struct GitignoreDecision {
+    /// The pattern as written in the ignore file
+    pattern: String,
+    /// The ignore file the pattern came from
+    source: Option<PathBuf>,
+    /// The pattern was a negation (`!pattern`) that re-included the path
+    negated: bool,
+}
+
+impl GitignoreDecision {
+    fn from_glob(glob: &gitignore::Glob, negated: bool) -> Self {
+        Self {
+            pattern: glob.original().to_string(),
+            source: glob.from().map(Path::to_path_buf),
+            negated,
+        }
+    }
+}
+
 /// Compiled matcher engine that evaluates paths against rules
 pub struct MatcherEngine {
     /// Compiled extension set for fast lookups
     include_ext_set: HashSet<String>,
 
+    /// Extensions negated in --include-ext (`!md`); these always exclude
+    exclude_ext_set: HashSet<String>,
+
     /// Original include glob patterns (for directory checking)
     include_glob: Vec<String>,
 
@@ -32,11 +67,21 @@ pub struct MatcherEngine {
     /// Compiled exclude glob patterns
     exclude_globset: Option<GlobSet>,
 
+    /// Exact root-relative paths from --exclude-path
+    exclude_paths: HashSet<String>,
+
+    /// Ordered `+`/`-` rules from --filter-file
+    filter_rules: Option<FilterRules>,
+
     /// Gitignore rules: list of (scope_dir relative to root, compiled gitignore).
     /// Each entry applies only to paths under its scope directory.
     /// A scope of "" means root-level (applies to everything).
     gitignore_layers: Vec<(String, Gitignore)>,
 
+    /// Root-level rules from .tree2mdignore and --ignore. An ignore here
+    /// wins over any .gitignore, even a nested negation.
+    override_layers: Vec<Gitignore>,
+
     /// Safety preset for excluding sensitive files
     safety_preset: Option<SafetyPreset>,
 
@@ -45,23 +90,31 @@ pub struct MatcherEngine {
 
     /// Whether matching is case sensitive
     case_sensitive: bool,
+
+    /// Log the gitignore pattern behind each decision (--gitignore-debug)
+    gitignore_debug: bool,
+
+    /// Walk VCS metadata directories instead of pruning them (--include-vcs)
+    include_vcs: bool,
 }
 
 impl MatcherEngine {
     /// Compile a MatchSpec into an optimized MatcherEngine
     pub fn compile(spec: &MatchSpec, root: &Path) -> io::Result<Self> {
-        // Build extension set
-        let include_ext_set: HashSet<String> = spec
-            .include_ext
-            .iter()
-            .map(|ext| {
-                if spec.case_sensitive {
-                    ext.clone()
-                } else {
-                    ext.to_lowercase()
-                }
-            })
-            .collect();
+        // Build extension sets
+        let ext_set = |exts: &[String]| -> HashSet<String> {
+            exts.iter()
+                .map(|ext| {
+                    if spec.case_sensitive {
+                        ext.clone()
+                    } else {
+                        ext.to_lowercase()
+                    }
+                })
+                .collect()
+        };
+        let include_ext_set = ext_set(&spec.include_ext);
+        let exclude_ext_set = ext_set(&spec.exclude_ext);
 
         // Build include globset
         let include_globset = if !spec.include_glob.is_empty() {
@@ -110,8 +163,9 @@ impl MatcherEngine {
         // Build gitignore layers if needed.
         // Each .gitignore file becomes a separate layer with its own scope,
         // because the `ignore` crate's Gitignore::matched() does not enforce
-        // directory scoping on its own.
-        let gitignore_layers = if spec.respect_gitignore {
+        // directory scoping on its own. --respect-npmignore replaces these
+        // with the npm layers below rather than stacking on top of them.
+        let mut gitignore_layers = if spec.respect_gitignore && !spec.respect_npmignore {
             let mut layers: Vec<(String, Gitignore)> = Vec::new();
 
             // Root-level layer: collects patterns from root/.gitignore,
@@ -166,23 +220,8 @@ impl MatcherEngine {
             }
 
             // Nested layers: each subdirectory .gitignore gets its own Gitignore
-            for gitignore_path in Self::collect_nested_gitignores(root) {
-                let dir = gitignore_path.parent().unwrap();
-                let scope = dir
-                    .strip_prefix(root)
-                    .unwrap_or(Path::new(""))
-                    .to_string_lossy()
-                    .replace('\\', "/");
-
-                let mut builder = GitignoreBuilder::new(dir);
-                builder.add(&gitignore_path);
-                let gi = builder.build().map_err(|e| {
-                    io::Error::new(
-                        io::ErrorKind::InvalidInput,
-                        format!("Failed to build gitignore for {}: {}", scope, e),
-                    )
-                })?;
-                layers.push((scope, gi));
+            for gitignore_path in Self::collect_nested_ignore_files(root, Self::gitignore_file) {
+                layers.push(Self::build_scoped_layer(root, &gitignore_path)?);
             }
 
             layers
@@ -190,6 +229,57 @@ impl MatcherEngine {
             Vec::new()
         };
 
+        // npm-style layers: like `npm publish`, each directory uses its
+        // .npmignore, or its .gitignore when it has no .npmignore, never both.
+        // Only the package root counts; ancestors and global ignores are not
+        // consulted, whatever --use-gitignore says.
+        if spec.respect_npmignore {
+            if let Some(ignore_path) = Self::npm_ignore_file(root) {
+                gitignore_layers.push(Self::build_scoped_layer(root, &ignore_path)?);
+            }
+            for ignore_path in Self::collect_nested_ignore_files(root, Self::npm_ignore_file) {
+                gitignore_layers.push(Self::build_scoped_layer(root, &ignore_path)?);
+            }
+        }
+
+        let filter_rules = spec
+            .filter_file
+            .as_deref()
+            .map(FilterRules::load)
+            .transpose()?;
+
+        // .tree2mdignore at the root adds its plain patterns as a root
+        // layer; its content directives are applied when emitting contents,
+        // but compiled here too so a bad pattern fails the run up front
+        let mut override_layers = Vec::new();
+        if let Some(rules) = Tree2mdIgnore::load(root)? {
+            if let Some(gi) = rules.tree_layer(root)? {
+                override_layers.push(gi);
+            }
+            rules.content_rules(root)?;
+        }
+
+        // --ignore patterns form one more root layer, built line by line so
+        // a later `!pattern` can re-include what an earlier one ignored
+        if !spec.ignore_patterns.is_empty() {
+            let mut builder = GitignoreBuilder::new(root);
+            for pattern in &spec.ignore_patterns {
+                builder.add_line(None, pattern).map_err(|e| {
+                    io::Error::new(
+                        io::ErrorKind::InvalidInput,
+                        format!("Invalid --ignore pattern '{}': {}", pattern, e),
+                    )
+                })?;
+            }
+            let gi = builder.build().map_err(|e| {
+                io::Error::new(
+                    io::ErrorKind::InvalidInput,
+                    format!("Failed to build --ignore patterns: {}", e),
+                )
+            })?;
+            override_layers.push(gi);
+        }
+
         // Create safety preset if enabled
         let safety_preset = if spec.use_safety_preset {
             Some(SafetyPreset::new())
@@ -199,19 +289,28 @@ impl MatcherEngine {
 
         Ok(Self {
             include_ext_set,
+            exclude_ext_set,
             include_glob: spec.include_glob.clone(),
             include_globset,
             exclude_globset,
+            exclude_paths: spec.exclude_paths.iter().cloned().collect(),
+            filter_rules,
             gitignore_layers,
+            override_layers,
             safety_preset,
             has_includes: spec.has_includes(),
             case_sensitive: spec.case_sensitive,
+            gitignore_debug: spec.gitignore_debug,
+            include_vcs: spec.include_vcs,
         })
     }
 
     /// Select whether to include, exclude, or prune a file
     ///
     /// Priority order:
+    /// 0. If the path is an --exclude-path or its extension is negated in
+    ///    --include-ext (`!md`) → Exclude
+    ///    Then the last --filter-file rule matching the file → Include/Exclude
     /// 1. If has_includes and file doesn't match any include → Exclude
     /// 2. If file matches a path-specific include (e.g., `vendor/**/*.py`) → Include
     ///    (path-specific includes explicitly target files and override exclude)
@@ -223,6 +322,27 @@ impl MatcherEngine {
     pub fn select_file(&self, rel_path: &RelPath) -> Selection {
         let path_str = rel_path.as_match_str();
 
+        // Priority 0: Exact --exclude-path matches and extension negations
+        // win over every include
+        if self.exclude_paths.contains(path_str.as_ref()) {
+            return Selection::Exclude;
+        }
+        if self
+            .file_ext(rel_path)
+            .is_some_and(|ext| self.exclude_ext_set.contains(&ext))
+        {
+            return Selection::Exclude;
+        }
+
+        // Priority 0.5: The last matching --filter-file rule decides
+        if let Some(include) = self.filter_decision(&path_str, false) {
+            return if include {
+                Selection::Include
+            } else {
+                Selection::Exclude
+            };
+        }
+
         let matched_include = self.matches_include_rules(&path_str, rel_path);
 
         // Priority 1: If include patterns exist but file doesn't match any, exclude
@@ -267,17 +387,32 @@ impl MatcherEngine {
     /// Select whether to include, exclude, or prune a directory
     ///
     /// Priority order:
-    /// 1. .git → always prune
+    /// 0. --exclude-path → always prune
+    /// 1. VCS metadata (.git, .svn, .hg, .bzr) → prune unless --include-vcs;
+    ///    then the last matching --filter-file rule: `-` → prune
     /// 2. Gitignore → always prune (like rg/fd: gitignored dirs are never traversed)
     /// 3. Safety preset → always prune
-    /// 4. Include patterns may keep dir alive (prevents -X from pruning)
+    /// 4. Include patterns or a `+` --filter-file rule may keep dir alive
+    ///    (prevents -X from pruning)
     /// 5. Exclude patterns (-X) → prune
     /// 6. Default → include
     pub fn select_dir(&self, rel_path: &RelPath) -> Selection {
         let path_str = rel_path.as_match_str();
 
-        // Priority 1: Always exclude .git directory
-        if path_str == ".git" || path_str.starts_with(".git/") {
+        // Priority 0: Exact --exclude-path matches, whatever else applies
+        if self.exclude_paths.contains(path_str.as_ref()) {
+            return Selection::PruneDir;
+        }
+
+        // Priority 1: VCS metadata directories at any depth
+        if !self.include_vcs && path_str.split('/').any(is_vcs_dir_name) {
+            return Selection::PruneDir;
+        }
+
+        // Priority 1.5: A `-` --filter-file rule prunes; `+` still leaves
+        // the directory to gitignore and the safety preset
+        let filter = self.filter_decision(&path_str, true);
+        if filter == Some(false) {
             return Selection::PruneDir;
         }
 
@@ -307,7 +442,10 @@ impl MatcherEngine {
         // any include patterns (including generic ones like `**/src/**`).
         // This prevents `-X` from pruning directories that might have matches.
         let may_contain_includes = self.dir_may_contain_includes(&path_str);
-        if self.matches_include_rules(&path_str, rel_path) || may_contain_includes {
+        if self.matches_include_rules(&path_str, rel_path)
+            || may_contain_includes
+            || filter == Some(true)
+        {
             return Selection::Include;
         }
 
@@ -325,10 +463,64 @@ impl MatcherEngine {
         Selection::Include
     }
 
+    /// The --filter-file decision for a path, if any rule matches it
+    fn filter_decision(&self, path_str: &str, is_dir: bool) -> Option<bool> {
+        self.filter_rules
+            .as_ref()
+            .and_then(|rules| rules.decide(path_str, is_dir))
+    }
+
     /// Check if a path matches any gitignore layer, respecting directory scoping.
-    /// Each layer has a scope (relative dir prefix). A layer only applies to
-    /// paths under its scope. Scope "" means root (applies to everything).
+    /// With --gitignore-debug, the deciding pattern is logged to stderr.
     fn matches_gitignore(&self, path_str: &str, rel_path: &RelPath, is_dir: bool) -> bool {
+        let Some(decision) = self.gitignore_decision(path_str, rel_path, is_dir) else {
+            return false;
+        };
+        if self.gitignore_debug {
+            let source = decision
+                .source
+                .map(|p| format!(" in {}", p.display()))
+                .unwrap_or_default();
+            if decision.negated {
+                eprintln!(
+                    "gitignore-debug: kept '{}' (negated pattern '{}'{})",
+                    path_str, decision.pattern, source
+                );
+            } else {
+                eprintln!(
+                    "gitignore-debug: excluded '{}' (pattern '{}'{})",
+                    path_str, decision.pattern, source
+                );
+            }
+        }
+        !decision.negated
+    }
+
+    /// Find the gitignore pattern that decides a path, as git does: each
+    /// layer applies only to paths under its scope (relative dir prefix,
+    /// "" for root), and the deepest layer with a matching pattern decides,
+    /// so a nested `!debug.log` re-includes what a parent `*.log` ignored.
+    /// Layers at the same depth (root, ancestor and global ignores) decide
+    /// by "an ignore wins". An ignore in .tree2mdignore or --ignore beats
+    /// everything; their negations only apply when no .gitignore matched.
+    fn gitignore_decision(
+        &self,
+        path_str: &str,
+        rel_path: &RelPath,
+        is_dir: bool,
+    ) -> Option<GitignoreDecision> {
+        let mut override_negation = None;
+        for gitignore in &self.override_layers {
+            match gitignore.matched(rel_path.to_path_buf(), is_dir) {
+                Match::Ignore(glob) => return Some(GitignoreDecision::from_glob(glob, false)),
+                Match::Whitelist(glob) if override_negation.is_none() => {
+                    override_negation = Some(GitignoreDecision::from_glob(glob, true));
+                }
+                _ => {}
+            }
+        }
+
+        let mut decision: Option<(usize, GitignoreDecision)> = None;
         for (scope, gitignore) in &self.gitignore_layers {
             // Check if path is under this layer's scope
             if !scope.is_empty() && !path_str.starts_with(&format!("{}/", scope)) {
@@ -336,33 +528,51 @@ impl MatcherEngine {
             }
 
             // For scoped layers, match against the path relative to the scope dir
-            let match_path = if scope.is_empty() {
-                rel_path.to_path_buf()
+            let (depth, match_path) = if scope.is_empty() {
+                (0, rel_path.to_path_buf())
             } else {
-                PathBuf::from(&path_str[scope.len() + 1..])
+                (
+                    scope.split('/').count(),
+                    PathBuf::from(&path_str[scope.len() + 1..]),
+                )
             };
 
-            if gitignore.matched(&match_path, is_dir).is_ignore() {
-                return true;
+            let candidate = match gitignore.matched(&match_path, is_dir) {
+                Match::Ignore(glob) => GitignoreDecision::from_glob(glob, false),
+                Match::Whitelist(glob) => GitignoreDecision::from_glob(glob, true),
+                _ => continue,
+            };
+            let replace = decision.as_ref().is_none_or(|(best, current)| {
+                depth > *best || (depth == *best && current.negated && !candidate.negated)
+            });
+            if replace {
+                decision = Some((depth, candidate));
             }
         }
-        false
+        decision.map(|(_, d)| d).or(override_negation)
+    }
+
+    /// Dotted extension of a path (".rs"), lowercased unless case sensitive
+    fn file_ext(&self, rel_path: &RelPath) -> Option<String> {
+        let ext = rel_path
+            .to_path_buf()
+            .extension()?
+            .to_string_lossy()
+            .into_owned();
+        let ext = format!(".{}", ext);
+        Some(if self.case_sensitive {
+            ext
+        } else {
+            ext.to_lowercase()
+        })
     }
 
     /// Check if a path matches any include rules
     fn matches_include_rules(&self, path_str: &str, rel_path: &RelPath) -> bool {
         // Check extension matching
         if !self.include_ext_set.is_empty() {
-            let path_buf = rel_path.to_path_buf();
-            if let Some(ext) = path_buf.extension() {
-                let ext_str = format!(".{}", ext.to_string_lossy());
-                let ext_to_check = if self.case_sensitive {
-                    ext_str
-                } else {
-                    ext_str.to_lowercase()
-                };
-
-                if self.include_ext_set.contains(&ext_to_check) {
+            if let Some(ext) = self.file_ext(rel_path) {
+                if self.include_ext_set.contains(&ext) {
                     return true;
                 }
             }
@@ -406,7 +616,10 @@ impl MatcherEngine {
 
     /// Recursively collect `.gitignore` files from subdirectories of root.
     /// The root's own `.gitignore` is excluded (already handled by the upward walk).
-    fn collect_nested_gitignores(root: &Path) -> Vec<PathBuf> {
+    fn collect_nested_ignore_files(
+        root: &Path,
+        ignore_file: fn(&Path) -> Option<PathBuf>,
+    ) -> Vec<PathBuf> {
         let mut result = Vec::new();
         let mut stack = Vec::new();
 
@@ -424,9 +637,8 @@ impl MatcherEngine {
         }
 
         while let Some(dir) = stack.pop() {
-            let gitignore_path = dir.join(".gitignore");
-            if gitignore_path.exists() {
-                result.push(gitignore_path);
+            if let Some(ignore_path) = ignore_file(&dir) {
+                result.push(ignore_path);
             }
 
             if let Ok(entries) = std::fs::read_dir(&dir) {
@@ -443,6 +655,38 @@ impl MatcherEngine {
         result
     }
 
+    /// The .gitignore file of a directory, if any
+    fn gitignore_file(dir: &Path) -> Option<PathBuf> {
+        Some(dir.join(".gitignore")).filter(|p| p.exists())
+    }
+
+    /// The ignore file npm would use for a directory: .npmignore, else .gitignore
+    fn npm_ignore_file(dir: &Path) -> Option<PathBuf> {
+        Some(dir.join(".npmignore"))
+            .filter(|p| p.exists())
+            .or_else(|| Self::gitignore_file(dir))
+    }
+
+    /// Build a layer from one ignore file, scoped to its directory relative to `root`
+    fn build_scoped_layer(root: &Path, ignore_path: &Path) -> io::Result<(String, Gitignore)> {
+        let dir = ignore_path.parent().unwrap();
+        let scope = dir
+            .strip_prefix(root)
+            .unwrap_or(Path::new(""))
+            .to_string_lossy()
+            .replace('\\', "/");
+
+        let mut builder = GitignoreBuilder::new(dir);
+        builder.add(ignore_path);
+        let gi = builder.build().map_err(|e| {
+            io::Error::new(
+                io::ErrorKind::InvalidInput,
+                format!("Failed to build gitignore for {}: {}", scope, e),
+            )
+        })?;
+        Ok((scope, gi))
+    }
+
     /// Check if a directory potentially contains files that match
     /// path-specific include patterns (patterns that don't start with `**/`).
     ///
@@ -545,6 +789,24 @@ mod tests {
         assert_eq!(engine.select_file(&txt_file), Selection::Exclude);
     }
 
+    #[test]
+    fn test_include_extensions_match_dotfiles() {
+        let spec = MatchSpec::new().with_include_ext(vec![".json".to_string()]);
+
+        let temp_dir = TempDir::new().unwrap();
+        let engine = MatcherEngine::compile(&spec, temp_dir.path()).unwrap();
+
+        let eslintrc = RelPath::from_relative(".eslintrc.json");
+        assert_eq!(engine.select_file(&eslintrc), Selection::Include);
+
+        let nested = RelPath::from_relative("config/.babelrc.json");
+        assert_eq!(engine.select_file(&nested), Selection::Include);
+
+        // A dotfile without an extension has nothing to match
+        let bare = RelPath::from_relative(".eslintrc");
+        assert_eq!(engine.select_file(&bare), Selection::Exclude);
+    }
+
     #[test]
     fn test_include_globs() {
         let spec =
@@ -0,0 +1,125 @@
+//! `--filter-file`: ordered include/exclude rules in one file, in the
+//! spirit of rsync filter rules.
+//!
+//! Grammar, one rule per line:
+//!
+//! - blank lines and `#` comments are skipped
+//! - `+ GLOB` includes matching paths
+//! - `- GLOB` excludes matching paths
+//!
+//! GLOB is relative to the root and made recursive like `-I`/`-X`
+//! patterns (`*.txt` matches at any depth, a bare name like `build` means
+//! that directory anywhere). The last rule matching a path decides; a path
+//! no rule matches is left to the other filters.
+
+use super::MatchSpec;
+use globset::{Glob, GlobMatcher};
+use std::io;
+use std::path::Path;
+
+/// One `+`/`-` line
+#[derive(Debug, Clone)]
+struct FilterRule {
+    include: bool,
+    matcher: GlobMatcher,
+}
+
+/// Rules parsed from a --filter-file, in file order
+#[derive(Debug, Clone, Default)]
+pub struct FilterRules {
+    rules: Vec<FilterRule>,
+}
+
+impl FilterRules {
+    pub fn parse(text: &str) -> Result<Self, String> {
+        let mut rules = Vec::new();
+        for (number, line) in text.lines().enumerate() {
+            let line = line.trim();
+            if line.is_empty() || line.starts_with('#') {
+                continue;
+            }
+            let (include, pattern) = if let Some(pattern) = line.strip_prefix('+') {
+                (true, pattern.trim())
+            } else if let Some(pattern) = line.strip_prefix('-') {
+                (false, pattern.trim())
+            } else {
+                return Err(format!(
+                    "line {}: expected '+ GLOB' or '- GLOB', got '{}'",
+                    number + 1,
+                    line
+                ));
+            };
+            if pattern.is_empty() {
+                return Err(format!(
+                    "line {}: missing glob after '{}'",
+                    number + 1,
+                    line
+                ));
+            }
+            let glob = Glob::new(&MatchSpec::normalize_pattern(pattern))
+                .map_err(|e| format!("line {}: invalid glob '{}': {}", number + 1, pattern, e))?;
+            rules.push(FilterRule {
+                include,
+                matcher: glob.compile_matcher(),
+            });
+        }
+        Ok(Self { rules })
+    }
+
+    /// Read and parse the filter file at `path`
+    pub fn load(path: &Path) -> io::Result<Self> {
+        let text = std::fs::read_to_string(path)?;
+        Self::parse(&text).map_err(|e| {
+            io::Error::new(
+                io::ErrorKind::InvalidInput,
+                format!("Invalid --filter-file '{}': {}", path.display(), e),
+            )
+        })
+    }
+
+    /// The decision of the last rule matching `path_str` (Some(true) to
+    /// include), or None when no rule matches. Directories are also tried
+    /// with a trailing slash, as for -X.
+    pub fn decide(&self, path_str: &str, is_dir: bool) -> Option<bool> {
+        let dir_form = format!("{}/", path_str);
+        self.rules
+            .iter()
+            .rev()
+            .find(|rule| {
+                rule.matcher.is_match(path_str) || (is_dir && rule.matcher.is_match(&dir_form))
+            })
+            .map(|rule| rule.include)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_last_matching_rule_wins() {
+        let rules = FilterRules::parse(
+            "# docs are noise, except the important one\n\
+             - *.txt\n\
+             + important.txt\n",
+        )
+        .unwrap();
+        assert_eq!(rules.decide("notes.txt", false), Some(false));
+        assert_eq!(rules.decide("docs/important.txt", false), Some(true));
+        assert_eq!(rules.decide("main.go", false), None);
+    }
+
+    #[test]
+    fn test_directory_rules() {
+        let rules = FilterRules::parse("- build\n").unwrap();
+        assert_eq!(rules.decide("build", true), Some(false));
+        assert_eq!(rules.decide("src/build/out.o", false), Some(false));
+    }
+
+    #[test]
+    fn test_rejects_lines_without_a_sign() {
+        let err = FilterRules::parse("+ *.go\n*.txt\n").unwrap_err();
+        assert!(err.starts_with("line 2:"), "{}", err);
+        assert!(FilterRules::parse("+\n").is_err());
+    }
+}
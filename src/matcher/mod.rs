@@ -1,7 +1,9 @@
 pub mod engine;
+pub mod filter_file;
 pub mod rel_path;
 pub mod spec;
+pub mod tree2mdignore;
 
-pub use engine::{MatcherEngine, Selection};
+pub use engine::{is_vcs_dir_name, MatcherEngine, Selection};
 pub use rel_path::RelPath;
 pub use spec::MatchSpec;
@@ -1,4 +1,124 @@
 use crate::cli::Args;
+use globset::Glob;
+use std::path::PathBuf;
+
+/// Built-in extension families used by --include-ext (overridable with --ext-alias)
+const BUILTIN_EXT_ALIASES: &[(&str, &[&str])] = &[
+    ("ts", &["ts", "tsx", "mts", "cts"]),
+    ("js", &["js", "jsx", "mjs", "cjs"]),
+    ("yaml", &["yaml", "yml"]),
+    ("yml", &["yml", "yaml"]),
+    ("md", &["md", "markdown"]),
+    ("jpg", &["jpg", "jpeg"]),
+    ("htm", &["htm", "html"]),
+    ("html", &["html", "htm"]),
+];
+
+/// An extension family from --ext-alias, e.g. `ts=ts,tsx`
+#[derive(Debug, Clone, PartialEq)]
+pub struct ExtAlias {
+    pub name: String,
+    pub extensions: Vec<String>,
+}
+
+impl ExtAlias {
+    /// Parse a `NAME=EXT,EXT...` spec
+    pub fn parse(spec: &str) -> Result<Self, String> {
+        let (name, extensions) = spec
+            .split_once('=')
+            .ok_or_else(|| format!("expected NAME=EXT[,EXT...], got '{}'", spec))?;
+
+        let name = normalize_ext(name);
+        let extensions: Vec<String> = extensions
+            .split(',')
+            .map(normalize_ext)
+            .filter(|e| !e.is_empty())
+            .collect();
+        if name.is_empty() || extensions.is_empty() {
+            return Err(format!("expected NAME=EXT[,EXT...], got '{}'", spec));
+        }
+
+        Ok(Self { name, extensions })
+    }
+}
+
+/// Strip whitespace and a leading dot: " .TS " -> "TS"
+fn normalize_ext(ext: &str) -> String {
+    ext.trim().trim_start_matches('.').to_string()
+}
+
+/// Extensions selected by --include-ext, dotted (".ts")
+#[derive(Debug, Default, Clone, PartialEq)]
+pub struct ExtList {
+    /// Extensions to include; empty means every extension (`*`)
+    pub include: Vec<String>,
+    /// `!`-prefixed extensions to exclude; these win over `include`
+    pub exclude: Vec<String>,
+    /// Every extension listed was excluded again (`go,!go`), so nothing
+    /// can match; `include` being empty does not mean `*` here
+    pub cancelled: bool,
+}
+
+/// Expand --include-ext values into dotted extensions (".ts"), applying
+/// user aliases first and built-in families second. Values may be
+/// comma-separated and may carry a leading dot. `*` allows every
+/// extension and `!ext` excludes one, so `*,!md` is everything but
+/// Markdown; a list of only negations implies `*`.
+pub fn parse_ext_list(values: &[String], aliases: &[ExtAlias]) -> ExtList {
+    let mut list = ExtList::default();
+    let mut wildcard = false;
+
+    for value in values.iter().flat_map(|v| v.split(',')) {
+        let value = value.trim();
+        let (negated, ext) = match value.strip_prefix('!') {
+            Some(rest) => (true, normalize_ext(rest)),
+            None => (false, normalize_ext(value)),
+        };
+        if ext.is_empty() {
+            continue;
+        }
+        if ext == "*" {
+            wildcard |= !negated;
+            continue;
+        }
+
+        let target = if negated {
+            &mut list.exclude
+        } else {
+            &mut list.include
+        };
+        let mut push = |ext: &str| {
+            let dotted = format!(".{}", ext);
+            if !target.contains(&dotted) {
+                target.push(dotted);
+            }
+        };
+        // Last --ext-alias for a name wins
+        if let Some(alias) = aliases.iter().rev().find(|a| a.name == ext) {
+            alias.extensions.iter().for_each(|e| push(e));
+        } else if let Some((_, family)) = BUILTIN_EXT_ALIASES.iter().find(|(n, _)| *n == ext) {
+            family.iter().for_each(|e| push(e));
+        } else {
+            push(&ext);
+        }
+    }
+
+    // `*` lifts the allow list; negations narrow whatever remains
+    if wildcard {
+        list.include.clear();
+    }
+    let exclude = list.exclude.clone();
+    let listed = !list.include.is_empty();
+    list.include.retain(|ext| !exclude.contains(ext));
+    list.cancelled = listed && list.include.is_empty();
+    list
+}
+
+/// Parse a --content-match glob, made recursive the same way as -I patterns
+pub fn parse_content_glob(pattern: &str) -> Result<Glob, String> {
+    let normalized = MatchSpec::normalize_pattern(pattern);
+    Glob::new(&normalized).map_err(|e| format!("invalid glob '{}': {}", pattern, e))
+}
 
 /// Declarative specification of file matching rules
 #[derive(Debug, Clone)]
@@ -6,18 +126,39 @@ pub struct MatchSpec {
     /// File extensions to include (e.g., [".rs", ".go"])
     pub include_ext: Vec<String>,
 
+    /// File extensions to exclude, from `!ext` in --include-ext (e.g., [".md"])
+    pub exclude_ext: Vec<String>,
+
     /// Glob patterns to include (e.g., ["**/*.rs", "src/*.go"])
     pub include_glob: Vec<String>,
 
     /// Glob patterns to exclude (e.g., ["**/target/**", "*.min.js"])
     pub exclude_glob: Vec<String>,
 
+    /// Exact root-relative paths to exclude (e.g., ["src/generated/big.go"])
+    pub exclude_paths: Vec<String>,
+
+    /// Ordered `+`/`-` rules file from --filter-file
+    pub filter_file: Option<PathBuf>,
+
+    /// Gitignore-style patterns from --ignore, in the order given
+    pub ignore_patterns: Vec<String>,
+
     /// Whether to respect gitignore files
     pub respect_gitignore: bool,
 
+    /// Whether to respect .npmignore files (falling back to .gitignore per directory)
+    pub respect_npmignore: bool,
+
+    /// Whether to log the gitignore pattern behind each decision
+    pub gitignore_debug: bool,
+
     /// Whether to apply safety presets (exclude sensitive files)
     pub use_safety_preset: bool,
 
+    /// Whether to walk VCS metadata directories (.git, .svn, .hg, .bzr)
+    pub include_vcs: bool,
+
     /// Whether pattern matching is case sensitive
     pub case_sensitive: bool,
 
@@ -29,10 +170,17 @@ impl Default for MatchSpec {
     fn default() -> Self {
         Self {
             include_ext: Vec::new(),
+            exclude_ext: Vec::new(),
             include_glob: Vec::new(),
             exclude_glob: Vec::new(),
+            exclude_paths: Vec::new(),
+            filter_file: None,
+            ignore_patterns: Vec::new(),
             respect_gitignore: false,
+            respect_npmignore: false,
+            gitignore_debug: false,
             use_safety_preset: true, // Default to safe mode ON
+            include_vcs: false,
             case_sensitive: true,
             _keep_dirs_until_pruned: true,
         }
@@ -49,7 +197,7 @@ impl MatchSpec {
     /// Normalize a glob pattern to be recursive if it doesn't contain path separators
     /// For example: "*.rs" becomes "**/*.rs" to match files at any depth
     /// For directory names like "specs", it becomes "**/{name}/**" to match at any depth (like .gitignore)
-    fn normalize_pattern(pattern: &str) -> String {
+    pub fn normalize_pattern(pattern: &str) -> String {
         // Trailing "/" just means "this is a directory" — strip it before normalization
         // so that "hoge/" and "hoge" behave identically
         let pattern = pattern.strip_suffix('/').unwrap_or(pattern);
@@ -68,10 +216,18 @@ impl MatchSpec {
         }
     }
 
+    /// Normalize an --exclude-path to the form paths are matched in:
+    /// forward slashes, no leading "./" and no trailing "/"
+    fn normalize_exact_path(path: &str) -> String {
+        let path = path.replace('\\', "/");
+        let path = path.strip_prefix("./").unwrap_or(&path);
+        path.trim_end_matches('/').to_string()
+    }
+
     /// Create a MatchSpec from CLI arguments
     pub fn from_args(args: &Args, target_path: &std::path::Path) -> Self {
-        // No more include_ext in new CLI, use include patterns instead
-        let include_ext = Vec::new();
+        // Extensions from --include-ext, expanded through --ext-alias families
+        let ext_list = parse_ext_list(&args.include_ext, &args.ext_alias);
 
         // Use the new include patterns from -I/--include
         let include_glob = args
@@ -99,11 +255,22 @@ impl MatchSpec {
         };
 
         Self {
-            include_ext,
+            include_ext: ext_list.include,
+            exclude_ext: ext_list.exclude,
             include_glob,
             exclude_glob,
+            exclude_paths: args
+                .exclude_path
+                .iter()
+                .map(|p| Self::normalize_exact_path(p))
+                .collect(),
+            filter_file: args.filter_file.clone(),
+            ignore_patterns: args.ignore.clone(),
             respect_gitignore,
+            respect_npmignore: args.respect_npmignore,
+            gitignore_debug: args.gitignore_debug,
             use_safety_preset: args.is_safe_mode(),
+            include_vcs: args.include_vcs,
             case_sensitive: true, // Could be extended with --ignore-case flag
             _keep_dirs_until_pruned: true,
         }
@@ -270,4 +437,55 @@ mod tests {
         let spec = MatchSpec::new().with_exclude_glob(vec!["src/lib/".to_string()]);
         assert_eq!(spec.exclude_glob[0], "src/lib");
     }
+
+    #[test]
+    fn test_parse_ext_list_builtin_aliases() {
+        let exts = parse_ext_list(&["ts".to_string(), ".rs".to_string()], &[]);
+        assert_eq!(exts.include, vec![".ts", ".tsx", ".mts", ".cts", ".rs"]);
+
+        let exts = parse_ext_list(&["go,yml".to_string()], &[]);
+        assert_eq!(exts.include, vec![".go", ".yml", ".yaml"]);
+    }
+
+    #[test]
+    fn test_parse_ext_list_user_alias_overrides_builtin() {
+        let aliases = vec![ExtAlias::parse("ts=ts,tsx").unwrap()];
+        let exts = parse_ext_list(&["ts".to_string()], &aliases);
+        assert_eq!(exts.include, vec![".ts", ".tsx"]);
+    }
+
+    #[test]
+    fn test_parse_ext_list_wildcard_and_negations() {
+        let list = |value: &str| parse_ext_list(&[value.to_string()], &[]);
+
+        // Everything except Markdown (the md family includes .markdown)
+        let exts = list("*,!.md");
+        assert!(exts.include.is_empty());
+        assert_eq!(exts.exclude, vec![".md", ".markdown"]);
+
+        // Negations alone imply `*`
+        assert_eq!(list("!md"), exts);
+
+        // Negations win over allowed entries, in any order
+        let exts = list("!tsx,ts,go,!go");
+        assert_eq!(exts.include, vec![".ts", ".mts", ".cts"]);
+        assert_eq!(exts.exclude, vec![".tsx", ".go"]);
+        assert!(!exts.cancelled);
+
+        // Negating every listed extension leaves nothing, not everything
+        let exts = list("go,!go");
+        assert!(exts.include.is_empty());
+        assert!(exts.cancelled);
+        assert!(!list("*,go,!go").cancelled);
+    }
+
+    #[test]
+    fn test_ext_alias_parse() {
+        let alias = ExtAlias::parse("web=.html, css,js").unwrap();
+        assert_eq!(alias.name, "web");
+        assert_eq!(alias.extensions, vec!["html", "css", "js"]);
+
+        assert!(ExtAlias::parse("ts").is_err());
+        assert!(ExtAlias::parse("ts=").is_err());
+    }
 }
@@ -0,0 +1,179 @@
+//! `.tree2mdignore`: gitignore syntax plus directives that only affect
+//! which files get their contents emitted under `-c`.
+//!
+//! Grammar, one rule per line:
+//!
+//! - blank lines and `#` comments are skipped, as in .gitignore
+//! - `!content:PATTERN` keeps matching files in the tree but never emits
+//!   their contents
+//! - `content-only:PATTERN` emits contents only for files matching one of
+//!   these patterns (the tree is unaffected)
+//! - any other line is a gitignore pattern (including `!pattern`) that
+//!   filters the tree itself
+//!
+//! PATTERN uses gitignore syntax and is relative to the directory holding
+//! the `.tree2mdignore` file (the root being rendered).
+
+use ignore::gitignore::{Gitignore, GitignoreBuilder};
+use std::io;
+use std::path::{Path, PathBuf};
+
+/// Name of the ignore file read from the root
+pub const FILE_NAME: &str = ".tree2mdignore";
+
+const SKIP_CONTENT_PREFIX: &str = "!content:";
+const CONTENT_ONLY_PREFIX: &str = "content-only:";
+
+/// Rules parsed from a `.tree2mdignore` file, in file order
+#[derive(Debug, Default, Clone, PartialEq)]
+pub struct Tree2mdIgnore {
+    /// Plain gitignore lines that filter the tree
+    pub tree_patterns: Vec<String>,
+    /// `!content:` patterns: listed in the tree, contents never emitted
+    pub skip_content: Vec<String>,
+    /// `content-only:` patterns: the only files whose contents are emitted
+    pub content_only: Vec<String>,
+}
+
+impl Tree2mdIgnore {
+    pub fn parse(text: &str) -> Self {
+        let mut rules = Self::default();
+        for line in text.lines() {
+            let trimmed = line.trim();
+            if trimmed.is_empty() || trimmed.starts_with('#') {
+                continue;
+            }
+            if let Some(pattern) = trimmed.strip_prefix(SKIP_CONTENT_PREFIX) {
+                rules.skip_content.push(pattern.trim().to_string());
+            } else if let Some(pattern) = trimmed.strip_prefix(CONTENT_ONLY_PREFIX) {
+                rules.content_only.push(pattern.trim().to_string());
+            } else {
+                rules.tree_patterns.push(line.to_string());
+            }
+        }
+        rules
+    }
+
+    /// Read `root/.tree2mdignore`, or None when there is no such file
+    pub fn load(root: &Path) -> io::Result<Option<Self>> {
+        match std::fs::read_to_string(root.join(FILE_NAME)) {
+            Ok(text) => Ok(Some(Self::parse(&text))),
+            Err(e) if e.kind() == io::ErrorKind::NotFound => Ok(None),
+            Err(e) => Err(e),
+        }
+    }
+
+    /// Compile the tree patterns into a gitignore layer scoped to `root`,
+    /// or None when the file has none
+    pub fn tree_layer(&self, root: &Path) -> io::Result<Option<Gitignore>> {
+        if self.tree_patterns.is_empty() {
+            return Ok(None);
+        }
+        let source = root.join(FILE_NAME);
+        compile(root, &self.tree_patterns, Some(source)).map(Some)
+    }
+
+    /// Compile the content directives for matching display paths
+    pub fn content_rules(&self, root: &Path) -> io::Result<ContentRules> {
+        let skip = compile(root, &self.skip_content, None)?;
+        let only = if self.content_only.is_empty() {
+            None
+        } else {
+            Some(compile(root, &self.content_only, None)?)
+        };
+        Ok(ContentRules { skip, only })
+    }
+}
+
+/// Compiled `!content:` and `content-only:` directives
+#[derive(Debug, Clone)]
+pub struct ContentRules {
+    skip: Gitignore,
+    only: Option<Gitignore>,
+}
+
+impl ContentRules {
+    /// Whether the contents of the file at `rel_path` (relative to the root)
+    /// may be emitted
+    pub fn allows(&self, rel_path: &Path) -> bool {
+        if self
+            .skip
+            .matched_path_or_any_parents(rel_path, false)
+            .is_ignore()
+        {
+            return false;
+        }
+        self.only.as_ref().is_none_or(|only| {
+            only.matched_path_or_any_parents(rel_path, false)
+                .is_ignore()
+        })
+    }
+}
+
+fn compile(root: &Path, patterns: &[String], source: Option<PathBuf>) -> io::Result<Gitignore> {
+    let mut builder = GitignoreBuilder::new(root);
+    for pattern in patterns {
+        builder.add_line(source.clone(), pattern).map_err(|e| {
+            io::Error::new(
+                io::ErrorKind::InvalidInput,
+                format!("Invalid {} pattern '{}': {}", FILE_NAME, pattern, e),
+            )
+        })?;
+    }
+    builder.build().map_err(|e| {
+        io::Error::new(
+            io::ErrorKind::InvalidInput,
+            format!("Failed to build {}: {}", FILE_NAME, e),
+        )
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_parse_sorts_lines_by_directive() {
+        let rules = Tree2mdIgnore::parse(
+            "# comment\n\
+             \n\
+             build-output/\n\
+             !keep.txt\n\
+             !content:pkg/*.go\n\
+             content-only: *.md\n",
+        );
+        assert_eq!(rules.tree_patterns, vec!["build-output/", "!keep.txt"]);
+        assert_eq!(rules.skip_content, vec!["pkg/*.go"]);
+        assert_eq!(rules.content_only, vec!["*.md"]);
+    }
+
+    #[test]
+    fn test_unknown_prefixes_are_plain_patterns() {
+        // Only the two directives are special; anything else is gitignore syntax
+        let rules = Tree2mdIgnore::parse("content:a.go\n!tree:b.go\n");
+        assert_eq!(rules.tree_patterns, vec!["content:a.go", "!tree:b.go"]);
+        assert!(rules.skip_content.is_empty());
+        assert!(rules.content_only.is_empty());
+    }
+
+    #[test]
+    fn test_content_rules() {
+        let root = Path::new("/project");
+        let rules = Tree2mdIgnore::parse("!content:pkg/*.go\ncontent-only:*.go\n")
+            .content_rules(root)
+            .unwrap();
+        assert!(rules.allows(Path::new("main.go")));
+        assert!(rules.allows(Path::new("cmd/main.go")));
+        assert!(!rules.allows(Path::new("pkg/util.go")));
+        assert!(!rules.allows(Path::new("README.md")));
+    }
+
+    #[test]
+    fn test_skip_content_directory() {
+        let rules = Tree2mdIgnore::parse("!content:vendor/\n")
+            .content_rules(Path::new("/project"))
+            .unwrap();
+        assert!(!rules.allows(Path::new("vendor/lib/a.go")));
+        assert!(rules.allows(Path::new("src/a.go")));
+    }
+}
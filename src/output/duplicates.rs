@@ -0,0 +1,188 @@
+use crate::render::pipeline::IrFile;
+use crate::util::format::format_size;
+use std::collections::hash_map::DefaultHasher;
+use std::collections::HashMap;
+use std::hash::Hasher;
+use std::path::{Path, PathBuf};
+
+/// Files with identical contents (listed by --duplicates-report)
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct DuplicateGroup {
+    /// Size of each copy in bytes
+    pub size: u64,
+    /// Display paths of the copies, sorted
+    pub paths: Vec<PathBuf>,
+}
+
+impl DuplicateGroup {
+    /// Bytes that would be freed by keeping a single copy
+    pub fn wasted(&self) -> u64 {
+        self.size * (self.paths.len() as u64 - 1)
+    }
+}
+
+/// Group `files` with identical contents, most wasted space first. Only
+/// files sharing a size are read and hashed; empty, special and
+/// --from-json files are never grouped.
+pub fn find_duplicates(files: &[&IrFile]) -> Vec<DuplicateGroup> {
+    let mut by_size: HashMap<u64, Vec<&IrFile>> = HashMap::new();
+    for file in files {
+        if file.size_bytes > 0 && file.special.is_none() && file.embedded.is_none() {
+            by_size.entry(file.size_bytes).or_default().push(file);
+        }
+    }
+
+    let mut by_hash: HashMap<(u64, u64), Vec<&Path>> = HashMap::new();
+    let mut display: HashMap<&Path, &PathBuf> = HashMap::new();
+    for (size, same_size) in by_size.into_iter().filter(|(_, f)| f.len() > 1) {
+        for file in same_size {
+            if let Some(hash) = hash_file(&file.path) {
+                by_hash.entry((size, hash)).or_default().push(&file.path);
+                display.insert(&file.path, &file.display_path);
+            }
+        }
+    }
+
+    // A shared hash is only a hint; confirm byte equality before grouping
+    let buckets = by_hash
+        .into_iter()
+        .filter(|(_, paths)| paths.len() > 1)
+        .flat_map(|((size, _), paths)| {
+            split_identical(&paths)
+                .into_iter()
+                .map(move |same| (size, same))
+        })
+        .map(|(size, same)| {
+            let paths = same.into_iter().map(|p| display[p].clone()).collect();
+            (size, paths)
+        })
+        .collect();
+
+    group_by_content(buckets)
+}
+
+/// Split `paths` into classes of byte-identical files. Files that can no
+/// longer be read are dropped.
+fn split_identical<'a>(paths: &[&'a Path]) -> Vec<Vec<&'a Path>> {
+    let mut classes: Vec<(Vec<u8>, Vec<&Path>)> = Vec::new();
+    for &path in paths {
+        let Ok(bytes) = std::fs::read(path) else {
+            continue;
+        };
+        match classes.iter_mut().find(|(first, _)| *first == bytes) {
+            Some((_, same)) => same.push(path),
+            None => classes.push((bytes, vec![path])),
+        }
+    }
+    classes.into_iter().map(|(_, same)| same).collect()
+}
+
+/// Turn (size, paths) buckets of identical files into sorted groups of
+/// two or more
+fn group_by_content(buckets: Vec<(u64, Vec<PathBuf>)>) -> Vec<DuplicateGroup> {
+    let mut groups: Vec<DuplicateGroup> = buckets
+        .into_iter()
+        .filter(|(_, paths)| paths.len() > 1)
+        .map(|(size, mut paths)| {
+            paths.sort();
+            DuplicateGroup { size, paths }
+        })
+        .collect();
+    groups.sort_by(|a, b| {
+        b.wasted()
+            .cmp(&a.wasted())
+            .then_with(|| a.paths.cmp(&b.paths))
+    });
+    groups
+}
+
+fn hash_file(path: &Path) -> Option<u64> {
+    let bytes = std::fs::read(path).ok()?;
+    let mut hasher = DefaultHasher::new();
+    hasher.write(&bytes);
+    Some(hasher.finish())
+}
+
+/// Markdown list of duplicate groups, e.g.
+/// "- Group 1 (3 copies, 1.2 MB wasted): a.bin, b.bin, c.bin"
+pub fn to_markdown(groups: &[DuplicateGroup]) -> String {
+    let mut out = String::from("**Duplicates**\n\n");
+    if groups.is_empty() {
+        out.push_str("No duplicate files.\n");
+        return out;
+    }
+    for (i, group) in groups.iter().enumerate() {
+        let paths: Vec<String> = group
+            .paths
+            .iter()
+            .map(|p| p.display().to_string())
+            .collect();
+        out.push_str(&format!(
+            "- Group {} ({} copies, {} wasted): {}\n",
+            i + 1,
+            group.paths.len(),
+            format_size(group.wasted()),
+            paths.join(", ")
+        ));
+    }
+    out
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_groups_sorted_by_waste() {
+        let buckets = vec![
+            (100, vec![PathBuf::from("b.txt"), PathBuf::from("a.txt")]),
+            (
+                10,
+                vec!["x", "y", "z"].into_iter().map(PathBuf::from).collect(),
+            ),
+            (5000, vec![PathBuf::from("unique.bin")]),
+            (300, vec![PathBuf::from("c.bin"), PathBuf::from("d.bin")]),
+        ];
+        let groups = group_by_content(buckets);
+        let wasted: Vec<u64> = groups.iter().map(DuplicateGroup::wasted).collect();
+        assert_eq!(wasted, vec![300, 100, 20]);
+        assert_eq!(
+            groups[1].paths,
+            vec![PathBuf::from("a.txt"), PathBuf::from("b.txt")]
+        );
+    }
+
+    #[test]
+    fn test_split_identical_separates_hash_collisions() {
+        let temp = tempfile::TempDir::new().unwrap();
+        let write = |name: &str, body: &str| {
+            let path = temp.path().join(name);
+            std::fs::write(&path, body).unwrap();
+            path
+        };
+        let a = write("a", "same");
+        let b = write("b", "diff");
+        let c = write("c", "same");
+        let missing = temp.path().join("missing");
+
+        // Pretend all four shared a hash
+        let classes = split_identical(&[&a, &b, &c, &missing]);
+        assert_eq!(
+            classes,
+            vec![vec![a.as_path(), c.as_path()], vec![b.as_path()]]
+        );
+    }
+
+    #[test]
+    fn test_markdown() {
+        let groups = vec![DuplicateGroup {
+            size: 1024,
+            paths: vec![PathBuf::from("a.bin"), PathBuf::from("b/a.bin")],
+        }];
+        assert_eq!(
+            to_markdown(&groups),
+            "**Duplicates**\n\n- Group 1 (2 copies, 1.0 KB wasted): a.bin, b/a.bin\n"
+        );
+        assert_eq!(to_markdown(&[]), "**Duplicates**\n\nNo duplicate files.\n");
+    }
+}
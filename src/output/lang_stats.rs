@@ -0,0 +1,93 @@
+use crate::language::sniff::{detect_file_lang, Sniff};
+use crate::render::pipeline::IrFile;
+use crate::util::format::format_size;
+use std::collections::HashMap;
+
+/// Bucket for files whose language can't be detected
+const OTHER: &str = "other";
+
+/// Per-language file counts and bytes (written by --lang-stats)
+#[derive(Debug, Default, PartialEq)]
+pub struct LanguageStats {
+    languages: HashMap<String, LanguageTotals>,
+    total_bytes: u64,
+}
+
+#[derive(Debug, Default, PartialEq)]
+struct LanguageTotals {
+    files: usize,
+    bytes: u64,
+}
+
+impl LanguageStats {
+    /// Aggregate `files` by detected language
+    pub fn from_files(files: &[&IrFile], sniff: Sniff) -> Self {
+        let mut stats = Self::default();
+        for file in files {
+            let lang = detect_file_lang(&file.path, sniff).map_or(OTHER, |lang| lang.name);
+            stats.add(lang, file.size_bytes);
+        }
+        stats
+    }
+
+    fn add(&mut self, language: &str, bytes: u64) {
+        let totals = self.languages.entry(language.to_string()).or_default();
+        totals.files += 1;
+        totals.bytes += bytes;
+        self.total_bytes += bytes;
+    }
+
+    /// Markdown table of languages, largest first ("other" always last)
+    pub fn to_markdown(&self) -> String {
+        let mut rows: Vec<(&String, &LanguageTotals)> = self.languages.iter().collect();
+        rows.sort_by(|a, b| {
+            (a.0 == OTHER)
+                .cmp(&(b.0 == OTHER))
+                .then(b.1.bytes.cmp(&a.1.bytes))
+                .then(a.0.cmp(b.0))
+        });
+
+        let mut out = String::from("**Languages**\n\n");
+        out.push_str("| Language | Files | Size | Share |\n");
+        out.push_str("|----------|------:|-----:|------:|\n");
+        for (name, totals) in rows {
+            let share = if self.total_bytes == 0 {
+                0.0
+            } else {
+                totals.bytes as f64 * 100.0 / self.total_bytes as f64
+            };
+            out.push_str(&format!(
+                "| {} | {} | {} | {:.1}% |\n",
+                name,
+                totals.files,
+                format_size(totals.bytes),
+                share
+            ));
+        }
+        out
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_markdown_orders_by_size_with_other_last() {
+        let mut stats = LanguageStats::default();
+        stats.add("rust", 100);
+        stats.add(OTHER, 500);
+        stats.add("python", 300);
+        stats.add("rust", 100);
+
+        assert_eq!(
+            stats.to_markdown(),
+            "**Languages**\n\n\
+             | Language | Files | Size | Share |\n\
+             |----------|------:|-----:|------:|\n\
+             | python | 1 | 300 B | 30.0% |\n\
+             | rust | 2 | 200 B | 20.0% |\n\
+             | other | 1 | 500 B | 50.0% |\n"
+        );
+    }
+}
@@ -1 +1,9 @@
+pub mod duplicates;
+pub mod lang_stats;
+pub mod pager;
+pub mod progress_bar;
 pub mod stats;
+pub mod summary;
+pub mod update;
+pub mod utf8_report;
+pub mod writer;
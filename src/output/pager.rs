@@ -0,0 +1,145 @@
+//! `--pager`: send the output through `$PAGER` (or `less`) when stdout is
+//! a terminal.
+
+use std::io::{self, Write};
+use std::path::Path;
+use std::process::{Child, ChildStdin, Command, Stdio};
+
+/// Pager used when $PAGER is unset or empty
+const DEFAULT_PAGER: &str = "less";
+
+/// The pager command line: $PAGER if set and non-empty, else `less`
+pub fn pager_command(env_pager: Option<String>) -> String {
+    env_pager
+        .map(|p| p.trim().to_string())
+        .filter(|p| !p.is_empty())
+        .unwrap_or_else(|| DEFAULT_PAGER.to_string())
+}
+
+/// A running pager reading the output on its stdin
+pub struct Pager {
+    child: Child,
+    stdin: Option<ChildStdin>,
+}
+
+impl Pager {
+    /// Start `command` through the shell so $PAGER may carry arguments
+    /// ("less -S"). `less` gets LESS=FRX unless the user set LESS: quit
+    /// when the output fits one screen, keep colors, leave the screen as is.
+    /// Fails with NotFound when the program is not installed, so the
+    /// caller can print to stdout instead of into a shell that exits.
+    pub fn spawn(command: &str) -> io::Result<Self> {
+        let program = command.split_whitespace().next().unwrap_or(command);
+        if !is_installed(program) {
+            return Err(io::Error::new(
+                io::ErrorKind::NotFound,
+                format!("'{}' not found", program),
+            ));
+        }
+        let mut cmd = if cfg!(windows) {
+            let mut cmd = Command::new("cmd");
+            cmd.args(["/C", command]);
+            cmd
+        } else {
+            let mut cmd = Command::new("sh");
+            cmd.args(["-c", command]);
+            cmd
+        };
+        if std::env::var_os("LESS").is_none() {
+            cmd.env("LESS", "FRX");
+        }
+        let mut child = cmd.stdin(Stdio::piped()).spawn()?;
+        let stdin = child.stdin.take();
+        Ok(Self { child, stdin })
+    }
+
+    /// Close the pager's input and wait for the user to quit it
+    pub fn finish(mut self) -> io::Result<()> {
+        drop(self.stdin.take());
+        self.child.wait().map(|_| ())
+    }
+}
+
+/// Whether `program` is a path to a file or found on $PATH
+fn is_installed(program: &str) -> bool {
+    let path = Path::new(program);
+    if path.components().count() > 1 {
+        return path.is_file();
+    }
+    std::env::var_os("PATH").is_some_and(|paths| {
+        std::env::split_paths(&paths).any(|dir| {
+            dir.join(program).is_file() || dir.join(format!("{}.exe", program)).is_file()
+        })
+    })
+}
+
+impl Write for Pager {
+    fn write(&mut self, buf: &[u8]) -> io::Result<usize> {
+        match self.stdin.as_mut() {
+            // Quitting the pager early closes the pipe; drop the rest
+            Some(stdin) => match stdin.write(buf) {
+                Err(e) if e.kind() == io::ErrorKind::BrokenPipe => {
+                    self.stdin = None;
+                    Ok(buf.len())
+                }
+                result => result,
+            },
+            None => Ok(buf.len()),
+        }
+    }
+
+    fn flush(&mut self) -> io::Result<()> {
+        match self.stdin.as_mut() {
+            Some(stdin) => match stdin.flush() {
+                Err(e) if e.kind() == io::ErrorKind::BrokenPipe => {
+                    self.stdin = None;
+                    Ok(())
+                }
+                result => result,
+            },
+            None => Ok(()),
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_pager_command() {
+        assert_eq!(pager_command(None), "less");
+        assert_eq!(pager_command(Some("  ".to_string())), "less");
+        assert_eq!(pager_command(Some("more -d".to_string())), "more -d");
+    }
+
+    #[test]
+    fn test_missing_pager_is_not_found() {
+        let err = Pager::spawn("no-such-pager-tree2md -R").err().unwrap();
+        assert_eq!(err.kind(), io::ErrorKind::NotFound);
+    }
+
+    #[cfg(unix)]
+    #[test]
+    fn test_output_reaches_the_pager() {
+        let dir = tempfile::tempdir().unwrap();
+        let sink = dir.path().join("paged.txt");
+        let mut pager = Pager::spawn(&format!("cat > '{}'", sink.display())).unwrap();
+        pager.write_all(b"line 1\nline 2\n").unwrap();
+        pager.finish().unwrap();
+        assert_eq!(std::fs::read_to_string(sink).unwrap(), "line 1\nline 2\n");
+    }
+
+    #[cfg(unix)]
+    #[test]
+    fn test_pager_quitting_early_is_not_an_error() {
+        // `true` exits without reading, like quitting less on the first page
+        let mut pager = Pager::spawn("true").unwrap();
+        std::thread::sleep(std::time::Duration::from_millis(100));
+        for _ in 0..64 {
+            pager.write_all(&[b'x'; 4096]).unwrap();
+        }
+        pager.flush().unwrap();
+        pager.finish().unwrap();
+    }
+}
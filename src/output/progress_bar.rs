@@ -0,0 +1,77 @@
+use std::io::Write;
+
+/// Width of the bar between the brackets, in characters
+const BAR_WIDTH: usize = 20;
+
+/// Determinate progress bar for the content pass (--progress-bar), redrawn
+/// in place on one line: "[########------------] 42/100 files". The line
+/// is ended once every file is done.
+pub struct ProgressBar<W: Write> {
+    out: W,
+    done: usize,
+    total: usize,
+}
+
+impl<W: Write> ProgressBar<W> {
+    pub fn new(out: W, total: usize) -> Self {
+        Self {
+            out,
+            done: 0,
+            total,
+        }
+    }
+
+    /// Count one more file as loaded and redraw the bar
+    pub fn advance(&mut self) {
+        if self.done >= self.total {
+            return;
+        }
+        self.done += 1;
+        // Progress is best-effort: a closed stderr must not fail the run
+        let _ = write!(self.out, "\r{}", frame(self.done, self.total));
+        if self.done == self.total {
+            let _ = writeln!(self.out);
+        }
+        let _ = self.out.flush();
+    }
+}
+
+/// One frame of the bar for `done` of `total` files
+fn frame(done: usize, total: usize) -> String {
+    let filled = (done * BAR_WIDTH).checked_div(total).unwrap_or(BAR_WIDTH);
+    format!(
+        "[{}{}] {}/{} files",
+        "#".repeat(filled),
+        "-".repeat(BAR_WIDTH - filled),
+        done,
+        total
+    )
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_frame_fills_proportionally() {
+        assert_eq!(frame(0, 4), "[--------------------] 0/4 files");
+        assert_eq!(frame(1, 4), "[#####---------------] 1/4 files");
+        assert_eq!(frame(4, 4), "[####################] 4/4 files");
+    }
+
+    #[test]
+    fn test_bar_updates_to_completion() {
+        let mut out = Vec::new();
+        let mut bar = ProgressBar::new(&mut out, 3);
+        for _ in 0..4 {
+            // The extra call past the total is ignored
+            bar.advance();
+        }
+
+        let text = String::from_utf8(out).unwrap();
+        let frames: Vec<&str> = text.split('\r').skip(1).collect();
+        assert_eq!(frames.len(), 3, "{:?}", text);
+        assert_eq!(frames[0], "[######--------------] 1/3 files");
+        assert_eq!(frames[2], "[####################] 3/3 files\n");
+    }
+}
@@ -1,6 +1,7 @@
 use crate::cli::StatsMode;
 use crate::profile::FileType;
 use crate::terminal::capabilities::ProgressChars;
+use serde_json::{Map, Value};
 use std::collections::HashMap;
 use std::path::Path;
 
@@ -15,6 +16,7 @@ pub struct Stats {
     total_dirs: usize,
     total_files: usize,
     total_loc: Option<usize>,
+    total_bytes: u64,
 }
 
 #[derive(Default)]
@@ -34,6 +36,7 @@ impl Stats {
             total_dirs: 0,
             total_files: 0,
             total_loc: None,
+            total_bytes: 0,
         }
     }
 
@@ -44,6 +47,7 @@ impl Stats {
         self.total_dirs = 0;
         self.total_files = 0;
         self.total_loc = None;
+        self.total_bytes = 0;
     }
 
     /// Add a file with its type
@@ -93,6 +97,34 @@ impl Stats {
         self.total_loc = Some(self.total_loc.unwrap_or(0) + lines);
     }
 
+    /// Add a file's size to the total
+    pub fn add_bytes(&mut self, bytes: u64) {
+        self.total_bytes += bytes;
+    }
+
+    /// Stats as a JSON object (for --format json). Per-language `lines`
+    /// is null unless line counting (--loc) is enabled.
+    pub fn to_json(&self) -> Value {
+        let mut types: Vec<&TypeStats> = self.file_types.values().collect();
+        types.sort_by(|a, b| a.name.cmp(&b.name));
+
+        let mut languages = Map::new();
+        for stats in types {
+            let mut language = Map::new();
+            language.insert("files".to_string(), Value::from(stats.count));
+            language.insert("lines".to_string(), Value::from(stats.loc));
+            languages.insert(stats.name.clone(), Value::Object(language));
+        }
+
+        let mut json = Map::new();
+        json.insert("directories".to_string(), Value::from(self.total_dirs));
+        json.insert("files".to_string(), Value::from(self.total_files));
+        json.insert("totalSize".to_string(), Value::from(self.total_bytes));
+        json.insert("lines".to_string(), Value::from(self.total_loc));
+        json.insert("languages".to_string(), Value::Object(languages));
+        Value::Object(json)
+    }
+
     /// Generate stats output based on mode
     pub fn generate_output(&self, mode: StatsMode, use_unicode: bool) -> String {
         match mode {
@@ -0,0 +1,133 @@
+use crate::fs_tree::Node;
+use crate::util::format::format_size;
+use serde_json::{Map, Value};
+use std::collections::BTreeMap;
+use std::io;
+use std::path::Path;
+
+/// Machine-readable summary of a built tree (written by --summary-json)
+#[derive(Debug, Default, PartialEq)]
+pub struct TreeSummary {
+    pub dirs: usize,
+    pub files: usize,
+    pub total_bytes: u64,
+    /// Depth of the deepest entry (direct children of the root are depth 1)
+    pub max_depth: usize,
+    /// File count per lowercase extension ("(no ext)" for none)
+    pub extensions: BTreeMap<String, usize>,
+}
+
+impl TreeSummary {
+    pub fn from_tree(root: &Node) -> Self {
+        let mut summary = Self::default();
+        summary.visit(root, 0);
+        summary
+    }
+
+    fn visit(&mut self, node: &Node, depth: usize) {
+        for child in &node.children {
+            self.max_depth = self.max_depth.max(depth + 1);
+            if child.is_dir {
+                self.dirs += 1;
+                self.visit(child, depth + 1);
+            } else {
+                self.files += 1;
+                self.total_bytes += match &child.embedded {
+                    Some(embedded) => embedded.size,
+                    None => std::fs::metadata(&child.path).map(|m| m.len()).unwrap_or(0),
+                };
+
+                let ext = Path::new(&child.name)
+                    .extension()
+                    .map(|e| e.to_string_lossy().to_lowercase())
+                    .unwrap_or_else(|| "(no ext)".to_string());
+                *self.extensions.entry(ext).or_insert(0) += 1;
+            }
+        }
+    }
+
+    pub fn to_json(&self) -> Value {
+        let extensions: Map<String, Value> = self
+            .extensions
+            .iter()
+            .map(|(ext, count)| (ext.clone(), Value::from(*count)))
+            .collect();
+
+        let mut summary = Map::new();
+        summary.insert("dirs".to_string(), Value::from(self.dirs));
+        summary.insert("files".to_string(), Value::from(self.files));
+        summary.insert("totalBytes".to_string(), Value::from(self.total_bytes));
+        summary.insert("maxDepth".to_string(), Value::from(self.max_depth));
+        summary.insert("extensions".to_string(), Value::Object(extensions));
+        Value::Object(summary)
+    }
+
+    /// One-line summary for stderr (--stderr-summary), e.g.
+    /// "tree2md: 42 files, 5 dirs, 310.0 KB total"
+    pub fn to_line(&self) -> String {
+        format!(
+            "tree2md: {} files, {} dirs, {} total",
+            self.files,
+            self.dirs,
+            format_size(self.total_bytes)
+        )
+    }
+
+    /// Write the summary as pretty-printed JSON to `path`
+    pub fn write_json(&self, path: &Path) -> io::Result<()> {
+        let mut json = serde_json::to_string_pretty(&self.to_json()).map_err(io::Error::other)?;
+        json.push('\n');
+        std::fs::write(path, json)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::path::PathBuf;
+
+    fn node(name: &str, is_dir: bool, children: Vec<Node>) -> Node {
+        let mut node = Node::new(name.to_string(), PathBuf::from(name), is_dir);
+        node.children = children;
+        node
+    }
+
+    #[test]
+    fn test_summary_counts_and_depth() {
+        let root = node(
+            ".",
+            true,
+            vec![
+                node(
+                    "src",
+                    true,
+                    vec![node("cmd", true, vec![node("main.go", false, vec![])])],
+                ),
+                node("README.md", false, vec![]),
+                node("Makefile", false, vec![]),
+            ],
+        );
+
+        let summary = TreeSummary::from_tree(&root);
+        assert_eq!(summary.dirs, 2);
+        assert_eq!(summary.files, 3);
+        assert_eq!(summary.max_depth, 3);
+        assert_eq!(summary.extensions.get("go"), Some(&1));
+        assert_eq!(summary.extensions.get("md"), Some(&1));
+        assert_eq!(summary.extensions.get("(no ext)"), Some(&1));
+    }
+
+    #[test]
+    fn test_summary_line() {
+        let summary = TreeSummary {
+            dirs: 5,
+            files: 42,
+            total_bytes: 310 * 1024,
+            ..Default::default()
+        };
+        assert_eq!(
+            summary.to_line(),
+            "tree2md: 42 files, 5 dirs, 310.0 KB total"
+        );
+    }
+}
@@ -0,0 +1,105 @@
+use std::io;
+use std::path::Path;
+
+/// Marker opening the generated block when --content-prefix is not set
+pub const DEFAULT_BEGIN_MARKER: &str = "<!-- BEGIN TREE2MD -->";
+/// Marker closing the generated block when --content-suffix is not set
+pub const DEFAULT_END_MARKER: &str = "<!-- END TREE2MD -->";
+
+/// Replace the text between `begin` and `end` marker lines in `document`
+/// with `body`, preserving everything else. Appends a new block when the
+/// document has no `begin` marker.
+pub fn splice_block(document: &str, body: &str, begin: &str, end: &str) -> io::Result<String> {
+    let mut body = body.to_string();
+    if !body.is_empty() && !body.ends_with('\n') {
+        body.push('\n');
+    }
+
+    let Some(begin_pos) = document.find(begin) else {
+        let mut result = document.to_string();
+        if !result.is_empty() {
+            if !result.ends_with('\n') {
+                result.push('\n');
+            }
+            result.push('\n');
+        }
+        result.push_str(&format!("{}\n{}{}\n", begin, body, end));
+        return Ok(result);
+    };
+
+    // Keep the rest of the BEGIN marker's line
+    let after_begin = begin_pos + begin.len();
+    let body_start = document[after_begin..]
+        .find('\n')
+        .map_or(document.len(), |i| after_begin + i + 1);
+
+    let end_pos = document[body_start..]
+        .find(end)
+        .map(|i| body_start + i)
+        .ok_or_else(|| {
+            io::Error::new(
+                io::ErrorKind::InvalidData,
+                format!("found '{}' without a matching '{}'", begin, end),
+            )
+        })?;
+
+    let mut result = String::with_capacity(document.len() + body.len());
+    result.push_str(&document[..body_start]);
+    if body_start == document.len() && !document.ends_with('\n') {
+        result.push('\n');
+    }
+    result.push_str(&body);
+    result.push_str(&document[end_pos..]);
+    Ok(result)
+}
+
+/// Splice `body` into the marker block of the file at `path`, creating the
+/// file if it doesn't exist.
+pub fn update_file(path: &Path, body: &str, begin: &str, end: &str) -> io::Result<()> {
+    let document = match std::fs::read_to_string(path) {
+        Ok(document) => document,
+        Err(e) if e.kind() == io::ErrorKind::NotFound => String::new(),
+        Err(e) => return Err(e),
+    };
+    let updated = splice_block(&document, body, begin, end)?;
+    std::fs::write(path, updated)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const BEGIN: &str = DEFAULT_BEGIN_MARKER;
+    const END: &str = DEFAULT_END_MARKER;
+
+    #[test]
+    fn test_splice_replaces_existing_block() {
+        let doc = "# Title\n\n<!-- BEGIN TREE2MD -->\nold\n<!-- END TREE2MD -->\n\nFooter\n";
+        let result = splice_block(doc, "new\n", BEGIN, END).unwrap();
+        assert_eq!(
+            result,
+            "# Title\n\n<!-- BEGIN TREE2MD -->\nnew\n<!-- END TREE2MD -->\n\nFooter\n"
+        );
+    }
+
+    #[test]
+    fn test_splice_appends_missing_block() {
+        let result = splice_block("# Title", "tree", BEGIN, END).unwrap();
+        assert_eq!(
+            result,
+            "# Title\n\n<!-- BEGIN TREE2MD -->\ntree\n<!-- END TREE2MD -->\n"
+        );
+
+        let result = splice_block("", "tree\n", BEGIN, END).unwrap();
+        assert_eq!(
+            result,
+            "<!-- BEGIN TREE2MD -->\ntree\n<!-- END TREE2MD -->\n"
+        );
+    }
+
+    #[test]
+    fn test_splice_rejects_unterminated_block() {
+        let doc = "<!-- BEGIN TREE2MD -->\nold\n";
+        assert!(splice_block(doc, "new\n", BEGIN, END).is_err());
+    }
+}
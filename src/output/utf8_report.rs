@@ -0,0 +1,86 @@
+use crate::content::io::is_binary_extension;
+use crate::render::pipeline::IrFile;
+use std::path::{Path, PathBuf};
+
+/// A text file that is not valid UTF-8 (listed by --validate-utf8)
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct InvalidUtf8 {
+    pub path: PathBuf,
+    /// Byte offset of the first invalid sequence
+    pub offset: usize,
+}
+
+/// Text files in `files` that are not valid UTF-8, in tree order. Special
+/// files, binary extensions and files holding NUL bytes are not checked.
+pub fn find_invalid_utf8(files: &[&IrFile]) -> Vec<InvalidUtf8> {
+    files
+        .iter()
+        .filter(|f| f.special.is_none() && f.embedded.is_none() && !is_binary_extension(&f.path))
+        .filter_map(|f| {
+            first_invalid_byte(&f.path).map(|offset| InvalidUtf8 {
+                path: f.display_path.clone(),
+                offset,
+            })
+        })
+        .collect()
+}
+
+fn first_invalid_byte(path: &Path) -> Option<usize> {
+    let bytes = std::fs::read(path).ok()?;
+    if bytes.contains(&0) {
+        return None;
+    }
+    invalid_utf8_offset(&bytes)
+}
+
+/// Offset of the first byte that is not part of valid UTF-8, if any
+fn invalid_utf8_offset(bytes: &[u8]) -> Option<usize> {
+    std::str::from_utf8(bytes).err().map(|e| e.valid_up_to())
+}
+
+/// Markdown list of offenders, e.g.
+/// "- data.txt (invalid UTF-8 at byte 1234)"
+pub fn to_markdown(files: &[InvalidUtf8]) -> String {
+    let mut out = String::from("**Invalid UTF-8**\n\n");
+    if files.is_empty() {
+        out.push_str("All text files are valid UTF-8.\n");
+        return out;
+    }
+    for file in files {
+        out.push_str(&format!(
+            "- {} (invalid UTF-8 at byte {})\n",
+            file.path.display(),
+            file.offset
+        ));
+    }
+    out
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_invalid_utf8_offset() {
+        assert_eq!(invalid_utf8_offset("héllo".as_bytes()), None);
+        assert_eq!(invalid_utf8_offset(b"ab\xffcd"), Some(2));
+        // A truncated multi-byte sequence at the end
+        assert_eq!(invalid_utf8_offset(b"abc\xc3"), Some(3));
+    }
+
+    #[test]
+    fn test_markdown() {
+        let files = vec![InvalidUtf8 {
+            path: PathBuf::from("data/rows.csv"),
+            offset: 1234,
+        }];
+        assert_eq!(
+            to_markdown(&files),
+            "**Invalid UTF-8**\n\n- data/rows.csv (invalid UTF-8 at byte 1234)\n"
+        );
+        assert_eq!(
+            to_markdown(&[]),
+            "**Invalid UTF-8**\n\nAll text files are valid UTF-8.\n"
+        );
+    }
+}
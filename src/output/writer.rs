@@ -0,0 +1,401 @@
+use crate::cli::OutputEncoding;
+use std::io::{self, Write};
+
+/// Writer that inserts `prefix` at the start of every line written through
+/// it (used for --prefix). An empty prefix passes writes straight through.
+pub struct LinePrefixWriter<W: Write> {
+    inner: W,
+    prefix: Vec<u8>,
+    at_line_start: bool,
+}
+
+impl<W: Write> LinePrefixWriter<W> {
+    pub fn new(inner: W, prefix: &str) -> Self {
+        Self {
+            inner,
+            prefix: prefix.as_bytes().to_vec(),
+            at_line_start: true,
+        }
+    }
+}
+
+impl<W: Write> Write for LinePrefixWriter<W> {
+    fn write(&mut self, buf: &[u8]) -> io::Result<usize> {
+        if self.prefix.is_empty() {
+            return self.inner.write(buf);
+        }
+
+        for line in buf.split_inclusive(|&b| b == b'\n') {
+            if self.at_line_start {
+                self.inner.write_all(&self.prefix)?;
+            }
+            self.inner.write_all(line)?;
+            self.at_line_start = line.ends_with(b"\n");
+        }
+        Ok(buf.len())
+    }
+
+    fn flush(&mut self) -> io::Result<()> {
+        self.inner.flush()
+    }
+}
+
+/// Writer that transcodes the UTF-8 written through it to the
+/// --output-encoding. UTF-16 output starts with a byte order mark.
+pub struct EncodingWriter<W: Write> {
+    inner: W,
+    encoding: OutputEncoding,
+    /// Trailing bytes of a UTF-8 sequence split across writes
+    pending: Vec<u8>,
+    wrote_bom: bool,
+}
+
+impl<W: Write> EncodingWriter<W> {
+    pub fn new(inner: W, encoding: OutputEncoding) -> Self {
+        Self {
+            inner,
+            encoding,
+            pending: Vec::new(),
+            wrote_bom: false,
+        }
+    }
+
+    fn encode_utf16(&mut self, text: &str, to_bytes: fn(u16) -> [u8; 2]) -> io::Result<()> {
+        let mut encoded = Vec::with_capacity(text.len() * 2 + 2);
+        if !self.wrote_bom {
+            encoded.extend_from_slice(&to_bytes(0xFEFF));
+            self.wrote_bom = true;
+        }
+        for unit in text.encode_utf16() {
+            encoded.extend_from_slice(&to_bytes(unit));
+        }
+        self.inner.write_all(&encoded)
+    }
+}
+
+impl<W: Write> Write for EncodingWriter<W> {
+    fn write(&mut self, buf: &[u8]) -> io::Result<usize> {
+        if self.encoding == OutputEncoding::Utf8 {
+            return self.inner.write(buf);
+        }
+
+        self.pending.extend_from_slice(buf);
+        let valid_len = match std::str::from_utf8(&self.pending) {
+            Ok(text) => text.len(),
+            // An incomplete sequence at the end waits for the next write
+            Err(e) if e.error_len().is_none() => e.valid_up_to(),
+            Err(e) => {
+                self.pending.clear();
+                return Err(io::Error::new(io::ErrorKind::InvalidData, e));
+            }
+        };
+        let rest = self.pending.split_off(valid_len);
+        let text = String::from_utf8(std::mem::replace(&mut self.pending, rest))
+            .expect("prefix was validated as UTF-8");
+
+        match self.encoding {
+            OutputEncoding::Utf16Le => self.encode_utf16(&text, u16::to_le_bytes)?,
+            OutputEncoding::Utf16Be => self.encode_utf16(&text, u16::to_be_bytes)?,
+            OutputEncoding::Utf8 => unreachable!(),
+        }
+        Ok(buf.len())
+    }
+
+    fn flush(&mut self) -> io::Result<()> {
+        self.inner.flush()
+    }
+}
+
+/// Writer that passes through whole lines until the output would exceed
+/// `limit` bytes (--max-output-bytes), then writes a footer and drops
+/// everything after it. Cutting only at line ends keeps UTF-8 sequences
+/// intact. Sizes are measured in `encoding`, as written by the
+/// EncodingWriter underneath, and the footer counts toward the limit; it is
+/// left out when not even the footer fits. A partial last line is held
+/// until a newline or `flush` completes it, or until it could no longer fit.
+pub struct CappedWriter<W: Write> {
+    inner: W,
+    limit: Option<usize>,
+    encoding: OutputEncoding,
+    written: usize,
+    pending: Vec<u8>,
+    truncated: bool,
+    /// Write the footer into the output; otherwise it goes to stderr
+    footer_in_output: bool,
+}
+
+impl<W: Write> CappedWriter<W> {
+    pub fn new(inner: W, limit: Option<usize>, encoding: OutputEncoding) -> Self {
+        Self {
+            inner,
+            limit,
+            encoding,
+            written: 0,
+            pending: Vec::new(),
+            truncated: false,
+            footer_in_output: true,
+        }
+    }
+
+    /// Report truncation on stderr instead of in the output, where a
+    /// Markdown footer would not belong (--format json, toml, xml)
+    pub fn with_footer_on_stderr(mut self) -> Self {
+        self.footer_in_output = false;
+        self
+    }
+
+    fn footer(limit: usize) -> String {
+        format!(
+            "\n_[Output truncated: --max-output-bytes {} reached]_\n",
+            limit
+        )
+    }
+
+    /// Size of `bytes` once encoded; the UTF-16 byte order mark is charged
+    /// to the first write
+    fn encoded_len(&self, bytes: &[u8]) -> usize {
+        match self.encoding {
+            OutputEncoding::Utf8 => bytes.len(),
+            OutputEncoding::Utf16Le | OutputEncoding::Utf16Be => {
+                let bom = if self.written == 0 { 2 } else { 0 };
+                bom + String::from_utf8_lossy(bytes).encode_utf16().count() * 2
+            }
+        }
+    }
+
+    /// Write `line` if it fits under `limit` with room left for the footer,
+    /// or the footer if it does not
+    fn emit(&mut self, line: &[u8], limit: usize) -> io::Result<()> {
+        let line_len = self.encoded_len(line);
+        if self.written + line_len + self.footer_len(limit) <= limit {
+            self.inner.write_all(line)?;
+            self.written += line_len;
+            Ok(())
+        } else {
+            self.truncate(limit)
+        }
+    }
+
+    /// Room the footer takes in the output
+    fn footer_len(&self, limit: usize) -> usize {
+        if self.footer_in_output {
+            self.encoded_len(Self::footer(limit).as_bytes())
+        } else {
+            0
+        }
+    }
+
+    /// Drop everything from here on and write the footer
+    fn truncate(&mut self, limit: usize) -> io::Result<()> {
+        self.truncated = true;
+        self.pending.clear();
+        if !self.footer_in_output {
+            eprintln!(
+                "Warning: output truncated: --max-output-bytes {} reached",
+                limit
+            );
+        } else if self.written + self.footer_len(limit) <= limit {
+            self.inner.write_all(Self::footer(limit).as_bytes())?;
+        }
+        Ok(())
+    }
+
+    /// Whether the partial line held in `pending` can no longer fit, even
+    /// before its newline arrives. Checked on the raw bytes, so a long line
+    /// is never buffered much past `limit`.
+    fn pending_overflows(&self, limit: usize) -> bool {
+        let min_len = match self.encoding {
+            OutputEncoding::Utf8 => self.pending.len(),
+            // UTF-16 takes at least two bytes for every three of UTF-8
+            OutputEncoding::Utf16Le | OutputEncoding::Utf16Be => self.pending.len() / 3 * 2,
+        };
+        self.written + min_len > limit
+    }
+}
+
+impl<W: Write> Write for CappedWriter<W> {
+    fn write(&mut self, buf: &[u8]) -> io::Result<usize> {
+        let Some(limit) = self.limit else {
+            return self.inner.write(buf);
+        };
+        if self.truncated {
+            return Ok(buf.len());
+        }
+
+        self.pending.extend_from_slice(buf);
+        while let Some(end) = self.pending.iter().position(|&b| b == b'\n') {
+            let line: Vec<u8> = self.pending.drain(..=end).collect();
+            self.emit(&line, limit)?;
+            if self.truncated {
+                return Ok(buf.len());
+            }
+        }
+        if self.pending_overflows(limit) {
+            self.truncate(limit)?;
+        }
+        Ok(buf.len())
+    }
+
+    fn flush(&mut self) -> io::Result<()> {
+        if let Some(limit) = self.limit {
+            if !self.truncated && !self.pending.is_empty() {
+                let line = std::mem::take(&mut self.pending);
+                self.emit(&line, limit)?;
+            }
+        }
+        self.inner.flush()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn prefixed(prefix: &str, chunks: &[&str]) -> String {
+        let mut buf = Vec::new();
+        let mut writer = LinePrefixWriter::new(&mut buf, prefix);
+        for chunk in chunks {
+            writer.write_all(chunk.as_bytes()).unwrap();
+        }
+        String::from_utf8(buf).unwrap()
+    }
+
+    fn capped(limit: Option<usize>, chunks: &[&str]) -> String {
+        let mut buf = Vec::new();
+        let mut writer = CappedWriter::new(&mut buf, limit, OutputEncoding::Utf8);
+        for chunk in chunks {
+            writer.write_all(chunk.as_bytes()).unwrap();
+        }
+        writer.flush().unwrap();
+        String::from_utf8(buf).unwrap()
+    }
+
+    #[test]
+    fn test_capped_cuts_at_line_boundary() {
+        let footer = CappedWriter::<Vec<u8>>::footer(80);
+        let lines = ["ééé line one\n", "line two\n", "line three is long\n"];
+        let out = capped(Some(80), &lines);
+        // The first two lines and the footer fit in 80 bytes; the third does not
+        assert_eq!(out, format!("{}{}{}", lines[0], lines[1], footer));
+        assert!(out.len() <= 80);
+    }
+
+    #[test]
+    fn test_capped_holds_partial_lines_until_flush() {
+        assert_eq!(capped(Some(1000), &["par", "tial\nend"]), "partial\nend");
+        assert_eq!(capped(None, &["a\n", "b"]), "a\nb");
+    }
+
+    #[test]
+    fn test_capped_drops_writes_after_truncation() {
+        let out = capped(Some(60), &["x".repeat(100).as_str(), "\nmore\n"]);
+        assert_eq!(out, CappedWriter::<Vec<u8>>::footer(60));
+    }
+
+    #[test]
+    fn test_capped_stops_buffering_a_line_that_cannot_fit() {
+        let mut buf = Vec::new();
+        let mut writer = CappedWriter::new(&mut buf, Some(60), OutputEncoding::Utf8);
+        writer.write_all(b"ok\n").unwrap();
+        for _ in 0..1000 {
+            writer.write_all(b"no newline yet ").unwrap();
+        }
+        assert!(writer.pending.is_empty());
+        assert!(writer.truncated);
+        writer.flush().unwrap();
+        drop(writer);
+        assert_eq!(
+            String::from_utf8(buf).unwrap(),
+            format!("ok\n{}", CappedWriter::<Vec<u8>>::footer(60))
+        );
+    }
+
+    #[test]
+    fn test_capped_footer_on_stderr_keeps_the_output_clean() {
+        let mut buf = Vec::new();
+        let mut writer =
+            CappedWriter::new(&mut buf, Some(8), OutputEncoding::Utf8).with_footer_on_stderr();
+        writer.write_all(b"{\n  \"a\": 1\n}\n").unwrap();
+        writer.flush().unwrap();
+        drop(writer);
+        assert_eq!(String::from_utf8(buf).unwrap(), "{\n");
+    }
+
+    #[test]
+    fn test_capped_leaves_out_a_footer_that_does_not_fit() {
+        assert_eq!(capped(Some(10), &["a\n", "b\n"]), "");
+    }
+
+    #[test]
+    fn test_capped_measures_encoded_bytes() {
+        let mut buf = Vec::new();
+        let encoder = EncodingWriter::new(&mut buf, OutputEncoding::Utf16Le);
+        let mut writer = CappedWriter::new(encoder, Some(160), OutputEncoding::Utf16Le);
+        for _ in 0..20 {
+            writer.write_all(b"line\n").unwrap();
+        }
+        writer.flush().unwrap();
+        drop(writer);
+
+        let footer: Vec<u8> = CappedWriter::<Vec<u8>>::footer(160)
+            .encode_utf16()
+            .flat_map(u16::to_le_bytes)
+            .collect();
+        assert!(buf.len() <= 160, "{} bytes", buf.len());
+        assert!(buf.ends_with(&footer));
+        // BOM (2) + footer (108) leave room for five 10-byte lines
+        assert_eq!(buf.len(), 2 + 5 * 10 + footer.len());
+    }
+
+    #[test]
+    fn test_prefixes_every_line() {
+        assert_eq!(prefixed("> ", &["a\n\nb\n"]), "> a\n> \n> b\n");
+    }
+
+    #[test]
+    fn test_lines_split_across_writes() {
+        assert_eq!(
+            prefixed("    ", &["fir", "st\nsec", "ond\n", "third"]),
+            "    first\n    second\n    third"
+        );
+    }
+
+    #[test]
+    fn test_empty_prefix_passes_through() {
+        assert_eq!(prefixed("", &["a\n", "b"]), "a\nb");
+    }
+
+    fn encoded(encoding: OutputEncoding, chunks: &[&[u8]]) -> Vec<u8> {
+        let mut buf = Vec::new();
+        let mut writer = EncodingWriter::new(&mut buf, encoding);
+        for chunk in chunks {
+            writer.write_all(chunk).unwrap();
+        }
+        buf
+    }
+
+    #[test]
+    fn test_utf16le_with_bom() {
+        assert_eq!(
+            encoded(OutputEncoding::Utf16Le, &[b"a\n"]),
+            vec![0xFF, 0xFE, b'a', 0, b'\n', 0]
+        );
+    }
+
+    #[test]
+    fn test_utf16be_split_multibyte_sequence() {
+        // "é" is 0xC3 0xA9 in UTF-8, written one byte at a time
+        assert_eq!(
+            encoded(OutputEncoding::Utf16Be, &[&[0xC3], &[0xA9]]),
+            vec![0xFE, 0xFF, 0x00, 0xE9]
+        );
+    }
+
+    #[test]
+    fn test_utf8_passes_through() {
+        assert_eq!(
+            encoded(OutputEncoding::Utf8, &["é".as_bytes()]),
+            "é".as_bytes()
+        );
+    }
+}
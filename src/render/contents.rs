@@ -0,0 +1,697 @@
+use crate::cli::{Args, ContentsMode};
+use crate::content::blame::{annotate, blame_lines, prefix_width, BlameLine};
+use crate::content::eol::normalize_eol;
+use crate::content::ext_limit::find_limit;
+use crate::content::frontmatter::{front_matter, is_markdown};
+use crate::content::indent::normalize_indent;
+use crate::content::io::{
+    is_binary_extension, is_too_large, read_lossy_text, read_to_string_timeout, run_with_timeout,
+};
+use crate::content::json::{is_json, minify_json, prettify_json};
+use crate::content::range::find_range;
+use crate::content::replace::apply_replacements;
+use crate::content::tokens::{estimate_tokens, TokenEstimator};
+use crate::content::trim::trim_blank_lines;
+use crate::content::truncate::{
+    collapse_at_indent, find_head_n, find_nest_threshold, nearest_boundary, truncate_head_lines,
+    truncate_long_lines, LineProfile, TruncationInfo, BOUNDARY_WINDOW,
+};
+use crate::language::sniff::{detect_file_lang, Sniff};
+use crate::matcher::tree2mdignore::{ContentRules, Tree2mdIgnore};
+use crate::output::progress_bar::ProgressBar;
+use crate::render::pipeline::{IrDir, IrFile};
+use globset::{GlobSet, GlobSetBuilder};
+use std::cell::{Cell, RefCell};
+use std::collections::{HashMap, HashSet};
+use std::io;
+use std::path::{Path, PathBuf};
+
+/// Contents of a single file as planned for emission under `-c`
+#[derive(Debug, Clone)]
+pub enum FileContent {
+    /// Text to emit. `truncation` is set whenever a --max-chars budget is active.
+    Text {
+        content: String,
+        truncation: Option<TruncationInfo>,
+        /// Lines in the whole file, when --content-range showed only part of it
+        range_of: Option<usize>,
+    },
+    /// Binary, special or unreadable file
+    Skipped(SkipReason),
+    /// Reading took longer than --read-timeout
+    TimedOut,
+    /// Left out because the --max-tokens budget was used up
+    OverBudget,
+    /// Left out by --content-match or --content-if-matches, or a Markdown
+    /// file without front matter under --frontmatter-only
+    Unmatched,
+}
+
+impl FileContent {
+    /// Why the contents are left out, e.g. "binary file"; None for `Text`
+    pub fn omitted_reason(&self) -> Option<String> {
+        match self {
+            FileContent::Text { .. } => None,
+            FileContent::Skipped(reason) => Some(reason.describe()),
+            FileContent::TimedOut => Some("read timed out".to_string()),
+            FileContent::OverBudget => Some("over the --max-tokens budget".to_string()),
+            FileContent::Unmatched => Some("excluded by content filters".to_string()),
+        }
+    }
+}
+
+/// Why a file's contents were not read
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum SkipReason {
+    /// Binary by extension, or not valid UTF-8
+    Binary,
+    /// FIFO, socket or device, named by its kind
+    Special(&'static str),
+    /// Opening or reading the file failed
+    Unreadable,
+}
+
+impl SkipReason {
+    /// Short reason for placeholders, e.g. "binary file"
+    pub fn describe(&self) -> String {
+        match self {
+            SkipReason::Binary => "binary file".to_string(),
+            SkipReason::Special(kind) => format!("special file ({})", kind),
+            SkipReason::Unreadable => "unreadable".to_string(),
+        }
+    }
+}
+
+/// How every file is cut down to fit the budget
+enum Strategy {
+    Head(usize),
+    Nest(usize),
+}
+
+/// Collect all files in DFS order from an IrDir tree.
+pub fn collect_files(dir: &IrDir) -> Vec<&IrFile> {
+    let mut result = Vec::new();
+    collect_files_rec(dir, &mut result);
+    result
+}
+
+fn collect_files_rec<'a>(dir: &'a IrDir, out: &mut Vec<&'a IrFile>) {
+    for subdir in &dir.dirs {
+        collect_files_rec(subdir, out);
+    }
+    for file in &dir.files {
+        out.push(file);
+    }
+}
+
+/// --git-blame data for one file
+struct FileBlame {
+    lines: Vec<BlameLine>,
+    /// Line of the file the text from `read_text` starts at
+    first_line: usize,
+}
+
+impl FileBlame {
+    /// Run `git blame` for `file`, if --git-blame applies to it.
+    /// `first_line` comes from `read_text`; when it is None a transform
+    /// rewrote the lines, which no longer match the file's history, so
+    /// blame is left out with a warning.
+    fn load(file: &IrFile, first_line: Option<usize>, args: &Args) -> Option<Self> {
+        if !args.git_blame
+            || file.embedded.is_some()
+            || is_too_large(&file.path, args.blame_max_size)
+        {
+            return None;
+        }
+        let Some(first_line) = first_line else {
+            eprintln!(
+                "Warning: --git-blame skipped for '{}': --minify-json, --pretty-json or --content-replace rewrote its lines",
+                file.display_path.display()
+            );
+            return None;
+        };
+        blame_lines(&file.path).map(|lines| Self { lines, first_line })
+    }
+
+    /// File line of each of the `count` lines of text
+    fn sources(&self, count: usize) -> Vec<Option<usize>> {
+        (self.first_line..self.first_line + count)
+            .map(Some)
+            .collect()
+    }
+}
+
+/// Text returned by `read_text`
+struct ReadText {
+    content: String,
+    /// Line of the file the text starts at, or None once a transform has
+    /// moved lines around
+    first_line: Option<usize>,
+    /// Lines read before --content-range cut them, when a range applies
+    range_of: Option<usize>,
+    /// Printed by `cut` when the text is emitted, so planning does not
+    /// repeat it
+    warning: Option<String>,
+}
+
+/// Read a file's contents, applying --normalize-eol, --frontmatter-only,
+/// --content-range, --minify-json/--pretty-json, --normalize-indent and
+/// --content-replace.
+/// Fails with the placeholder to emit instead: `Skipped` for binary, special
+/// or unreadable files, `TimedOut` when --read-timeout expires, `Unmatched`
+/// for Markdown without front matter under --frontmatter-only. Files from
+/// a --from-json tree return the contents embedded in the JSON unchanged.
+fn read_text(file: &IrFile, args: &Args) -> Result<ReadText, FileContent> {
+    if let Some(kind) = file.special {
+        return Err(FileContent::Skipped(SkipReason::Special(kind)));
+    }
+    if is_binary_extension(&file.path) {
+        return Err(FileContent::Skipped(SkipReason::Binary));
+    }
+    // A --from-json file was read and transformed by the run that wrote it
+    if let Some(embedded) = &file.embedded {
+        return embedded
+            .content
+            .clone()
+            .map(|content| ReadText {
+                content,
+                first_line: None,
+                range_of: None,
+                warning: None,
+            })
+            .ok_or(FileContent::Skipped(SkipReason::Unreadable));
+    }
+    let mut content = match read_to_string_timeout(&file.path, args.read_timeout) {
+        Ok(content) => content,
+        // Under --validate-utf8, text that is not valid UTF-8 is still shown
+        Err(e) if e.kind() == io::ErrorKind::InvalidData && args.validate_utf8 => {
+            read_lossy_text(&file.path)
+                .ok()
+                .flatten()
+                .ok_or(FileContent::Skipped(SkipReason::Binary))?
+        }
+        Err(e) => {
+            return Err(match e.kind() {
+                io::ErrorKind::TimedOut => FileContent::TimedOut,
+                io::ErrorKind::InvalidData => FileContent::Skipped(SkipReason::Binary),
+                _ => FileContent::Skipped(SkipReason::Unreadable),
+            })
+        }
+    };
+    // Normalize line endings first so every later step sees LF only
+    if args.normalize_eol {
+        content = normalize_eol(&content);
+    }
+    // Front matter starts at line 1, so --content-range still lines up
+    if args.frontmatter_only && is_markdown(&file.path) {
+        content = front_matter(&content).to_string();
+        // Nothing to show for a document without front matter
+        if content.is_empty() {
+            return Err(FileContent::Unmatched);
+        }
+    }
+    // Cut the range first so line numbers refer to the file on disk
+    let range = find_range(&args.content_range, &file.display_path);
+    let range_of = range.map(|range| {
+        let lines = content.lines().count();
+        content = range.apply(&content);
+        lines
+    });
+    let mut first_line = Some(range.map_or(1, |r| r.start));
+    let mut warning = None;
+    if (args.minify_json || args.pretty_json) && is_json(&file.path) {
+        let (flag, reformatted) = if args.minify_json {
+            ("--minify-json", minify_json(&content))
+        } else {
+            ("--pretty-json", prettify_json(&content))
+        };
+        match reformatted {
+            Some(reformatted) => {
+                content = reformatted;
+                first_line = None;
+            }
+            None => {
+                warning = Some(format!(
+                    "Warning: {}: '{}' is not valid JSON; emitted as-is",
+                    flag,
+                    file.display_path.display()
+                ))
+            }
+        }
+    }
+    // Indentation changes leave every line in place
+    if args.normalize_indent {
+        content = normalize_indent(&content, &file.path);
+    }
+    if !args.content_replace.is_empty() {
+        let replaced = apply_replacements(&content, &args.content_replace);
+        if replaced.lines().count() != content.lines().count() {
+            first_line = None;
+        }
+        content = replaced;
+    }
+    Ok(ReadText {
+        content,
+        first_line,
+        range_of,
+        warning,
+    })
+}
+
+/// Open `file` as `read_text` would, so --strict can report a read
+/// error besides the placeholder. Binary and special files are
+/// never read and always pass, as does a file that takes longer than
+/// --read-timeout to open (it gets the timeout placeholder).
+fn check_readable(file: &IrFile, args: &Args) -> io::Result<()> {
+    if file.special.is_some() || file.embedded.is_some() || is_binary_extension(&file.path) {
+        return Ok(());
+    }
+    let opened = match args.read_timeout {
+        Some(timeout) => {
+            let path = file.path.clone();
+            run_with_timeout(timeout, move || std::fs::File::open(path).map(drop))
+        }
+        None => std::fs::File::open(&file.path).map(drop),
+    };
+    opened
+        .or_else(|e| match e.kind() {
+            io::ErrorKind::TimedOut => Ok(()),
+            _ => Err(e),
+        })
+        .map_err(|e| {
+            io::Error::new(
+                e.kind(),
+                format!("cannot read '{}': {}", file.display_path.display(), e),
+            )
+        })
+}
+
+/// Path-based content filters: --content-paths, --content-match,
+/// --content-depth and the `.tree2mdignore` content directives
+struct PathFilter {
+    /// Compiled --content-match globs, if any were given
+    globs: Option<GlobSet>,
+    /// `!content:` / `content-only:` rules from the root's .tree2mdignore
+    rules: Option<ContentRules>,
+}
+
+impl PathFilter {
+    fn new(args: &Args) -> Self {
+        Self {
+            globs: content_globs(args),
+            rules: content_rules(args),
+        }
+    }
+
+    /// Whether `file` passes --content-paths, or else --content-match,
+    /// --content-depth and .tree2mdignore
+    fn selects(&self, file: &IrFile, args: &Args) -> bool {
+        if !args.content_paths.is_empty() {
+            return args
+                .content_paths
+                .iter()
+                .any(|path| path.strip_prefix(".").unwrap_or(path) == file.display_path);
+        }
+        self.globs.as_ref().is_none_or(|globs| {
+            globs.is_match(file.display_path.to_string_lossy().replace('\\', "/"))
+        }) && args
+            .content_depth
+            .is_none_or(|depth| file.display_path.components().count() <= depth)
+            && self
+                .rules
+                .as_ref()
+                .is_none_or(|rules| rules.allows(&file.display_path))
+    }
+}
+
+/// --content-match globs as one set, or None when none were given
+fn content_globs(args: &Args) -> Option<GlobSet> {
+    if args.content_match.is_empty() {
+        return None;
+    }
+    let mut builder = GlobSetBuilder::new();
+    for glob in &args.content_match {
+        builder.add(glob.clone());
+    }
+    builder.build().ok()
+}
+
+/// Content directives from the .tree2mdignore at the root, if there is one.
+/// Syntax errors were already reported when the walk's `MatcherEngine`
+/// compiled them.
+fn content_rules(args: &Args) -> Option<ContentRules> {
+    // A --from-json tree was already filtered by the run that wrote it
+    if args.from_json.is_some() {
+        return None;
+    }
+    let target = Path::new(&args.target);
+    let root = if target.is_file() {
+        target.parent()?
+    } else {
+        target
+    };
+    Tree2mdIgnore::load(root)
+        .ok()
+        .flatten()?
+        .content_rules(root)
+        .ok()
+}
+
+/// Whether content read by `read_text` passes --content-if-matches.
+/// --content-paths overrides it.
+fn is_selected(content: &str, args: &Args) -> bool {
+    if !args.content_paths.is_empty() {
+        return true;
+    }
+    args.content_if_matches
+        .as_ref()
+        .is_none_or(|pattern| pattern.is_match(content))
+}
+
+/// Head line count for `file`: its --truncate-ext limit if its extension
+/// has one, else --preview-lines if it is over --preview-larger-than
+fn preview_lines(file: &IrFile, args: &Args) -> Option<usize> {
+    if let Some(lines) = find_limit(&args.truncate_ext, &file.path) {
+        return Some(lines);
+    }
+    args.preview_larger_than
+        .filter(|&threshold| file.size_bytes > threshold)
+        .map(|_| args.preview_lines)
+}
+
+/// Cut `content` down to its preview if `file` is over --preview-larger-than.
+/// Returns the content and the number of lines the preview omitted.
+fn preview(file: &IrFile, content: String, args: &Args) -> (String, usize) {
+    match preview_lines(file, args) {
+        Some(n) => head_lines(file, &content, n, true, args),
+        None => (content, 0),
+    }
+}
+
+/// The first `n` lines of `content`. Under --truncate-at-boundary, files in
+/// a recognized language are cut at the nearest clean boundary instead,
+/// which may keep a few lines fewer than `n`, or more if `allow_longer`
+/// (not under a --max-chars budget, which `n` already just fits).
+fn head_lines(
+    file: &IrFile,
+    content: &str,
+    n: usize,
+    allow_longer: bool,
+    args: &Args,
+) -> (String, usize) {
+    let n = if args.truncate_at_boundary
+        && detect_file_lang(&file.path, Sniff::from_args(args)).is_some()
+    {
+        nearest_boundary(content, n, BOUNDARY_WINDOW, allow_longer).unwrap_or(n)
+    } else {
+        n
+    };
+    truncate_head_lines(content, n)
+}
+
+/// How file contents are cut down to fit --max-chars.
+///
+/// Planning reads each file once but keeps only its line profile, and
+/// `content_for` re-reads a file when its section is emitted, so memory
+/// stays bounded by the largest single file rather than the whole output.
+/// Previews (--preview-larger-than) are applied before the budget.
+///
+/// --max-tokens is tracked as sections are emitted: once a file would take
+/// the running estimate past the limit, it and every later file are left
+/// out as `OverBudget`.
+pub struct ContentPlan {
+    /// None when no --max-chars budget is active
+    budget: Option<Option<Strategy>>,
+    path_filter: PathFilter,
+    estimate_tokens: TokenEstimator,
+    /// Estimated tokens emitted so far
+    tokens_used: Cell<usize>,
+    token_budget_spent: Cell<bool>,
+    /// --progress-bar on stderr, advanced once per file
+    progress: Option<RefCell<ProgressBar<io::Stderr>>>,
+    /// --git-blame results loaded while planning, taken by `cut`
+    blames: RefCell<HashMap<PathBuf, Option<FileBlame>>>,
+    /// Selected files --strict found unreadable
+    read_errors: Vec<String>,
+    /// Files whose read timed out (--read-timeout) while planning
+    timed_out: HashSet<PathBuf>,
+}
+
+impl ContentPlan {
+    /// Plan the contents of `files` against --max-chars, if set. Under
+    /// --strict, selected files that cannot be read are listed in
+    /// `read_errors` (they still get a placeholder).
+    /// With --progress-bar, each `content_for` call advances a bar on stderr.
+    pub fn new(files: &[&IrFile], args: &Args) -> Self {
+        let mut plan = Self::plan(files, args);
+        if args.strict {
+            plan.read_errors = files
+                .iter()
+                .filter(|f| plan.path_filter.selects(f, args))
+                .filter_map(|f| check_readable(f, args).err())
+                .map(|e| e.to_string())
+                .collect();
+        }
+        plan.progress = args
+            .progress_bar
+            .then(|| RefCell::new(ProgressBar::new(io::stderr(), files.len())));
+        plan
+    }
+
+    fn plan(files: &[&IrFile], args: &Args) -> Self {
+        let path_filter = PathFilter::new(args);
+        let Some(max_chars) = args.max_chars else {
+            return Self::with_budget(None, path_filter);
+        };
+
+        let mut blames = HashMap::new();
+        let mut timed_out = HashSet::new();
+        let mut profiles = Vec::new();
+        for file in files.iter().filter(|f| path_filter.selects(f, args)) {
+            let ReadText {
+                content,
+                first_line,
+                ..
+            } = match read_text(file, args) {
+                Ok(read) => read,
+                // Not read again by `cut`, which would wait out the timeout twice
+                Err(FileContent::TimedOut) => {
+                    timed_out.insert(file.path.clone());
+                    continue;
+                }
+                Err(_) => continue,
+            };
+            if !is_selected(&content, args) {
+                continue;
+            }
+            // Blame is kept for `cut`, so git blame runs once per file
+            let blame = FileBlame::load(file, first_line, args);
+            let profile = LineProfile::from_content(&preview(file, content, args).0);
+            profiles.push(match &blame {
+                Some(blame) => profile.with_line_prefix(prefix_width(&blame.lines)),
+                None => profile,
+            });
+            if args.git_blame {
+                blames.insert(file.path.clone(), blame);
+            }
+        }
+
+        // Check if total fits within budget
+        let total_chars: usize = profiles.iter().map(|p| p.total_len()).sum();
+        let strategy = if total_chars <= max_chars {
+            None
+        } else {
+            Some(match args.contents_mode {
+                ContentsMode::Head => Strategy::Head(find_head_n(&profiles, max_chars)),
+                ContentsMode::Nest => match find_nest_threshold(&profiles, max_chars) {
+                    Some(t) => Strategy::Nest(t),
+                    // Nest couldn't fit even at threshold=0, fall back to head
+                    None => Strategy::Head(find_head_n(&profiles, max_chars)),
+                },
+            })
+        };
+
+        let mut plan = Self::with_budget(Some(strategy), path_filter);
+        plan.blames = RefCell::new(blames);
+        plan.timed_out = timed_out;
+        plan
+    }
+
+    fn with_budget(budget: Option<Option<Strategy>>, path_filter: PathFilter) -> Self {
+        Self {
+            budget,
+            path_filter,
+            estimate_tokens,
+            tokens_used: Cell::new(0),
+            token_budget_spent: Cell::new(false),
+            progress: None,
+            blames: RefCell::default(),
+            read_errors: Vec::new(),
+            timed_out: HashSet::new(),
+        }
+    }
+
+    /// Selected files --strict found unreadable, e.g. `cannot read 'a.rs': ...`
+    pub fn read_errors(&self) -> &[String] {
+        &self.read_errors
+    }
+
+    /// Read `file` and cut it according to the plan, or leave it out if it
+    /// doesn't fit the remaining --max-tokens budget.
+    pub fn content_for(&self, file: &IrFile, args: &Args) -> FileContent {
+        let content = self.next_content(file, args);
+        if let Some(progress) = &self.progress {
+            progress.borrow_mut().advance();
+        }
+        content
+    }
+
+    fn next_content(&self, file: &IrFile, args: &Args) -> FileContent {
+        if self.token_budget_spent.get() {
+            return FileContent::OverBudget;
+        }
+        let content = self.cut(file, args);
+        let (Some(max_tokens), FileContent::Text { content: text, .. }) =
+            (args.max_tokens, &content)
+        else {
+            return content;
+        };
+
+        let used = self.tokens_used.get() + (self.estimate_tokens)(text);
+        if used > max_tokens {
+            self.token_budget_spent.set(true);
+            return FileContent::OverBudget;
+        }
+        self.tokens_used.set(used);
+        content
+    }
+
+    /// Read `file` and cut it according to the plan. --trim-blank-lines is
+    /// applied last, so truncation is still planned on the original file.
+    /// --max-line-length and --git-blame apply to the lines that are kept.
+    fn cut(&self, file: &IrFile, args: &Args) -> FileContent {
+        if !self.path_filter.selects(file, args) {
+            return FileContent::Unmatched;
+        }
+        if self.timed_out.contains(&file.path) {
+            return FileContent::TimedOut;
+        }
+        let ReadText {
+            content: original,
+            first_line,
+            range_of,
+            warning,
+        } = match read_text(file, args) {
+            Ok(read) => read,
+            Err(placeholder) => return placeholder,
+        };
+        if !is_selected(&original, args) {
+            return FileContent::Unmatched;
+        }
+        if let Some(warning) = warning {
+            eprintln!("{}", warning);
+        }
+        let blame = match self.blames.borrow_mut().remove(&file.path) {
+            Some(blame) => blame,
+            None => FileBlame::load(file, first_line, args),
+        };
+        // File line of each line shown, tracked through every cut
+        let mut sources = blame
+            .as_ref()
+            .map(|b| b.sources(original.lines().count()))
+            .unwrap_or_default();
+
+        let (previewed, preview_omitted) = preview(file, original.clone(), args);
+        if self.budget.is_none() && preview_omitted == 0 {
+            let content = if args.trim_blank_lines {
+                trim_sources(&original, &mut sources);
+                trim_blank_lines(&original).0
+            } else {
+                original
+            };
+            return FileContent::Text {
+                content: finish(content, blame.as_ref(), &sources, args),
+                truncation: None,
+                range_of,
+            };
+        }
+        sources.truncate(previewed.lines().count());
+
+        let (content, omitted, kind) = match self.budget {
+            Some(Some(Strategy::Head(n))) => {
+                let (truncated, omitted) = head_lines(file, &previewed, n, false, args);
+                sources.truncate(truncated.lines().count());
+                (truncated, omitted, "head")
+            }
+            Some(Some(Strategy::Nest(t))) => {
+                let lines: Vec<&str> = previewed.lines().collect();
+                let (collapsed, omitted, kept) = collapse_at_indent(&lines, t);
+                if !sources.is_empty() {
+                    sources = kept.iter().map(|k| k.and_then(|i| sources[i])).collect();
+                }
+                (collapsed, omitted, "nest")
+            }
+            Some(None) | None => (previewed, 0, "none"),
+        };
+        // Report a preview unless the budget cut the file further
+        let kind = if preview_omitted > 0 && omitted == 0 {
+            "preview"
+        } else {
+            kind
+        };
+
+        let mut info = TruncationInfo::new(&original, &content, omitted + preview_omitted, kind);
+        let content = if args.trim_blank_lines {
+            // Trimmed lines leave both counts, so the omitted count is unchanged
+            let (trimmed, removed) = trim_blank_lines(&content);
+            trim_sources(&content, &mut sources);
+            info.shown_lines = info.shown_lines.saturating_sub(removed);
+            info.total_lines = info.total_lines.saturating_sub(removed);
+            info.total_bytes = info
+                .total_bytes
+                .saturating_sub(content.len() - trimmed.len());
+            info.shown_bytes = trimmed.len();
+            trimmed
+        } else {
+            content
+        };
+        FileContent::Text {
+            content: finish(content, blame.as_ref(), &sources, args),
+            truncation: Some(info),
+            range_of,
+        }
+    }
+}
+
+/// Drop the entries of `sources` for the blank lines `trim_blank_lines`
+/// removes from `content`.
+fn trim_sources(content: &str, sources: &mut Vec<Option<usize>>) {
+    let lines: Vec<&str> = content.lines().collect();
+    let Some(first) = lines.iter().position(|l| !l.trim().is_empty()) else {
+        sources.clear();
+        return;
+    };
+    let last = lines
+        .iter()
+        .rposition(|l| !l.trim().is_empty())
+        .unwrap_or(first);
+    sources.truncate(last + 1);
+    sources.drain(..first.min(sources.len()));
+}
+
+/// Apply --max-line-length and then --git-blame to the lines being shown.
+fn finish(
+    content: String,
+    blame: Option<&FileBlame>,
+    sources: &[Option<usize>],
+    args: &Args,
+) -> String {
+    let content = match args.max_line_length {
+        Some(max) => truncate_long_lines(&content, max),
+        None => content,
+    };
+    match blame {
+        Some(blame) => annotate(&content, &blame.lines, sources),
+        None => content,
+    }
+}
@@ -0,0 +1,151 @@
+//! `--export-dir`: one Markdown page per file, laid out like the source
+//! tree, plus an `index.md` tree linking to every page.
+
+use crate::cli::Args;
+use crate::content::bom::{has_bom, BOM_NOTE};
+use crate::content::truncate::{truncation_message, TruncationInfo, DEFAULT_TRUNCATION_FORMAT};
+use crate::fs_tree::{LocCounter, Node};
+use crate::language::sniff::{detect_file_lang, Sniff};
+use crate::output::stats::Stats;
+use crate::profile::EmojiMapper;
+use crate::render::contents::{collect_files, ContentPlan, FileContent};
+use crate::render::pipeline::{build_ir, AggregationContext, IrDir, IrFile};
+use std::collections::HashSet;
+use std::fs;
+use std::io;
+use std::path::{Path, PathBuf};
+
+/// Name of the linked tree written at the top of the export directory
+pub const INDEX_FILE: &str = "index.md";
+
+/// Write the export for `root` into `out_dir`, creating it as needed.
+/// Contents follow the same filters and budgets as `-c`; files without
+/// contents (binary, filtered, ...) are listed in the index but get no page.
+/// Returns the number of pages written and the files --strict found
+/// unreadable.
+pub fn export_dir(root: &Node, args: &Args, out_dir: &Path) -> io::Result<(usize, Vec<String>)> {
+    let emoji_mapper = EmojiMapper::new(false);
+    let mut stats = Stats::new();
+    let loc_counter = LocCounter::new(args.loc.clone()).with_read_timeout(args.read_timeout);
+    let mut ctx = AggregationContext {
+        emoji_mapper: &emoji_mapper,
+        stats: &mut stats,
+        loc_counter: &loc_counter,
+        dir_languages: None,
+    };
+    let ir = build_ir(root, &mut ctx);
+
+    let files = collect_files(&ir);
+    let plan = ContentPlan::new(&files, args);
+    let mut paged = HashSet::new();
+    for file in files {
+        let FileContent::Text {
+            content,
+            truncation,
+            ..
+        } = plan.content_for(file, args)
+        else {
+            continue;
+        };
+        let page = out_dir.join(page_path(file));
+        if let Some(parent) = page.parent() {
+            fs::create_dir_all(parent)?;
+        }
+        fs::write(&page, render_page(file, &content, truncation, args))?;
+        paged.insert(file.display_path.clone());
+    }
+
+    let mut index = String::from("# Index\n\n");
+    write_index(&ir, 0, &paged, &mut index);
+    fs::create_dir_all(out_dir)?;
+    fs::write(out_dir.join(INDEX_FILE), index)?;
+    Ok((paged.len(), plan.read_errors().to_vec()))
+}
+
+/// Page location relative to the export root: the file's display path
+/// with ".md" appended ("src/main.rs" -> "src/main.rs.md")
+fn page_path(file: &IrFile) -> PathBuf {
+    let mut path = file.display_path.clone().into_os_string();
+    path.push(".md");
+    PathBuf::from(path)
+}
+
+fn render_page(
+    file: &IrFile,
+    content: &str,
+    truncation: Option<TruncationInfo>,
+    args: &Args,
+) -> String {
+    let lang = detect_file_lang(&file.path, Sniff::from_args(args))
+        .map(|l| l.name)
+        .unwrap_or("");
+    let mut page = format!(
+        "# {}\n\n```{}\n{}",
+        file.display_path.display(),
+        lang,
+        content
+    );
+    if !content.ends_with('\n') {
+        page.push('\n');
+    }
+    if let Some(info) = truncation.filter(TruncationInfo::is_truncated) {
+        let template = args
+            .truncation_format
+            .as_deref()
+            .unwrap_or(DEFAULT_TRUNCATION_FORMAT);
+        page.push_str(&truncation_message(&info, template));
+        page.push('\n');
+    }
+    if args.note_bom && has_bom(content) {
+        page.push_str(BOM_NOTE);
+        page.push('\n');
+    }
+    page.push_str("```\n");
+    page
+}
+
+/// Nested list of `dir`, directories first, linking files that have a page
+fn write_index(dir: &IrDir, depth: usize, paged: &HashSet<PathBuf>, out: &mut String) {
+    let indent = "  ".repeat(depth);
+    for subdir in &dir.dirs {
+        out.push_str(&format!("{}- {}/\n", indent, subdir.name));
+        write_index(subdir, depth + 1, paged, out);
+    }
+    for file in &dir.files {
+        if paged.contains(&file.display_path) {
+            out.push_str(&format!(
+                "{}- [{}]({})\n",
+                indent,
+                file.name,
+                link_target(&page_path(file))
+            ));
+        } else {
+            out.push_str(&format!("{}- {}\n", indent, file.name));
+        }
+    }
+}
+
+/// Markdown link destination for a relative page path; wrapped in angle
+/// brackets when it has characters a bare destination cannot hold
+fn link_target(path: &Path) -> String {
+    let target = path.to_string_lossy().replace('\\', "/");
+    if target.contains([' ', '(', ')']) {
+        format!("<{}>", target)
+    } else {
+        target
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_link_target() {
+        assert_eq!(link_target(Path::new("src/main.rs.md")), "src/main.rs.md");
+        assert_eq!(
+            link_target(Path::new("docs/My Notes (v2).md.md")),
+            "<docs/My Notes (v2).md.md>"
+        );
+    }
+}
@@ -0,0 +1,226 @@
+use crate::cli::Args;
+use crate::content::truncate::TruncationInfo;
+use crate::fs_tree::{LocCounter, Node};
+use crate::language::sniff::{detect_file_lang, Sniff};
+use crate::output::stats::Stats;
+use crate::profile::EmojiMapper;
+use crate::render::contents::{collect_files, ContentPlan, FileContent};
+use crate::render::pipeline::{build_ir, AggregationContext, IrDir, IrFile};
+use crate::render::renderer::{OutputFormat, Renderer};
+use serde_json::{Map, Value};
+use std::io::{self, Write};
+
+/// JSON renderer producing a nested tree.
+/// Every node has the same shape: `name`, `path`, `type`, `language`,
+/// `lines`, `size`, `content`, and `children` (null/empty where they don't
+/// apply). File nodes carry a `truncation` object when --max-chars is set,
+/// and the root carries a `stats` object when --stats is enabled.
+/// --compact-json drops the null and empty fields instead.
+pub struct JsonRenderer<'a> {
+    args: &'a Args,
+    emoji_mapper: EmojiMapper,
+    stats: Stats,
+    loc_counter: LocCounter,
+    /// Files --strict found unreadable while writing contents
+    read_errors: Vec<String>,
+}
+
+impl<'a> JsonRenderer<'a> {
+    pub fn new(args: &'a Args) -> Self {
+        Self {
+            args,
+            emoji_mapper: EmojiMapper::new(false),
+            stats: Stats::new(),
+            loc_counter: LocCounter::new(args.loc.clone()).with_read_timeout(args.read_timeout),
+            read_errors: Vec::new(),
+        }
+    }
+
+    fn dir_value(&self, dir: &IrDir, plan: Option<&ContentPlan>) -> Value {
+        let mut children: Vec<Value> = dir.dirs.iter().map(|d| self.dir_value(d, plan)).collect();
+        children.extend(dir.files.iter().map(|f| {
+            let content = plan.map(|plan| plan.content_for(f, self.args));
+            self.file_value(f, content)
+        }));
+
+        let mut node = Map::new();
+        node.insert("name".to_string(), Value::from(dir.name.clone()));
+        node.insert("path".to_string(), path_value(&dir.display_path));
+        node.insert("type".to_string(), Value::from("dir"));
+        node.insert("language".to_string(), Value::Null);
+        node.insert("lines".to_string(), Value::Null);
+        node.insert("size".to_string(), Value::Null);
+        node.insert("content".to_string(), Value::Null);
+        node.insert("children".to_string(), Value::Array(children));
+        if let Some(hidden) = dir.collapsed {
+            node.insert("collapsed".to_string(), Value::from(hidden));
+        }
+        self.finish(node)
+    }
+
+    fn file_value(&self, file: &IrFile, content: Option<FileContent>) -> Value {
+        let omitted = content.as_ref().and_then(FileContent::omitted_reason);
+        let (content, truncation) = match content {
+            Some(FileContent::Text {
+                content,
+                truncation,
+                ..
+            }) => (Value::from(content), truncation),
+            _ => (Value::Null, None),
+        };
+
+        let mut node = Map::new();
+        node.insert("name".to_string(), Value::from(file.name.clone()));
+        node.insert("path".to_string(), path_value(&file.display_path));
+        node.insert("type".to_string(), Value::from("file"));
+        node.insert(
+            "language".to_string(),
+            Value::from(detect_file_lang(&file.path, Sniff::from_args(self.args)).map(|l| l.name)),
+        );
+        node.insert("lines".to_string(), Value::from(file.loc));
+        node.insert("size".to_string(), Value::from(file.size_bytes));
+        node.insert("content".to_string(), content);
+        node.insert("children".to_string(), Value::Array(vec![]));
+        if let Some(info) = truncation {
+            node.insert("truncation".to_string(), truncation_value(&info));
+        }
+        // Under -c, say why a file has no content
+        if let Some(reason) = omitted {
+            node.insert("omitted".to_string(), Value::from(reason));
+        }
+        self.finish(node)
+    }
+
+    /// Wrap a node, dropping null and empty-array fields under --compact-json
+    fn finish(&self, mut node: Map<String, Value>) -> Value {
+        if self.args.compact_json {
+            node.retain(|_, value| match value {
+                Value::Null => false,
+                Value::Array(items) => !items.is_empty(),
+                _ => true,
+            });
+        }
+        Value::Object(node)
+    }
+}
+
+fn path_value(path: &std::path::Path) -> Value {
+    let path = path.to_string_lossy().replace('\\', "/");
+    Value::from(if path.is_empty() {
+        ".".to_string()
+    } else {
+        path
+    })
+}
+
+fn truncation_value(info: &TruncationInfo) -> Value {
+    let mut truncation = Map::new();
+    truncation.insert("truncated".to_string(), Value::from(info.is_truncated()));
+    truncation.insert("shownLines".to_string(), Value::from(info.shown_lines));
+    truncation.insert("totalLines".to_string(), Value::from(info.total_lines));
+    truncation.insert("shownBytes".to_string(), Value::from(info.shown_bytes));
+    truncation.insert("totalBytes".to_string(), Value::from(info.total_bytes));
+    truncation.insert("type".to_string(), Value::from(info.kind));
+    Value::Object(truncation)
+}
+
+impl<'a> Renderer for JsonRenderer<'a> {
+    fn write_tree(&mut self, root: &Node, out: &mut dyn Write) -> io::Result<()> {
+        self.stats.reset();
+
+        if !root.children.is_empty() {
+            self.stats.add_directory();
+        }
+
+        let mut ctx = AggregationContext {
+            emoji_mapper: &self.emoji_mapper,
+            stats: &mut self.stats,
+            loc_counter: &self.loc_counter,
+            dir_languages: None,
+        };
+        let mut ir = build_ir(root, &mut ctx);
+        ir.name = ".".to_string();
+
+        if self.args.summary_only {
+            return writeln!(out, "{}", self.render_stats(&self.stats));
+        }
+
+        let plan = self
+            .args
+            .contents
+            .then(|| ContentPlan::new(&collect_files(&ir), self.args));
+        if let Some(plan) = &plan {
+            self.read_errors = plan.read_errors().to_vec();
+        }
+
+        let mut tree = self.dir_value(&ir, plan.as_ref());
+        if self.args.should_show_stats() {
+            if let Value::Object(root) = &mut tree {
+                root.insert("stats".to_string(), self.stats.to_json());
+            }
+        }
+        let output = serde_json::to_string_pretty(&tree).unwrap_or_default();
+        writeln!(out, "{}", output)
+    }
+
+    fn render_stats(&self, stats: &Stats) -> String {
+        serde_json::to_string_pretty(&stats.to_json()).unwrap_or_default()
+    }
+
+    fn output_format(&self) -> OutputFormat {
+        OutputFormat::Json
+    }
+
+    fn read_errors(&self) -> &[String] {
+        &self.read_errors
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use clap::Parser;
+    use std::path::PathBuf;
+
+    #[test]
+    fn test_json_renderer_uniform_node_shape() {
+        let args = Args::parse_from(["tree2md"]);
+        let mut renderer = JsonRenderer::new(&args);
+
+        let root = Node {
+            name: "test".to_string(),
+            path: PathBuf::from("test"),
+            is_dir: true,
+            display_path: PathBuf::from(""),
+            link_target: None,
+            collapsed: None,
+            embedded: None,
+            children: vec![Node {
+                name: "main.rs".to_string(),
+                path: PathBuf::from("test/main.rs"),
+                is_dir: false,
+                display_path: PathBuf::from("main.rs"),
+                link_target: None,
+                collapsed: None,
+                embedded: None,
+                children: vec![],
+            }],
+        };
+
+        let output = renderer.render_tree(&root);
+        let tree: Value = serde_json::from_str(&output).unwrap();
+
+        assert_eq!(tree["name"].as_str(), Some("."));
+        assert_eq!(tree["path"].as_str(), Some("."));
+        assert_eq!(tree["type"].as_str(), Some("dir"));
+        assert!(tree["content"].is_null());
+
+        let file = &tree["children"][0];
+        assert_eq!(file["path"].as_str(), Some("main.rs"));
+        assert_eq!(file["type"].as_str(), Some("file"));
+        assert_eq!(file["language"].as_str(), Some("rust"));
+        assert_eq!(file["children"].as_array().map(Vec::len), Some(0));
+        assert!(file["content"].is_null());
+        assert!(file.get("truncation").is_none());
+    }
+}
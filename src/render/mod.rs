@@ -1,31 +1,102 @@
+pub mod contents;
+pub mod export;
+pub mod json;
 pub mod pipe;
 pub mod pipeline;
 pub mod renderer;
 pub mod terminal;
+pub mod toml;
+pub mod xml;
 
+pub use self::toml::TomlRenderer;
+pub use json::JsonRenderer;
 pub use pipe::PipeRenderer;
 pub use renderer::Renderer;
 pub use terminal::TerminalRenderer;
+pub use xml::XmlRenderer;
 
-use crate::cli::Args;
+use crate::cli::{Args, FormatMode};
+use crate::fs_tree::Node;
 use crate::terminal::capabilities::TerminalCapabilities;
 use crate::terminal::detect::TerminalDetector;
+use std::io::{self, Write};
 
-/// Create the appropriate renderer based on TTY detection
+/// Create the appropriate renderer based on --format and TTY detection
 pub fn create_renderer<'a>(
     args: &'a Args,
     _capabilities: &TerminalCapabilities,
 ) -> Box<dyn Renderer + 'a> {
+    match args.format {
+        FormatMode::Toml => return Box::new(TomlRenderer::new(args)),
+        FormatMode::Json => return Box::new(JsonRenderer::new(args)),
+        FormatMode::Xml => return Box::new(XmlRenderer::new(args)),
+        FormatMode::Auto => {}
+    }
+
     let detector = TerminalDetector::new();
     let is_tty = detector.is_tty();
 
-    if is_tty {
+    // --update writes into a file, so never use the TTY renderer
+    if is_tty && args.update.is_none() {
         Box::new(TerminalRenderer::new(args))
     } else {
         Box::new(PipeRenderer::new(args))
     }
 }
 
+/// Render `root` to `out`, bracketed with --content-prefix /
+/// --content-suffix lines. `notes` (e.g. that the tree is partial) follow
+/// the Markdown output; structured formats leave them to stderr.
+pub fn write_wrapped(
+    args: &Args,
+    renderer: &mut dyn Renderer,
+    root: &Node,
+    notes: &[String],
+    out: &mut dyn Write,
+) -> io::Result<()> {
+    let mut out = TailWriter {
+        inner: out,
+        last: None,
+    };
+    if let Some(prefix) = &args.content_prefix {
+        writeln!(out, "{}", prefix)?;
+    }
+    renderer.write_tree(root, &mut out)?;
+    if matches!(args.format, FormatMode::Auto) {
+        for note in notes {
+            writeln!(out, "\n{}", note)?;
+        }
+    }
+    if let Some(suffix) = &args.content_suffix {
+        if out.last.is_some_and(|b| b != b'\n') {
+            writeln!(out)?;
+        }
+        writeln!(out, "{}", suffix)?;
+    }
+    Ok(())
+}
+
+/// Writer that remembers the last byte written, so the suffix can be put
+/// on its own line
+struct TailWriter<'w> {
+    inner: &'w mut dyn Write,
+    last: Option<u8>,
+}
+
+impl Write for TailWriter<'_> {
+    fn write(&mut self, buf: &[u8]) -> io::Result<usize> {
+        let n = self.inner.write(buf)?;
+        if n > 0 {
+            self.last = Some(buf[n - 1]);
+        }
+        Ok(n)
+    }
+
+    fn flush(&mut self) -> io::Result<()> {
+        self.inner.flush()
+    }
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -36,20 +107,98 @@ mod tests {
         Args {
             target: ".".to_string(),
             level: None,
+            no_recurse: false,
+            collapse_below: None,
+            include_vcs: false,
+            keep_empty_dirs: false,
             include: vec![],
+            include_ext: vec![],
+            ext_alias: vec![],
             exclude: vec![],
+            ignore: vec![],
+            follow_symlinks: false,
             use_gitignore: crate::cli::UseGitignoreMode::Auto,
+            respect_npmignore: false,
+            gitignore_debug: false,
+            changed_in: None,
+            format: crate::cli::FormatMode::Auto,
+            compact_json: false,
+            sort: crate::cli::SortMode::Name,
+            sort_ignorecase: false,
+            sort_reverse: false,
+            content_prefix: None,
+            content_suffix: None,
+            prefix: None,
+            max_name_length: None,
+            show_size: false,
+            show_mtime: false,
+            show_lang: false,
+            root_full_path: false,
+            git_status: false,
+            output_encoding: crate::cli::OutputEncoding::Utf8,
+            update: None,
+            depth_markers: false,
             emoji: vec![],
             emoji_map: None,
             fun: FunMode::Off,
             no_anim: false,
             stats: StatsMode::Off,
             loc: LocMode::Off,
+            summary_json: None,
+            lang_stats: false,
+            summary_only: false,
+            stderr_summary: false,
             contents: false,
             max_chars: None,
+            max_tokens: None,
+            preview_larger_than: None,
+            preview_lines: 20,
             contents_mode: crate::cli::ContentsMode::Head,
+            content_placeholder: None,
+            heading_style: crate::cli::HeadingStyle::Path,
+            heading_meta: false,
+            content_replace: vec![],
+            content_if_matches: None,
+            content_match: vec![],
+            content_depth: None,
+            content_paths: vec![],
+            strict: false,
+            wikilinks: false,
+            truncate_ext: vec![],
+            read_timeout: None,
+            list_languages: false,
+            show_omitted_content: false,
+            dir_language: false,
+            frontmatter_only: false,
+            split_content_on: None,
+            export_dir: None,
+            max_total_dirs: None,
+            minify_json: false,
+            duplicates_report: false,
+            pretty_json: false,
+            max_output_bytes: None,
+            pager: false,
+            from_json: None,
+            note_bom: false,
+            truncate_at_boundary: false,
+            exclude_path: vec![],
+            dir_summary: false,
+            progress_bar: false,
+            filter_file: None,
+            validate_utf8: false,
+            git_blame: false,
+            blame_max_size: 1024 * 1024,
+            content_range: vec![],
+            sniff_content: false,
+            normalize_indent: false,
+            normalize_eol: false,
+            trim_blank_lines: false,
+            max_line_length: None,
+            truncation_format: None,
             safe: true,
             unsafe_mode: false,
+            force: false,
+            timeout: None,
         }
     }
 
@@ -62,4 +211,24 @@ mod tests {
 
         assert_eq!(renderer.output_format(), OutputFormat::Pipe);
     }
+
+    #[test]
+    fn test_create_renderer_explicit_format() {
+        let mut args = create_test_args();
+        args.format = FormatMode::Toml;
+        let capabilities = TerminalCapabilities::new();
+        let renderer = create_renderer(&args, &capabilities);
+
+        assert_eq!(renderer.output_format(), OutputFormat::Toml);
+        drop(renderer);
+
+        args.format = FormatMode::Json;
+        let renderer = create_renderer(&args, &capabilities);
+        assert_eq!(renderer.output_format(), OutputFormat::Json);
+        drop(renderer);
+
+        args.format = FormatMode::Xml;
+        let renderer = create_renderer(&args, &capabilities);
+        assert_eq!(renderer.output_format(), OutputFormat::Xml);
+    }
 }
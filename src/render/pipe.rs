@@ -1,14 +1,23 @@
-use crate::cli::{Args, ContentsMode};
-use crate::content::io::is_binary_extension;
-use crate::content::truncate::{
-    collapse_at_indent, find_head_n, find_nest_threshold, truncate_head_lines,
-};
-use crate::fs_tree::{LocCounter, Node};
-use crate::language::detect_lang;
+use crate::cli::{Args, HeadingStyle};
+use crate::content::bom::{has_bom, BOM_NOTE};
+use crate::content::range::find_range;
+use crate::content::split::{split_on_markers, Section};
+use crate::content::truncate::{truncation_message, TruncationInfo, DEFAULT_TRUNCATION_FORMAT};
+use crate::fs_tree::{GitStatus, LocCounter, Node};
+use crate::language::sniff::{detect_file_lang, Sniff};
+use crate::output::duplicates;
+use crate::output::lang_stats::LanguageStats;
 use crate::output::stats::Stats;
+use crate::output::utf8_report;
 use crate::profile::EmojiMapper;
+use crate::render::contents::{collect_files, ContentPlan, FileContent};
 use crate::render::pipeline::{build_ir, AggregationContext, IrDir, IrFile};
-use crate::render::renderer::{OutputFormat, Renderer};
+use crate::render::renderer::{
+    depth_marker, dir_annotation, dir_languages, dir_summary, file_annotations, OutputFormat,
+    Renderer,
+};
+use crate::util::format::{collapsed_marker, format_size, truncate_name, wikilink};
+use std::io::{self, Write};
 
 /// Pipe renderer for non-TTY output.
 /// Produces plain tree characters with optional line counts and file contents.
@@ -17,7 +26,9 @@ pub struct PipeRenderer<'a> {
     emoji_mapper: EmojiMapper,
     stats: Stats,
     loc_counter: LocCounter,
-    output: String,
+    git_status: Option<GitStatus>,
+    /// Files --strict found unreadable while writing contents
+    read_errors: Vec<String>,
 }
 
 impl<'a> PipeRenderer<'a> {
@@ -26,14 +37,42 @@ impl<'a> PipeRenderer<'a> {
             args,
             emoji_mapper: EmojiMapper::new(false), // no emoji in pipe mode
             stats: Stats::new(),
-            loc_counter: LocCounter::new(args.loc.clone()),
-            output: String::new(),
+            loc_counter: LocCounter::new(args.loc.clone()).with_read_timeout(args.read_timeout),
+            git_status: None,
+            read_errors: Vec::new(),
         }
     }
 
-    fn render_ir_dir(&mut self, dir: &IrDir, prefix: &str) {
+    /// `[M] `-style marker for `path` under --git-status
+    fn status_marker(&self, path: &std::path::Path) -> String {
+        self.git_status
+            .as_ref()
+            .and_then(|status| status.code_for(path))
+            .map(|code| format!("[{}] ", code))
+            .unwrap_or_default()
+    }
+
+    /// File name as listed in the tree: a wikilink for markdown files under
+    /// --wikilinks (never shortened, so the link stays valid), otherwise
+    /// the name cut to --max-name-length
+    fn file_label(&self, file: &IrFile) -> String {
+        self.args
+            .wikilinks
+            .then(|| wikilink(&file.name))
+            .flatten()
+            .unwrap_or_else(|| truncate_name(&file.name, self.args.max_name_length).into_owned())
+    }
+
+    fn render_ir_dir(
+        &self,
+        dir: &IrDir,
+        prefix: &str,
+        depth: usize,
+        out: &mut dyn Write,
+    ) -> io::Result<()> {
         let total = dir.dirs.len() + dir.files.len();
         let mut idx = 0;
+        let marker = depth_marker(self.args, depth);
 
         // Render subdirectories first
         for subdir in &dir.dirs {
@@ -42,11 +81,24 @@ impl<'a> PipeRenderer<'a> {
             let branch = if is_last { "└── " } else { "├── " };
             let continuation = if is_last { "    " } else { "│   " };
 
-            self.output
-                .push_str(&format!("{}{}{}/\n", prefix, branch, subdir.name));
+            writeln!(
+                out,
+                "{}{}{}{}{}/{}{}",
+                marker,
+                prefix,
+                branch,
+                self.status_marker(&subdir.path),
+                truncate_name(&subdir.name, self.args.max_name_length),
+                collapsed_marker(subdir.collapsed),
+                dir_annotation(subdir, self.args)
+            )?;
 
             let new_prefix = format!("{}{}", prefix, continuation);
-            self.render_ir_dir(subdir, &new_prefix);
+            if let Some(summary) = dir_summary(subdir, self.args) {
+                let marker = depth_marker(self.args, depth + 1);
+                writeln!(out, "{}{}{}", marker, new_prefix, summary)?;
+            }
+            self.render_ir_dir(subdir, &new_prefix, depth + 1, out)?;
         }
 
         // Then render files
@@ -55,159 +107,194 @@ impl<'a> PipeRenderer<'a> {
             let is_last = idx == total;
             let branch = if is_last { "└── " } else { "├── " };
 
-            self.output.push_str(prefix);
-            self.output.push_str(branch);
-            self.output.push_str(&file.name);
-
-            if let Some(loc) = file.loc {
-                self.output.push_str(&format!("  ({} lines)", loc));
+            write!(
+                out,
+                "{}{}{}{}{}",
+                marker,
+                prefix,
+                branch,
+                self.status_marker(&file.path),
+                self.file_label(file)
+            )?;
+
+            if let Some(kind) = file.special {
+                write!(out, " ({})", kind)?;
             }
 
-            self.output.push('\n');
+            writeln!(out, "{}", file_annotations(file, self.args, true))?;
         }
+        Ok(())
     }
 
-    fn render_contents(&mut self, dir: &IrDir) {
-        match self.args.max_chars {
-            Some(max_chars) => self.render_contents_with_budget(dir, max_chars),
-            None => self.render_contents_unlimited(dir),
-        }
-    }
-
-    fn render_contents_unlimited(&mut self, dir: &IrDir) {
-        for subdir in &dir.dirs {
-            self.render_contents_unlimited(subdir);
-        }
-        for file in &dir.files {
-            self.render_file_content(file, None);
-        }
-    }
-
-    fn render_contents_with_budget(&mut self, dir: &IrDir, max_chars: usize) {
-        // Collect all readable files in DFS order
+    /// Write each file's section as soon as it is read, so only one file's
+    /// contents are held at a time. Returns the files --strict found unreadable.
+    fn render_contents(&self, dir: &IrDir, out: &mut dyn Write) -> io::Result<Vec<String>> {
         let files = collect_files(dir);
-
-        // Read all file contents
-        let contents: Vec<Option<String>> = files
-            .iter()
-            .map(|f| {
-                if is_binary_extension(&f.path) {
-                    None
-                } else {
-                    std::fs::read_to_string(&f.path).ok()
+        let plan = ContentPlan::new(&files, self.args);
+
+        let mut over_budget = Vec::new();
+        for file in files {
+            match plan.content_for(file, self.args) {
+                FileContent::Text {
+                    content,
+                    truncation,
+                    range_of,
+                } => self.emit_file_section(file, &content, truncation, range_of, out)?,
+                FileContent::Skipped(reason) => {
+                    self.emit_placeholder(file, &reason.describe(), out)?
                 }
-            })
-            .collect();
-
-        // Check if total fits within budget
-        let total_chars: usize = contents
-            .iter()
-            .map(|c| c.as_ref().map_or(0, |s| s.len()))
-            .sum();
-        if total_chars <= max_chars {
-            for (file, content) in files.iter().zip(contents.iter()) {
-                if let Some(content) = content {
-                    self.emit_file_section(file, content, 0);
+                FileContent::TimedOut
+                    if self.args.content_placeholder.is_some()
+                        || self.args.show_omitted_content =>
+                {
+                    self.emit_placeholder(file, "read timed out", out)?
                 }
-            }
-            return;
-        }
-
-        // Collect only the readable contents as &str for uniform parameter search
-        let readable_strs: Vec<&str> = contents.iter().filter_map(|c| c.as_deref()).collect();
-
-        match &self.args.contents_mode {
-            ContentsMode::Head => {
-                let n = find_head_n(&readable_strs, max_chars);
-                for (file, content) in files.iter().zip(contents.iter()) {
-                    if let Some(content) = content {
-                        let (truncated, omitted) = truncate_head_lines(content, n);
-                        self.emit_file_section(file, &truncated, omitted);
-                    }
+                FileContent::TimedOut => {
+                    writeln!(out, "\n## {}\n\n[Read timed out]", self.heading(file))?
                 }
-            }
-            ContentsMode::Nest => {
-                let threshold = find_nest_threshold(&readable_strs, max_chars);
-                match threshold {
-                    Some(t) => {
-                        for (file, content) in files.iter().zip(contents.iter()) {
-                            if let Some(content) = content {
-                                let lines: Vec<&str> = content.lines().collect();
-                                let (collapsed, omitted) = collapse_at_indent(&lines, t);
-                                self.emit_file_section(file, &collapsed, omitted);
-                            }
-                        }
-                    }
-                    None => {
-                        // Nest couldn't fit even at threshold=0, fall back to head
-                        let n = find_head_n(&readable_strs, max_chars);
-                        for (file, content) in files.iter().zip(contents.iter()) {
-                            if let Some(content) = content {
-                                let (truncated, omitted) = truncate_head_lines(content, n);
-                                self.emit_file_section(file, &truncated, omitted);
-                            }
-                        }
-                    }
+                FileContent::OverBudget if self.args.show_omitted_content => {
+                    self.emit_omitted(file, "over the --max-tokens budget", out)?
+                }
+                FileContent::OverBudget => over_budget.push(file),
+                FileContent::Unmatched if self.args.show_omitted_content => {
+                    self.emit_omitted(file, "excluded by content filters", out)?
                 }
+                FileContent::Unmatched => {}
             }
         }
-    }
 
-    fn render_file_content(&mut self, file: &IrFile, _max_chars: Option<usize>) {
-        if is_binary_extension(&file.path) {
-            return;
-        }
-        if let Ok(content) = std::fs::read_to_string(&file.path) {
-            self.emit_file_section(file, &content, 0);
+        if !over_budget.is_empty() {
+            writeln!(
+                out,
+                "\n_{} file(s) omitted to stay within --max-tokens:_\n",
+                over_budget.len()
+            )?;
+            for file in over_budget {
+                writeln!(out, "- `{}`", file.display_path.display())?;
+            }
         }
+        Ok(plan.read_errors().to_vec())
     }
 
-    fn emit_file_section(&mut self, file: &IrFile, content: &str, omitted_lines: usize) {
-        let file_name = file
-            .path
-            .file_name()
-            .unwrap_or_default()
-            .to_string_lossy()
-            .to_string();
-        let lang_hint = detect_lang(&file_name).map(|l| l.name).unwrap_or("");
-
-        self.output.push_str(&format!(
-            "\n## {}\n\n```{}\n",
-            file.display_path.display(),
-            lang_hint
-        ));
-        self.output.push_str(content);
-        if !content.ends_with('\n') {
-            self.output.push('\n');
-        }
-        if omitted_lines > 0 {
-            self.output
-                .push_str(&format!("... ({} lines omitted)\n", omitted_lines));
-        }
-        self.output.push_str("```\n");
+    /// Emit a placeholder section for a file whose contents were skipped.
+    /// Skipped files are silently omitted unless --content-placeholder or
+    /// --show-omitted-content is set; the custom placeholder wins.
+    fn emit_placeholder(&self, file: &IrFile, reason: &str, out: &mut dyn Write) -> io::Result<()> {
+        let Some(template) = &self.args.content_placeholder else {
+            if self.args.show_omitted_content {
+                return self.emit_omitted(file, reason, out);
+            }
+            return Ok(());
+        };
+        let path = file.display_path.display().to_string();
+        let note = template.replace("{path}", &path);
+        let heading = self.heading(file);
+
+        write!(out, "\n## {}\n\n{}\n", heading, note)
     }
-}
 
-/// Collect all files in DFS order from an IrDir tree.
-fn collect_files(dir: &IrDir) -> Vec<&IrFile> {
-    let mut result = Vec::new();
-    collect_files_rec(dir, &mut result);
-    result
-}
+    /// Emit a --show-omitted-content section saying why a listed file has
+    /// no contents: "_Contents omitted: binary file_"
+    fn emit_omitted(&self, file: &IrFile, reason: &str, out: &mut dyn Write) -> io::Result<()> {
+        write!(
+            out,
+            "\n## {}\n\n_Contents omitted: {}_\n",
+            self.heading(file),
+            reason
+        )
+    }
 
-fn collect_files_rec<'a>(dir: &'a IrDir, out: &mut Vec<&'a IrFile>) {
-    for subdir in &dir.dirs {
-        collect_files_rec(subdir, out);
+    /// Heading text for a file section, per --heading-style. Symlinked
+    /// files note their target: "## src/util.go -> ../shared/util.go"
+    fn heading(&self, file: &IrFile) -> String {
+        let path = file.display_path.display();
+        let mut heading = match self.args.heading_style {
+            HeadingStyle::Path => path.to_string(),
+            HeadingStyle::Name => file.name.clone(),
+            HeadingStyle::NameWithPath => format!("{} `{}`", file.name, path),
+        };
+        if let Some(target) = &file.link_target {
+            heading.push_str(&format!(" -> {}", target.display()));
+        }
+        heading
     }
-    for file in &dir.files {
-        out.push(file);
+
+    fn emit_file_section(
+        &self,
+        file: &IrFile,
+        content: &str,
+        truncation: Option<TruncationInfo>,
+        range_of: Option<usize>,
+        out: &mut dyn Write,
+    ) -> io::Result<()> {
+        let lang_hint = detect_file_lang(&file.path, Sniff::from_args(self.args))
+            .map(|l| l.name)
+            .unwrap_or("");
+
+        let mut heading = self.heading(file);
+        if let Some(range) = find_range(&self.args.content_range, &file.display_path) {
+            // Give the file's length when known: "(lines 40-80 of 200)"
+            let total = range_of.map(|n| format!(" of {}", n)).unwrap_or_default();
+            heading.push_str(&format!(" (lines {}{})", range.label(), total));
+        }
+        if self.args.heading_meta {
+            // Describe the whole file, even when only part of it is shown
+            let lines = file
+                .loc
+                .or(truncation.as_ref().map(|t| t.total_lines))
+                .unwrap_or_else(|| content.lines().count());
+            heading.push_str(&format!(
+                " ({} lines, {})",
+                lines,
+                format_size(file.size_bytes)
+            ));
+        }
+        write!(out, "\n## {}\n", heading)?;
+
+        // Every section but the last is closed here; the last one stays
+        // open for the truncation note
+        let sections = match &self.args.split_content_on {
+            Some(marker) => split_on_markers(content, marker),
+            None => vec![Section {
+                marker: None,
+                body: content,
+            }],
+        };
+        let last = sections.len() - 1;
+        for (i, section) in sections.iter().enumerate() {
+            if let Some(marker) = section.marker {
+                write!(out, "\n### {}\n", marker)?;
+            }
+            write!(out, "\n```{}\n", lang_hint)?;
+            out.write_all(section.body.as_bytes())?;
+            if !section.body.ends_with('\n') {
+                writeln!(out)?;
+            }
+            if i < last {
+                out.write_all(b"```\n")?;
+            }
+        }
+        if let Some(info) = truncation.filter(TruncationInfo::is_truncated) {
+            let template = self
+                .args
+                .truncation_format
+                .as_deref()
+                .unwrap_or(DEFAULT_TRUNCATION_FORMAT);
+            writeln!(out, "{}", truncation_message(&info, template))?;
+        }
+        if self.args.note_bom && has_bom(content) {
+            writeln!(out, "{}", BOM_NOTE)?;
+        }
+        out.write_all(b"```\n")
     }
 }
 
 impl<'a> Renderer for PipeRenderer<'a> {
-    fn render_tree(&mut self, root: &Node) -> String {
-        self.output.clear();
+    fn write_tree(&mut self, root: &Node, out: &mut dyn Write) -> io::Result<()> {
         self.stats.reset();
+        if self.args.git_status {
+            self.git_status = GitStatus::load(&root.path);
+        }
 
         if !root.children.is_empty() {
             self.stats.add_directory();
@@ -217,26 +304,57 @@ impl<'a> Renderer for PipeRenderer<'a> {
             emoji_mapper: &self.emoji_mapper,
             stats: &mut self.stats,
             loc_counter: &self.loc_counter,
+            dir_languages: dir_languages(self.args),
         };
 
         let ir = build_ir(root, &mut ctx);
 
+        // --summary-only prints just the stats, whatever --stats says
+        let summary_only = self.args.summary_only;
+
         // Render tree structure
-        self.output.push_str(".\n");
-        self.render_ir_dir(&ir, "");
+        if !summary_only {
+            if self.args.root_full_path {
+                writeln!(out, "{}{}", depth_marker(self.args, 0), root.path.display())?;
+            } else {
+                writeln!(out, "{}.", depth_marker(self.args, 0))?;
+            }
+            self.render_ir_dir(&ir, "", 1, out)?;
+        }
 
         // Append stats if enabled
-        if self.args.should_show_stats() {
-            self.output.push('\n');
-            self.output.push_str(&self.render_stats(&self.stats));
+        if summary_only {
+            out.write_all(self.render_stats(&self.stats).as_bytes())?;
+        } else if self.args.should_show_stats() {
+            writeln!(out)?;
+            out.write_all(self.render_stats(&self.stats).as_bytes())?;
+        }
+
+        if self.args.lang_stats {
+            let lang_stats =
+                LanguageStats::from_files(&collect_files(&ir), Sniff::from_args(self.args));
+            writeln!(out)?;
+            out.write_all(lang_stats.to_markdown().as_bytes())?;
+        }
+
+        if self.args.duplicates_report {
+            let groups = duplicates::find_duplicates(&collect_files(&ir));
+            writeln!(out)?;
+            out.write_all(duplicates::to_markdown(&groups).as_bytes())?;
+        }
+
+        if self.args.validate_utf8 {
+            let invalid = utf8_report::find_invalid_utf8(&collect_files(&ir));
+            writeln!(out)?;
+            out.write_all(utf8_report::to_markdown(&invalid).as_bytes())?;
         }
 
         // Append file contents if -c is enabled
-        if self.args.contents {
-            self.render_contents(&ir);
+        if self.args.contents && !summary_only {
+            self.read_errors = self.render_contents(&ir, out)?;
         }
 
-        self.output.clone()
+        Ok(())
     }
 
     fn render_stats(&self, stats: &Stats) -> String {
@@ -246,6 +364,10 @@ impl<'a> Renderer for PipeRenderer<'a> {
     fn output_format(&self) -> OutputFormat {
         OutputFormat::Pipe
     }
+
+    fn read_errors(&self) -> &[String] {
+        &self.read_errors
+    }
 }
 
 #[cfg(test)]
@@ -259,20 +381,98 @@ mod tests {
         Args {
             target: ".".to_string(),
             level: None,
+            no_recurse: false,
+            collapse_below: None,
+            include_vcs: false,
+            keep_empty_dirs: false,
             include: vec![],
+            include_ext: vec![],
+            ext_alias: vec![],
             exclude: vec![],
+            ignore: vec![],
+            follow_symlinks: false,
             use_gitignore: crate::cli::UseGitignoreMode::Auto,
+            respect_npmignore: false,
+            gitignore_debug: false,
+            changed_in: None,
+            format: crate::cli::FormatMode::Auto,
+            compact_json: false,
+            sort: crate::cli::SortMode::Name,
+            sort_ignorecase: false,
+            sort_reverse: false,
+            content_prefix: None,
+            content_suffix: None,
+            prefix: None,
+            max_name_length: None,
+            show_size: false,
+            show_mtime: false,
+            show_lang: false,
+            root_full_path: false,
+            git_status: false,
+            output_encoding: crate::cli::OutputEncoding::Utf8,
+            update: None,
+            depth_markers: false,
             emoji: vec![],
             emoji_map: None,
             fun: FunMode::Off,
             no_anim: false,
             stats: StatsMode::Off,
             loc: LocMode::Off,
+            summary_json: None,
+            lang_stats: false,
+            summary_only: false,
+            stderr_summary: false,
             contents: false,
             max_chars: None,
+            max_tokens: None,
+            preview_larger_than: None,
+            preview_lines: 20,
             contents_mode: ContentsMode::Head,
+            content_placeholder: None,
+            heading_style: crate::cli::HeadingStyle::Path,
+            heading_meta: false,
+            content_replace: vec![],
+            content_if_matches: None,
+            content_match: vec![],
+            content_depth: None,
+            content_paths: vec![],
+            strict: false,
+            wikilinks: false,
+            truncate_ext: vec![],
+            read_timeout: None,
+            list_languages: false,
+            show_omitted_content: false,
+            dir_language: false,
+            frontmatter_only: false,
+            split_content_on: None,
+            export_dir: None,
+            max_total_dirs: None,
+            minify_json: false,
+            duplicates_report: false,
+            pretty_json: false,
+            max_output_bytes: None,
+            pager: false,
+            from_json: None,
+            note_bom: false,
+            truncate_at_boundary: false,
+            exclude_path: vec![],
+            dir_summary: false,
+            progress_bar: false,
+            filter_file: None,
+            validate_utf8: false,
+            git_blame: false,
+            blame_max_size: 1024 * 1024,
+            content_range: vec![],
+            sniff_content: false,
+            normalize_indent: false,
+            normalize_eol: false,
+            trim_blank_lines: false,
+            max_line_length: None,
+            truncation_format: None,
             safe: true,
             unsafe_mode: false,
+            force: false,
+            timeout: None,
         }
     }
 
@@ -286,17 +486,26 @@ mod tests {
             path: PathBuf::from("test"),
             is_dir: true,
             display_path: PathBuf::from("."),
+            link_target: None,
+            collapsed: None,
+            embedded: None,
             children: vec![
                 Node {
                     name: "src".to_string(),
                     path: PathBuf::from("test/src"),
                     is_dir: true,
                     display_path: PathBuf::from("src"),
+                    link_target: None,
+                    collapsed: None,
+                    embedded: None,
                     children: vec![Node {
                         name: "main.rs".to_string(),
                         path: PathBuf::from("test/src/main.rs"),
                         is_dir: false,
                         display_path: PathBuf::from("src/main.rs"),
+                        link_target: None,
+                        collapsed: None,
+                        embedded: None,
                         children: vec![],
                     }],
                 },
@@ -305,6 +514,9 @@ mod tests {
                     path: PathBuf::from("test/Cargo.toml"),
                     is_dir: false,
                     display_path: PathBuf::from("Cargo.toml"),
+                    link_target: None,
+                    collapsed: None,
+                    embedded: None,
                     children: vec![],
                 },
             ],
@@ -324,4 +536,74 @@ mod tests {
         let renderer = PipeRenderer::new(&args);
         assert_eq!(renderer.output_format(), OutputFormat::Pipe);
     }
+
+    /// Records the largest single write so tests can check that no
+    /// full-document buffer is handed to the writer.
+    #[derive(Default)]
+    struct ChunkRecorder {
+        total: usize,
+        largest: usize,
+    }
+
+    impl Write for ChunkRecorder {
+        fn write(&mut self, buf: &[u8]) -> io::Result<usize> {
+            self.total += buf.len();
+            self.largest = self.largest.max(buf.len());
+            Ok(buf.len())
+        }
+
+        fn flush(&mut self) -> io::Result<()> {
+            Ok(())
+        }
+    }
+
+    fn large_file_tree(dir: &std::path::Path, count: usize, line: &str, lines: usize) -> Node {
+        let mut root = Node::new("root".to_string(), dir.to_path_buf(), true)
+            .with_display_path(PathBuf::from("."));
+        for i in 0..count {
+            let name = format!("file{:02}.txt", i);
+            let path = dir.join(&name);
+            std::fs::write(&path, line.repeat(lines)).unwrap();
+            root.children
+                .push(Node::new(name.clone(), path, false).with_display_path(PathBuf::from(name)));
+        }
+        root
+    }
+
+    #[test]
+    fn test_pipe_renderer_streams_contents_per_file() {
+        let temp = tempfile::TempDir::new().unwrap();
+        let line = "0123456789abcdef0123456789abcdef0123456789abcdef012345678\n";
+        let file_size = line.len() * 1024;
+        let root = large_file_tree(temp.path(), 32, line, 1024);
+
+        let mut args = create_test_args();
+        args.contents = true;
+        let mut renderer = PipeRenderer::new(&args);
+        let mut out = ChunkRecorder::default();
+        renderer.write_tree(&root, &mut out).unwrap();
+
+        assert!(out.total > 32 * file_size);
+        assert!(
+            out.largest <= file_size,
+            "largest write was {} bytes",
+            out.largest
+        );
+    }
+
+    #[test]
+    fn test_pipe_renderer_streams_budgeted_contents() {
+        let temp = tempfile::TempDir::new().unwrap();
+        let root = large_file_tree(temp.path(), 8, "line\n", 100);
+
+        let mut args = create_test_args();
+        args.contents = true;
+        args.max_chars = Some(8 * 49);
+        let mut renderer = PipeRenderer::new(&args);
+        let output = renderer.render_tree(&root);
+
+        // 10 lines of "line" joined by newlines is 49 chars per file
+        assert_eq!(output.matches("... (90 lines omitted)").count(), 8);
+        assert_eq!(output.matches("line\n").count(), 8 * 10);
+    }
 }
@@ -1,6 +1,10 @@
+use crate::content::io::special_file_kind;
+use crate::fs_tree::node::EmbeddedFile;
 use crate::fs_tree::{LocCounter, Node};
+use crate::language::sniff::{detect_file_lang, Sniff};
 use crate::output::stats::Stats;
 use crate::profile::{EmojiMapper, FileType};
+use std::collections::BTreeMap;
 use std::path::PathBuf;
 
 /// Intermediate representation for a file
@@ -16,15 +20,32 @@ pub struct IrFile {
     pub loc: Option<usize>,
     #[allow(dead_code)]
     pub size_bytes: u64,
+    /// Kind of special file ("fifo", "socket", "device"); never read
+    pub special: Option<&'static str>,
+    /// Target of a symlinked file (--follow-symlinks)
+    pub link_target: Option<PathBuf>,
+    /// Set for files from a --from-json tree, whose contents come from the JSON
+    pub embedded: Option<EmbeddedFile>,
 }
 
 /// Intermediate representation for a directory
 #[derive(Debug, Clone)]
 pub struct IrDir {
     pub name: String,
+    /// Actual filesystem path
+    pub path: PathBuf,
     pub display_path: PathBuf,
     pub files: Vec<IrFile>,
     pub dirs: Vec<IrDir>,
+    /// Entries hidden beneath this directory by --collapse-below
+    pub collapsed: Option<usize>,
+    /// Files anywhere below this directory by detected language; empty
+    /// unless `AggregationContext::dir_languages` is set
+    pub languages: BTreeMap<&'static str, usize>,
+    /// Number of files anywhere below this directory
+    pub total_files: usize,
+    /// Total size of those files
+    pub total_bytes: u64,
 }
 
 /// Context for aggregation during IR building
@@ -32,6 +53,9 @@ pub struct AggregationContext<'a> {
     pub emoji_mapper: &'a EmojiMapper,
     pub stats: &'a mut Stats,
     pub loc_counter: &'a LocCounter,
+    /// Tally languages per directory, detecting them this way
+    /// (--dir-language, --dir-summary)
+    pub dir_languages: Option<Sniff>,
 }
 
 /// Build the intermediate representation from the filesystem tree
@@ -42,6 +66,9 @@ pub fn build_ir(root: &Node, ctx: &mut AggregationContext) -> IrDir {
 fn build_ir_node(node: &Node, ctx: &mut AggregationContext) -> IrDir {
     let mut files = Vec::new();
     let mut dirs = Vec::new();
+    let mut languages = BTreeMap::new();
+    let mut total_files = 0;
+    let mut total_bytes = 0;
 
     // Process children
     for child in &node.children {
@@ -51,6 +78,11 @@ fn build_ir_node(node: &Node, ctx: &mut AggregationContext) -> IrDir {
 
             // Recursively build IR for subdirectory
             let ir_dir = build_ir_node(child, ctx);
+            for (lang, count) in &ir_dir.languages {
+                *languages.entry(*lang).or_default() += count;
+            }
+            total_files += ir_dir.total_files;
+            total_bytes += ir_dir.total_bytes;
             dirs.push(ir_dir);
         } else {
             // Classify file type
@@ -65,19 +97,38 @@ fn build_ir_node(node: &Node, ctx: &mut AggregationContext) -> IrDir {
             // Add file to stats
             ctx.stats.add_file(file_type, emoji.clone(), &child.path);
 
-            // Count lines of code if enabled
-            let loc = if let Some(line_count) = ctx.loc_counter.count_lines(&child.path) {
-                ctx.stats.add_loc(file_type, line_count);
-                Some(line_count)
-            } else {
-                None
+            // Stat once for size and special-file detection; a --from-json
+            // file takes both from the JSON instead
+            let metadata = match child.embedded {
+                Some(_) => None,
+                None => std::fs::metadata(&child.path).ok(),
+            };
+            let special = metadata.as_ref().and_then(special_file_kind);
+
+            // Count lines of code if enabled (special files are never read)
+            let loc = match &child.embedded {
+                Some(embedded) => embedded.lines.filter(|_| ctx.loc_counter.is_enabled()),
+                None if special.is_some() => None,
+                None => ctx.loc_counter.count_lines(&child.path),
             };
+            if let Some(line_count) = loc {
+                ctx.stats.add_loc(file_type, line_count);
+            }
 
             // Get file size
-            let size_bytes = std::fs::metadata(&child.path)
-                .ok()
-                .map(|m| m.len())
-                .unwrap_or(0);
+            let size_bytes = match &child.embedded {
+                Some(embedded) => embedded.size,
+                None => metadata.map(|m| m.len()).unwrap_or(0),
+            };
+            ctx.stats.add_bytes(size_bytes);
+            total_files += 1;
+            total_bytes += size_bytes;
+
+            if let Some(sniff) = ctx.dir_languages {
+                if let Some(lang) = detect_file_lang(&child.path, sniff) {
+                    *languages.entry(lang.name).or_default() += 1;
+                }
+            }
 
             // Create IR file
             let ir_file = IrFile {
@@ -88,6 +139,9 @@ fn build_ir_node(node: &Node, ctx: &mut AggregationContext) -> IrDir {
                 emoji,
                 loc,
                 size_bytes,
+                special,
+                link_target: child.link_target.clone(),
+                embedded: child.embedded.clone(),
             };
 
             files.push(ir_file);
@@ -99,9 +153,14 @@ fn build_ir_node(node: &Node, ctx: &mut AggregationContext) -> IrDir {
     // as it will be handled by the parent or caller
     IrDir {
         name: node.name.clone(),
+        path: node.path.clone(),
         display_path: node.display_path.clone(),
         files,
         dirs,
+        collapsed: node.collapsed,
+        languages,
+        total_files,
+        total_bytes,
     }
 }
 
@@ -132,17 +191,26 @@ mod tests {
             path: PathBuf::from("root"),
             is_dir: true,
             display_path: PathBuf::from("."),
+            link_target: None,
+            collapsed: None,
+            embedded: None,
             children: vec![
                 Node {
                     name: "src".to_string(),
                     path: PathBuf::from("root/src"),
                     is_dir: true,
                     display_path: PathBuf::from("src"),
+                    link_target: None,
+                    collapsed: None,
+                    embedded: None,
                     children: vec![Node {
                         name: "main.rs".to_string(),
                         path: PathBuf::from("root/src/main.rs"),
                         is_dir: false,
                         display_path: PathBuf::from("src/main.rs"),
+                        link_target: None,
+                        collapsed: None,
+                        embedded: None,
                         children: vec![],
                     }],
                 },
@@ -151,6 +219,9 @@ mod tests {
                     path: PathBuf::from("root/README.md"),
                     is_dir: false,
                     display_path: PathBuf::from("README.md"),
+                    link_target: None,
+                    collapsed: None,
+                    embedded: None,
                     children: vec![],
                 },
             ],
@@ -168,6 +239,7 @@ mod tests {
             emoji_mapper: &emoji_mapper,
             stats: &mut stats,
             loc_counter: &loc_counter,
+            dir_languages: None,
         };
 
         let ir = build_ir(&root, &mut ctx);
@@ -184,11 +256,38 @@ mod tests {
         assert_eq!(ir.files[0].name, "README.md");
     }
 
+    #[test]
+    fn test_build_ir_rolls_up_directory_tallies() {
+        let root = create_test_node();
+        let emoji_mapper = EmojiMapper::new(false);
+        let mut stats = Stats::new();
+        let loc_counter = LocCounter::new(LocMode::Off);
+
+        let mut ctx = AggregationContext {
+            emoji_mapper: &emoji_mapper,
+            stats: &mut stats,
+            loc_counter: &loc_counter,
+            dir_languages: Some(Sniff::Off),
+        };
+        let ir = build_ir(&root, &mut ctx);
+
+        assert_eq!(ir.dirs[0].languages.get("rust"), Some(&1));
+        // Subdirectory tallies roll up into their parent
+        assert_eq!(ir.languages.get("rust"), Some(&1));
+        assert_eq!(ir.languages.values().sum::<usize>(), 2);
+        assert_eq!((ir.dirs[0].total_files, ir.total_files), (1, 2));
+    }
+
     #[test]
     fn test_ir_dir_methods() {
         let ir_dir = IrDir {
             name: "test".to_string(),
+            path: PathBuf::from("test"),
             display_path: PathBuf::from("test"),
+            collapsed: None,
+            languages: BTreeMap::new(),
+            total_files: 0,
+            total_bytes: 0,
             files: vec![
                 IrFile {
                     name: "file1.txt".to_string(),
@@ -198,6 +297,9 @@ mod tests {
                     emoji: String::new(),
                     loc: None,
                     size_bytes: 0,
+                    special: None,
+                    link_target: None,
+                    embedded: None,
                 },
                 IrFile {
                     name: "file2.txt".to_string(),
@@ -207,13 +309,21 @@ mod tests {
                     emoji: String::new(),
                     loc: None,
                     size_bytes: 0,
+                    special: None,
+                    link_target: None,
+                    embedded: None,
                 },
             ],
             dirs: vec![IrDir {
                 name: "subdir".to_string(),
+                path: PathBuf::from("test/subdir"),
                 display_path: PathBuf::from("test/subdir"),
                 files: vec![],
                 dirs: vec![],
+                collapsed: None,
+                languages: BTreeMap::new(),
+                total_files: 0,
+                total_bytes: 0,
             }],
         };
 
@@ -222,9 +332,14 @@ mod tests {
 
         let empty_dir = IrDir {
             name: "empty".to_string(),
+            path: PathBuf::from("empty"),
             display_path: PathBuf::from("empty"),
             files: vec![],
             dirs: vec![],
+            collapsed: None,
+            languages: BTreeMap::new(),
+            total_files: 0,
+            total_bytes: 0,
         };
 
         assert!(empty_dir.is_empty());
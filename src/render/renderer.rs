@@ -1,6 +1,11 @@
+use crate::cli::Args;
 use crate::fs_tree::Node;
+use crate::language::sniff::{detect_file_lang, Sniff};
 use crate::output::stats::Stats;
 use crate::profile::{EmojiMapper, FileType};
+use crate::render::pipeline::{IrDir, IrFile};
+use crate::util::format::{format_date, format_size};
+use std::io::{self, Write};
 
 /// Output format for the renderer
 #[derive(Debug, Clone, Copy, PartialEq)]
@@ -10,6 +15,12 @@ pub enum OutputFormat {
     Pipe,
     /// Terminal with Unicode tree branches
     Terminal,
+    /// Flattened TOML manifest
+    Toml,
+    /// Nested JSON tree
+    Json,
+    /// Nested XML elements
+    Xml,
 }
 
 /// Configuration for rendering
@@ -34,8 +45,18 @@ impl Default for RenderConfig {
 
 /// Trait for rendering directory trees in different formats
 pub trait Renderer {
-    /// Render the tree structure
-    fn render_tree(&mut self, root: &Node) -> String;
+    /// Write the tree structure (and any file contents) to `out` as it is
+    /// produced, without building the whole document in memory
+    fn write_tree(&mut self, root: &Node, out: &mut dyn Write) -> io::Result<()>;
+
+    /// Render the tree structure into a string
+    #[allow(dead_code)]
+    fn render_tree(&mut self, root: &Node) -> String {
+        let mut buf = Vec::new();
+        // Writing into a Vec cannot fail
+        let _ = self.write_tree(root, &mut buf);
+        String::from_utf8_lossy(&buf).into_owned()
+    }
 
     /// Render statistics footer
     fn render_stats(&self, stats: &Stats) -> String;
@@ -55,6 +76,99 @@ pub trait Renderer {
     /// Get the output format
     #[allow(dead_code)]
     fn output_format(&self) -> OutputFormat;
+
+    /// Files -c could not read under --strict during the last `write_tree`
+    fn read_errors(&self) -> &[String] {
+        &[]
+    }
+}
+
+/// Marker prepended to a tree line at `depth` (root = 0) when --depth-markers is set
+pub fn depth_marker(args: &Args, depth: usize) -> String {
+    if args.depth_markers {
+        format!("[{}] ", depth)
+    } else {
+        String::new()
+    }
+}
+
+/// Annotation group after a file name in the tree, e.g.
+/// "  (12 lines, 1.2 KB, 2024-01-02, go)". Every annotation flag feeds this
+/// one group, always in the order lines, size (--show-size), modification
+/// date (--show-mtime), language (--show-lang). Empty when there is nothing
+/// to show. `with_loc` is false where the renderer places line counts itself.
+pub fn file_annotations(file: &IrFile, args: &Args, with_loc: bool) -> String {
+    let mut parts = Vec::new();
+    if let Some(loc) = file.loc.filter(|_| with_loc) {
+        parts.push(format!("{} lines", loc));
+    }
+    if args.show_size {
+        parts.push(format_size(file.size_bytes));
+    }
+    if args.show_mtime {
+        if let Ok(modified) = std::fs::metadata(&file.path).and_then(|m| m.modified()) {
+            parts.push(format_date(modified));
+        }
+    }
+    if args.show_lang {
+        if let Some(lang) = detect_file_lang(&file.path, Sniff::from_args(args)) {
+            parts.push(lang.name.to_string());
+        }
+    }
+    if parts.is_empty() {
+        String::new()
+    } else {
+        format!("  ({})", parts.join(", "))
+    }
+}
+
+/// How `build_ir` should tally languages per directory: only when
+/// --dir-language or --dir-summary shows them
+pub fn dir_languages(args: &Args) -> Option<Sniff> {
+    (args.dir_language || args.dir_summary).then(|| Sniff::from_args(args))
+}
+
+/// Annotation after a directory name under --dir-language, e.g.
+/// " [mostly go]" for the language of most files below it; empty when no
+/// file has a known language.
+pub fn dir_annotation(dir: &IrDir, args: &Args) -> String {
+    if !args.dir_language {
+        return String::new();
+    }
+    predominant_language(dir)
+        .map(|lang| format!(" [mostly {}]", lang))
+        .unwrap_or_default()
+}
+
+/// Line shown beneath a directory under --dir-summary, e.g.
+/// "(3 files, 4.2 KB, mostly go)", covering every file below it. The
+/// language is left out when no file has a known one.
+pub fn dir_summary(dir: &IrDir, args: &Args) -> Option<String> {
+    if !args.dir_summary {
+        return None;
+    }
+    let files = dir.total_files;
+    let mut parts = vec![
+        format!("{} {}", files, if files == 1 { "file" } else { "files" }),
+        format_size(dir.total_bytes),
+    ];
+    if let Some(lang) = predominant_language(dir) {
+        parts.push(format!("mostly {}", lang));
+    }
+    Some(format!("({})", parts.join(", ")))
+}
+
+/// The language of most files under `dir`, from the tally `build_ir`
+/// kept. Ties go to the alphabetically first language; None when no file
+/// has a known language.
+fn predominant_language(dir: &IrDir) -> Option<&'static str> {
+    // max_by_key keeps the last maximum; iterate in reverse so the first
+    // language in name order wins a tie
+    dir.languages
+        .iter()
+        .rev()
+        .max_by_key(|(_, count)| **count)
+        .map(|(lang, _)| *lang)
 }
 
 /// Helper struct for managing node metadata during rendering
@@ -1,12 +1,24 @@
 use crate::cli::Args;
-use crate::fs_tree::{LocCounter, Node};
+use crate::fs_tree::{GitStatus, LocCounter, Node};
+use crate::language::sniff::Sniff;
+use crate::output::duplicates;
+use crate::output::lang_stats::LanguageStats;
 use crate::output::stats::Stats;
+use crate::output::utf8_report;
 use crate::profile::{EmojiMapper, FileType};
+use crate::render::contents::collect_files;
 use crate::render::pipeline::{build_ir, AggregationContext, IrDir, IrFile};
-use crate::render::renderer::{OutputFormat, Renderer};
+use crate::render::renderer::{
+    depth_marker, dir_annotation, dir_languages, dir_summary, file_annotations, OutputFormat,
+    Renderer,
+};
 use crate::terminal::capabilities::TerminalCapabilities;
 use crate::terminal::detect::TerminalDetector;
-use crate::util::format::{format_loc_display, is_global_outlier, loc_category, loc_to_bar};
+use crate::util::format::{
+    collapsed_marker, format_loc_display, is_global_outlier, loc_category, loc_to_bar,
+    truncate_name,
+};
+use std::io::{self, Write};
 use std::path::Path;
 
 /// Terminal renderer with Unicode tree branches
@@ -16,7 +28,7 @@ pub struct TerminalRenderer<'a> {
     emoji_mapper: EmojiMapper,
     stats: Stats,
     loc_counter: LocCounter,
-    output: String,
+    git_status: Option<GitStatus>,
     global_threshold: usize, // Threshold for global outliers (95th percentile)
 }
 
@@ -48,12 +60,21 @@ impl<'a> TerminalRenderer<'a> {
             capabilities,
             emoji_mapper,
             stats: Stats::new(),
-            loc_counter: LocCounter::new(args.loc.clone()),
-            output: String::new(),
+            loc_counter: LocCounter::new(args.loc.clone()).with_read_timeout(args.read_timeout),
+            git_status: None,
             global_threshold: 0,
         }
     }
 
+    /// `[M] `-style marker for `path` under --git-status
+    fn status_marker(&self, path: &Path) -> String {
+        self.git_status
+            .as_ref()
+            .and_then(|status| status.code_for(path))
+            .map(|code| format!("[{}] ", code))
+            .unwrap_or_default()
+    }
+
     #[allow(clippy::only_used_in_recursion)]
     fn collect_all_files(
         &self,
@@ -67,12 +88,21 @@ impl<'a> TerminalRenderer<'a> {
         }
 
         for file in &dir.files {
-            files.push((file.name.clone(), file.loc));
+            let name = truncate_name(&file.name, self.args.max_name_length);
+            files.push((name.into_owned(), file.loc));
         }
     }
 
-    fn render_ir_dir_aligned(&mut self, dir: &IrDir, prefix: &str, max_name_width: usize) {
+    fn render_ir_dir_aligned(
+        &self,
+        dir: &IrDir,
+        prefix: &str,
+        max_name_width: usize,
+        depth: usize,
+        out: &mut dyn Write,
+    ) -> io::Result<()> {
         let tree_chars = self.capabilities.tree_chars();
+        let marker = depth_marker(self.args, depth);
 
         let max_loc_in_dir = dir.files.iter().filter_map(|f| f.loc).max().unwrap_or(0);
 
@@ -88,17 +118,22 @@ impl<'a> TerminalRenderer<'a> {
                 String::new()
             };
 
-            self.output.push_str(&format!(
-                "{}{}{}{}/\n",
+            writeln!(
+                out,
+                "{}{}{}{}{}{}/{}{}",
+                marker,
                 prefix,
                 if subdir_is_last {
                     tree_chars.last_branch
                 } else {
                     tree_chars.branch
                 },
+                self.status_marker(&subdir.path),
                 emoji_str,
-                subdir.name
-            ));
+                truncate_name(&subdir.name, self.args.max_name_length),
+                collapsed_marker(subdir.collapsed),
+                dir_annotation(subdir, self.args)
+            )?;
 
             let new_prefix = format!(
                 "{}{}",
@@ -109,29 +144,39 @@ impl<'a> TerminalRenderer<'a> {
                     tree_chars.vertical
                 }
             );
-            self.render_ir_dir_aligned(subdir, &new_prefix, max_name_width);
+            if let Some(summary) = dir_summary(subdir, self.args) {
+                let marker = depth_marker(self.args, depth + 1);
+                writeln!(out, "{}{}{}", marker, new_prefix, summary)?;
+            }
+            self.render_ir_dir_aligned(subdir, &new_prefix, max_name_width, depth + 1, out)?;
         }
 
         for (i, file) in dir.files.iter().enumerate() {
             let file_is_last = i == dir.files.len() - 1;
             self.render_ir_file_with_local_scale(
                 file,
+                &marker,
                 prefix,
                 file_is_last,
                 max_name_width,
                 max_loc_in_dir,
-            );
+                out,
+            )?;
         }
+        Ok(())
     }
 
+    #[allow(clippy::too_many_arguments)]
     fn render_ir_file_with_local_scale(
-        &mut self,
+        &self,
         file: &IrFile,
+        marker: &str,
         prefix: &str,
         is_last: bool,
         max_name_width: usize,
         max_loc_in_dir: usize,
-    ) {
+        out: &mut dyn Write,
+    ) -> io::Result<()> {
         let tree_chars = self.capabilities.tree_chars();
 
         let branch = if is_last {
@@ -146,10 +191,18 @@ impl<'a> TerminalRenderer<'a> {
             String::new()
         };
 
-        self.output.push_str(prefix);
-        self.output.push_str(branch);
-        let name_with_emoji = format!("{}{}", emoji_str, file.name);
-        self.output.push_str(&name_with_emoji);
+        let mut name_with_emoji = format!(
+            "{}{}{}",
+            self.status_marker(&file.path),
+            emoji_str,
+            truncate_name(&file.name, self.args.max_name_length)
+        );
+        if let Some(kind) = file.special {
+            name_with_emoji.push_str(&format!(" ({})", kind));
+        }
+        // Line counts get their own aligned column, so they stay out of the group
+        name_with_emoji.push_str(&file_annotations(file, self.args, false));
+        write!(out, "{}{}{}{}", marker, prefix, branch, name_with_emoji)?;
 
         if let Some(loc) = file.loc {
             let current_len = prefix.len() + 2 + name_with_emoji.len();
@@ -169,20 +222,23 @@ impl<'a> TerminalRenderer<'a> {
                 ""
             };
 
-            self.output.push_str(&format!(
+            write!(
+                out,
                 "{}  {}  {} ({}){}",
                 padding, bar, loc_formatted, category, star
-            ));
+            )?;
         }
 
-        self.output.push('\n');
+        writeln!(out)
     }
 }
 
 impl<'a> Renderer for TerminalRenderer<'a> {
-    fn render_tree(&mut self, root: &Node) -> String {
-        self.output.clear();
+    fn write_tree(&mut self, root: &Node, out: &mut dyn Write) -> io::Result<()> {
         self.stats.reset();
+        if self.args.git_status {
+            self.git_status = GitStatus::load(&root.path);
+        }
 
         if !root.children.is_empty() {
             self.stats.add_directory();
@@ -192,6 +248,7 @@ impl<'a> Renderer for TerminalRenderer<'a> {
             emoji_mapper: &self.emoji_mapper,
             stats: &mut self.stats,
             loc_counter: &self.loc_counter,
+            dir_languages: dir_languages(self.args),
         };
 
         let ir = build_ir(root, &mut ctx);
@@ -219,14 +276,44 @@ impl<'a> Renderer for TerminalRenderer<'a> {
             usize::MAX
         };
 
-        self.render_ir_dir_aligned(&ir, "", max_name_width);
+        if self.args.summary_only {
+            // Just the stats, whatever --stats says
+            out.write_all(self.render_stats(&self.stats).as_bytes())?;
+        } else {
+            if self.args.root_full_path {
+                writeln!(out, "{}{}", depth_marker(self.args, 0), root.path.display())?;
+            } else if self.args.depth_markers {
+                // Markers count from the root, so show it as the pipe renderer does
+                writeln!(out, "{}.", depth_marker(self.args, 0))?;
+            }
+            self.render_ir_dir_aligned(&ir, "", max_name_width, 1, out)?;
+
+            if self.args.should_show_stats() {
+                writeln!(out)?;
+                out.write_all(self.render_stats(&self.stats).as_bytes())?;
+            }
+        }
+
+        if self.args.lang_stats {
+            let lang_stats =
+                LanguageStats::from_files(&collect_files(&ir), Sniff::from_args(self.args));
+            writeln!(out)?;
+            out.write_all(lang_stats.to_markdown().as_bytes())?;
+        }
+
+        if self.args.duplicates_report {
+            let groups = duplicates::find_duplicates(&collect_files(&ir));
+            writeln!(out)?;
+            out.write_all(duplicates::to_markdown(&groups).as_bytes())?;
+        }
 
-        if self.args.should_show_stats() {
-            self.output.push('\n');
-            self.output.push_str(&self.render_stats(&self.stats));
+        if self.args.validate_utf8 {
+            let invalid = utf8_report::find_invalid_utf8(&collect_files(&ir));
+            writeln!(out)?;
+            out.write_all(utf8_report::to_markdown(&invalid).as_bytes())?;
         }
 
-        self.output.clone()
+        Ok(())
     }
 
     fn render_stats(&self, stats: &Stats) -> String {
@@ -259,20 +346,98 @@ mod tests {
         Args {
             target: ".".to_string(),
             level: None,
+            no_recurse: false,
+            collapse_below: None,
+            include_vcs: false,
+            keep_empty_dirs: false,
             include: vec![],
+            include_ext: vec![],
+            ext_alias: vec![],
             exclude: vec![],
+            ignore: vec![],
+            follow_symlinks: false,
             use_gitignore: crate::cli::UseGitignoreMode::Auto,
+            respect_npmignore: false,
+            gitignore_debug: false,
+            changed_in: None,
+            format: crate::cli::FormatMode::Auto,
+            compact_json: false,
+            sort: crate::cli::SortMode::Name,
+            sort_ignorecase: false,
+            sort_reverse: false,
+            content_prefix: None,
+            content_suffix: None,
+            prefix: None,
+            max_name_length: None,
+            show_size: false,
+            show_mtime: false,
+            show_lang: false,
+            root_full_path: false,
+            git_status: false,
+            output_encoding: crate::cli::OutputEncoding::Utf8,
+            update: None,
+            depth_markers: false,
             emoji: vec![],
             emoji_map: None,
             fun: FunMode::Off,
             no_anim: false,
             stats: StatsMode::Off,
             loc: LocMode::Off,
+            summary_json: None,
+            lang_stats: false,
+            summary_only: false,
+            stderr_summary: false,
             contents: false,
             max_chars: None,
+            max_tokens: None,
+            preview_larger_than: None,
+            preview_lines: 20,
             contents_mode: crate::cli::ContentsMode::Head,
+            content_placeholder: None,
+            heading_style: crate::cli::HeadingStyle::Path,
+            heading_meta: false,
+            content_replace: vec![],
+            content_if_matches: None,
+            content_match: vec![],
+            content_depth: None,
+            content_paths: vec![],
+            strict: false,
+            wikilinks: false,
+            truncate_ext: vec![],
+            read_timeout: None,
+            list_languages: false,
+            show_omitted_content: false,
+            dir_language: false,
+            frontmatter_only: false,
+            split_content_on: None,
+            export_dir: None,
+            max_total_dirs: None,
+            minify_json: false,
+            duplicates_report: false,
+            pretty_json: false,
+            max_output_bytes: None,
+            pager: false,
+            from_json: None,
+            note_bom: false,
+            truncate_at_boundary: false,
+            exclude_path: vec![],
+            dir_summary: false,
+            progress_bar: false,
+            filter_file: None,
+            validate_utf8: false,
+            git_blame: false,
+            blame_max_size: 1024 * 1024,
+            content_range: vec![],
+            sniff_content: false,
+            normalize_indent: false,
+            normalize_eol: false,
+            trim_blank_lines: false,
+            max_line_length: None,
+            truncation_format: None,
             safe: true,
             unsafe_mode: false,
+            force: false,
+            timeout: None,
         }
     }
 
@@ -286,17 +451,26 @@ mod tests {
             path: PathBuf::from("test"),
             is_dir: true,
             display_path: PathBuf::from("."),
+            link_target: None,
+            collapsed: None,
+            embedded: None,
             children: vec![
                 Node {
                     name: "dir1".to_string(),
                     path: PathBuf::from("test/dir1"),
                     is_dir: true,
                     display_path: PathBuf::from("dir1"),
+                    link_target: None,
+                    collapsed: None,
+                    embedded: None,
                     children: vec![Node {
                         name: "file1.txt".to_string(),
                         path: PathBuf::from("test/dir1/file1.txt"),
                         is_dir: false,
                         display_path: PathBuf::from("dir1/file1.txt"),
+                        link_target: None,
+                        collapsed: None,
+                        embedded: None,
                         children: vec![],
                     }],
                 },
@@ -305,6 +479,9 @@ mod tests {
                     path: PathBuf::from("test/file2.rs"),
                     is_dir: false,
                     display_path: PathBuf::from("file2.rs"),
+                    link_target: None,
+                    collapsed: None,
+                    embedded: None,
                     children: vec![],
                 },
             ],
@@ -316,6 +493,30 @@ mod tests {
         assert!(output.contains("file2.rs"));
     }
 
+    #[test]
+    fn test_terminal_depth_markers_start_at_root() {
+        let mut args = create_test_args();
+        args.depth_markers = true;
+        args.stats = StatsMode::Off;
+        let mut renderer = TerminalRenderer::new(&args);
+
+        let mut dir = Node::new("src".to_string(), PathBuf::from("test/src"), true)
+            .with_display_path(PathBuf::from("src"));
+        dir.children.push(
+            Node::new("a.txt".to_string(), PathBuf::from("test/src/a.txt"), false)
+                .with_display_path(PathBuf::from("src/a.txt")),
+        );
+        let mut root = Node::new("test".to_string(), PathBuf::from("test"), true)
+            .with_display_path(PathBuf::new());
+        root.children.push(dir);
+
+        let output = renderer.render_tree(&root);
+        let lines: Vec<&str> = output.lines().collect();
+        assert_eq!(lines[0], "[0] .", "{}", output);
+        assert!(lines[1].starts_with("[1] "), "{}", output);
+        assert!(lines[2].starts_with("[2] "), "{}", output);
+    }
+
     #[test]
     fn test_terminal_renderer_output_format() {
         let args = create_test_args();
@@ -0,0 +1,126 @@
+use crate::cli::Args;
+use crate::fs_tree::Node;
+use crate::language::sniff::{detect_file_lang, Sniff};
+use crate::output::stats::Stats;
+use crate::render::renderer::{OutputFormat, Renderer};
+use ::toml::{Table, Value};
+use std::io::{self, Write};
+
+/// TOML renderer producing a flattened manifest.
+/// TOML can't nest arrays of tables cleanly, so every node becomes one
+/// `[[file]]` table with `path`, `type`, and (for files) `size` and `lang`.
+pub struct TomlRenderer<'a> {
+    args: &'a Args,
+}
+
+impl<'a> TomlRenderer<'a> {
+    pub fn new(args: &'a Args) -> Self {
+        Self { args }
+    }
+
+    fn collect_entries(&self, node: &Node, entries: &mut Vec<Value>) {
+        for child in &node.children {
+            let mut entry = Table::new();
+            entry.insert(
+                "path".to_string(),
+                Value::String(child.display_path.to_string_lossy().replace('\\', "/")),
+            );
+
+            if child.is_dir {
+                entry.insert("type".to_string(), Value::String("dir".to_string()));
+            } else {
+                entry.insert("type".to_string(), Value::String("file".to_string()));
+
+                let size = match &child.embedded {
+                    Some(embedded) => embedded.size,
+                    None => std::fs::metadata(&child.path).map(|m| m.len()).unwrap_or(0),
+                };
+                entry.insert("size".to_string(), Value::Integer(size as i64));
+
+                if let Some(lang) = detect_file_lang(&child.path, Sniff::from_args(self.args)) {
+                    entry.insert("lang".to_string(), Value::String(lang.name.to_string()));
+                }
+            }
+
+            entries.push(Value::Table(entry));
+
+            if child.is_dir {
+                self.collect_entries(child, entries);
+            }
+        }
+    }
+}
+
+impl<'a> Renderer for TomlRenderer<'a> {
+    fn write_tree(&mut self, root: &Node, out: &mut dyn Write) -> io::Result<()> {
+        let mut entries = Vec::new();
+        self.collect_entries(root, &mut entries);
+
+        let mut manifest = Table::new();
+        manifest.insert("file".to_string(), Value::Array(entries));
+
+        out.write_all(::toml::to_string(&manifest).unwrap_or_default().as_bytes())
+    }
+
+    fn render_stats(&self, _stats: &Stats) -> String {
+        // Stats are not part of the TOML manifest
+        String::new()
+    }
+
+    fn output_format(&self) -> OutputFormat {
+        OutputFormat::Toml
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use clap::Parser;
+    use std::path::PathBuf;
+
+    #[test]
+    fn test_toml_renderer_flattens_tree() {
+        let args = Args::parse_from(["tree2md"]);
+        let mut renderer = TomlRenderer::new(&args);
+
+        let root = Node {
+            name: "test".to_string(),
+            path: PathBuf::from("test"),
+            is_dir: true,
+            display_path: PathBuf::from("."),
+            link_target: None,
+            collapsed: None,
+            embedded: None,
+            children: vec![Node {
+                name: "src".to_string(),
+                path: PathBuf::from("test/src"),
+                is_dir: true,
+                display_path: PathBuf::from("src"),
+                link_target: None,
+                collapsed: None,
+                embedded: None,
+                children: vec![Node {
+                    name: "main.rs".to_string(),
+                    path: PathBuf::from("test/src/main.rs"),
+                    is_dir: false,
+                    display_path: PathBuf::from("src/main.rs"),
+                    link_target: None,
+                    collapsed: None,
+                    embedded: None,
+                    children: vec![],
+                }],
+            }],
+        };
+
+        let output = renderer.render_tree(&root);
+        let parsed: Table = ::toml::from_str(&output).unwrap();
+        let files = parsed["file"].as_array().unwrap();
+
+        assert_eq!(files.len(), 2);
+        assert_eq!(files[0]["path"].as_str(), Some("src"));
+        assert_eq!(files[0]["type"].as_str(), Some("dir"));
+        assert_eq!(files[1]["path"].as_str(), Some("src/main.rs"));
+        assert_eq!(files[1]["type"].as_str(), Some("file"));
+        assert_eq!(files[1]["lang"].as_str(), Some("rust"));
+    }
+}
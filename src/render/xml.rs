@@ -0,0 +1,204 @@
+use crate::cli::{Args, OutputEncoding};
+use crate::fs_tree::{LocCounter, Node};
+use crate::language::sniff::{detect_file_lang, Sniff};
+use crate::output::stats::Stats;
+use crate::profile::EmojiMapper;
+use crate::render::contents::{collect_files, ContentPlan, FileContent};
+use crate::render::pipeline::{build_ir, AggregationContext, IrDir, IrFile};
+use crate::render::renderer::{OutputFormat, Renderer};
+use std::io::{self, Write};
+
+/// XML renderer producing nested `<directory>` and `<file>` elements.
+/// Files carry `size` and, when known, `lang` and `lines`; with -c their
+/// contents are written as CDATA as each file is read.
+pub struct XmlRenderer<'a> {
+    args: &'a Args,
+    emoji_mapper: EmojiMapper,
+    stats: Stats,
+    loc_counter: LocCounter,
+    /// Files --strict found unreadable while writing contents
+    read_errors: Vec<String>,
+}
+
+impl<'a> XmlRenderer<'a> {
+    pub fn new(args: &'a Args) -> Self {
+        Self {
+            args,
+            emoji_mapper: EmojiMapper::new(false),
+            stats: Stats::new(),
+            loc_counter: LocCounter::new(args.loc.clone()).with_read_timeout(args.read_timeout),
+            read_errors: Vec::new(),
+        }
+    }
+
+    fn write_dir(
+        &self,
+        dir: &IrDir,
+        indent: usize,
+        plan: Option<&ContentPlan>,
+        out: &mut dyn Write,
+    ) -> io::Result<()> {
+        let pad = "  ".repeat(indent);
+        write!(
+            out,
+            "{}<directory name=\"{}\" path=\"{}\"",
+            pad,
+            escape_attr(&dir.name),
+            escape_attr(&xml_path(&dir.display_path))
+        )?;
+        if dir.dirs.is_empty() && dir.files.is_empty() {
+            return writeln!(out, "/>");
+        }
+        writeln!(out, ">")?;
+
+        for subdir in &dir.dirs {
+            self.write_dir(subdir, indent + 1, plan, out)?;
+        }
+        for file in &dir.files {
+            self.write_file(file, indent + 1, plan, out)?;
+        }
+
+        writeln!(out, "{}</directory>", pad)
+    }
+
+    fn write_file(
+        &self,
+        file: &IrFile,
+        indent: usize,
+        plan: Option<&ContentPlan>,
+        out: &mut dyn Write,
+    ) -> io::Result<()> {
+        write!(
+            out,
+            "{}<file name=\"{}\" path=\"{}\" size=\"{}\"",
+            "  ".repeat(indent),
+            escape_attr(&file.name),
+            escape_attr(&xml_path(&file.display_path)),
+            file.size_bytes
+        )?;
+        if let Some(lang) = detect_file_lang(&file.path, Sniff::from_args(self.args)) {
+            write!(out, " lang=\"{}\"", escape_attr(lang.name))?;
+        }
+        if let Some(loc) = file.loc {
+            write!(out, " lines=\"{}\"", loc)?;
+        }
+
+        let content = plan.map(|plan| plan.content_for(file, self.args));
+        let Some(FileContent::Text {
+            content,
+            truncation,
+            ..
+        }) = content
+        else {
+            // Under -c, say why a file has no content
+            if let Some(reason) = content.as_ref().and_then(FileContent::omitted_reason) {
+                write!(out, " omitted=\"{}\"", escape_attr(&reason))?;
+            }
+            return writeln!(out, "/>");
+        };
+
+        if let Some(info) = truncation.filter(|info| info.is_truncated()) {
+            write!(
+                out,
+                " truncated=\"true\" shownLines=\"{}\" totalLines=\"{}\"",
+                info.shown_lines, info.total_lines
+            )?;
+        }
+        writeln!(out, "><![CDATA[{}]]></file>", escape_cdata(&content))
+    }
+}
+
+/// Display path with forward slashes ("." for the root)
+fn xml_path(path: &std::path::Path) -> String {
+    let path = path.to_string_lossy().replace('\\', "/");
+    if path.is_empty() {
+        ".".to_string()
+    } else {
+        path
+    }
+}
+
+/// Escape text for use inside a double-quoted attribute
+fn escape_attr(value: &str) -> String {
+    let mut escaped = String::with_capacity(value.len());
+    for c in value.chars() {
+        match c {
+            '&' => escaped.push_str("&amp;"),
+            '<' => escaped.push_str("&lt;"),
+            '>' => escaped.push_str("&gt;"),
+            '"' => escaped.push_str("&quot;"),
+            '\'' => escaped.push_str("&apos;"),
+            '\n' => escaped.push_str("&#10;"),
+            '\t' => escaped.push_str("&#9;"),
+            c => escaped.push(c),
+        }
+    }
+    escaped
+}
+
+/// Split any `]]>` so it can't close the CDATA section early
+fn escape_cdata(content: &str) -> String {
+    content.replace("]]>", "]]]]><![CDATA[>")
+}
+
+impl<'a> Renderer for XmlRenderer<'a> {
+    fn write_tree(&mut self, root: &Node, out: &mut dyn Write) -> io::Result<()> {
+        self.stats.reset();
+
+        let mut ctx = AggregationContext {
+            emoji_mapper: &self.emoji_mapper,
+            stats: &mut self.stats,
+            loc_counter: &self.loc_counter,
+            dir_languages: None,
+        };
+        let mut ir = build_ir(root, &mut ctx);
+        ir.name = ".".to_string();
+
+        let plan = self
+            .args
+            .contents
+            .then(|| ContentPlan::new(&collect_files(&ir), self.args));
+        if let Some(plan) = &plan {
+            self.read_errors = plan.read_errors().to_vec();
+        }
+
+        let encoding = match self.args.output_encoding {
+            OutputEncoding::Utf8 => "UTF-8",
+            OutputEncoding::Utf16Le | OutputEncoding::Utf16Be => "UTF-16",
+        };
+        writeln!(out, "<?xml version=\"1.0\" encoding=\"{}\"?>", encoding)?;
+        self.write_dir(&ir, 0, plan.as_ref(), out)
+    }
+
+    fn render_stats(&self, _stats: &Stats) -> String {
+        // Stats are not part of the XML tree
+        String::new()
+    }
+
+    fn output_format(&self) -> OutputFormat {
+        OutputFormat::Xml
+    }
+
+    fn read_errors(&self) -> &[String] {
+        &self.read_errors
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_escape_attr() {
+        assert_eq!(
+            escape_attr(r#"a&b <c> "d" 'e'"#),
+            "a&amp;b &lt;c&gt; &quot;d&quot; &apos;e&apos;"
+        );
+    }
+
+    #[test]
+    fn test_escape_cdata_splits_terminator() {
+        assert_eq!(escape_cdata("x]]>y"), "x]]]]><![CDATA[>y");
+        assert_eq!(escape_cdata("a[0]] > b"), "a[0]] > b");
+    }
+}
@@ -0,0 +1,40 @@
+use std::path::Path;
+
+/// Check if a path is a scan root that is almost never intended:
+/// the user's home directory or a filesystem root.
+///
+/// Scanning these walks an enormous tree (and, under `$HOME`, plenty of
+/// dotfiles with secrets), so the caller should require `--force`.
+pub fn is_sensitive_root(path: &Path) -> bool {
+    // Filesystem root: "/" on Unix, "C:\" on Windows
+    if path.parent().is_none() {
+        return true;
+    }
+
+    if let Some(home) = dirs::home_dir() {
+        let home = home.canonicalize().unwrap_or(home);
+        if path == home {
+            return true;
+        }
+    }
+
+    false
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use tempfile::TempDir;
+
+    #[test]
+    fn test_filesystem_root_is_sensitive() {
+        assert!(is_sensitive_root(Path::new("/")));
+    }
+
+    #[test]
+    fn test_regular_directory_is_not_sensitive() {
+        let temp_dir = TempDir::new().unwrap();
+        let path = temp_dir.path().canonicalize().unwrap();
+        assert!(!is_sensitive_root(&path));
+    }
+}
@@ -1,4 +1,6 @@
+pub mod guard;
 pub mod presets;
 pub mod validator;
 
+pub use guard::is_sensitive_root;
 pub use presets::SafetyPreset;
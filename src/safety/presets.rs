@@ -90,9 +90,6 @@ impl SafetyPreset {
             "__pycache__/**",
             "**/__pycache__/**",
             "*.pyc",
-            // Git directory (always excluded)
-            ".git/**",
-            "**/.git/**",
             // Other sensitive or large directories
             "logs/**",
             "**/logs/**",
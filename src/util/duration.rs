@@ -0,0 +1,57 @@
+use std::time::Duration;
+
+/// Parse a duration such as "500ms", "30s", "2m", or "1h".
+/// A bare number is taken as seconds.
+pub fn parse_duration(s: &str) -> Result<Duration, String> {
+    let s = s.trim();
+    let split = s
+        .find(|c: char| !c.is_ascii_digit() && c != '.')
+        .unwrap_or(s.len());
+    let (number, unit) = s.split_at(split);
+
+    let value: f64 = number
+        .parse()
+        .map_err(|_| format!("invalid duration '{}': expected e.g. 500ms, 30s, 2m", s))?;
+    let seconds = match unit.trim() {
+        "ms" => value / 1000.0,
+        "" | "s" => value,
+        "m" => value * 60.0,
+        "h" => value * 3600.0,
+        other => {
+            return Err(format!(
+                "invalid duration unit '{}' (expected ms, s, m, or h)",
+                other
+            ))
+        }
+    };
+    Duration::try_from_secs_f64(seconds).map_err(|_| format!("duration '{}' is out of range", s))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_parse_duration_units() {
+        assert_eq!(parse_duration("500ms"), Ok(Duration::from_millis(500)));
+        assert_eq!(parse_duration("30s"), Ok(Duration::from_secs(30)));
+        assert_eq!(parse_duration("2m"), Ok(Duration::from_secs(120)));
+        assert_eq!(parse_duration("1h"), Ok(Duration::from_secs(3600)));
+        assert_eq!(parse_duration("1.5s"), Ok(Duration::from_millis(1500)));
+        assert_eq!(parse_duration("10"), Ok(Duration::from_secs(10)));
+    }
+
+    #[test]
+    fn test_parse_duration_rejects_garbage() {
+        assert!(parse_duration("").is_err());
+        assert!(parse_duration("fast").is_err());
+        assert!(parse_duration("5 days").is_err());
+    }
+
+    #[test]
+    fn test_parse_duration_rejects_overflow() {
+        let err = parse_duration("99999999999999999999").unwrap_err();
+        assert!(err.contains("out of range"), "{}", err);
+        assert!(parse_duration("99999999999999999999h").is_err());
+    }
+}
@@ -1,3 +1,7 @@
+use std::borrow::Cow;
+use std::path::Path;
+use std::time::{SystemTime, UNIX_EPOCH};
+
 /// Format bytes into human-readable size
 pub fn format_size(bytes: u64) -> String {
     const UNITS: &[&str] = &["B", "KB", "MB", "GB"];
@@ -128,6 +132,54 @@ pub fn loc_to_bar(loc: usize, max_loc_in_dir: usize, bar_width: usize) -> String
     format!("[{}{}]", "█".repeat(num_filled), "·".repeat(num_empty))
 }
 
+/// Shorten `name` to at most `max` characters, ending in "…" when cut
+/// (--max-name-length). `None` leaves the name as is.
+pub fn truncate_name(name: &str, max: Option<usize>) -> Cow<'_, str> {
+    match max {
+        Some(max) if name.chars().count() > max => {
+            let kept: String = name.chars().take(max.saturating_sub(1)).collect();
+            Cow::Owned(format!("{}…", kept))
+        }
+        _ => Cow::Borrowed(name),
+    }
+}
+
+/// Obsidian wikilink for a markdown file name, e.g. "[[notes]]" for
+/// "notes.md" (--wikilinks). None for any other file.
+pub fn wikilink(name: &str) -> Option<String> {
+    let stem = Path::new(name)
+        .extension()
+        .filter(|ext| ext.eq_ignore_ascii_case("md"))
+        .and(Path::new(name).file_stem())?;
+    Some(format!("[[{}]]", stem.to_string_lossy()))
+}
+
+/// Marker after a directory collapsed by --collapse-below, e.g. " […] (12 hidden)"
+pub fn collapsed_marker(hidden: Option<usize>) -> String {
+    hidden
+        .map(|n| format!(" […] ({} hidden)", n))
+        .unwrap_or_default()
+}
+
+/// Format a timestamp as a UTC calendar date, e.g. "2024-01-02"
+pub fn format_date(time: SystemTime) -> String {
+    let secs = match time.duration_since(UNIX_EPOCH) {
+        Ok(d) => d.as_secs() as i64,
+        Err(e) => -(e.duration().as_secs() as i64),
+    };
+    // Civil date from days since 1970-01-01 (Howard Hinnant's algorithm)
+    let z = secs.div_euclid(86_400) + 719_468;
+    let era = z.div_euclid(146_097);
+    let doe = z - era * 146_097;
+    let yoe = (doe - doe / 1460 + doe / 36_524 - doe / 146_096) / 365;
+    let doy = doe - (365 * yoe + yoe / 4 - yoe / 100);
+    let mp = (5 * doy + 2) / 153;
+    let day = doy - (153 * mp + 2) / 5 + 1;
+    let month = if mp < 10 { mp + 3 } else { mp - 9 };
+    let year = yoe + era * 400 + i64::from(month <= 2);
+    format!("{:04}-{:02}-{:02}", year, month, day)
+}
+
 /// Format lines of code for display
 pub fn format_loc_display(loc: usize) -> String {
     if loc >= 1000 {
@@ -146,6 +198,23 @@ pub fn is_global_outlier(loc: usize, threshold: usize) -> bool {
 mod tests {
     use super::*;
 
+    #[test]
+    fn test_truncate_name() {
+        assert_eq!(truncate_name("short.rs", Some(20)), "short.rs");
+        assert_eq!(truncate_name("abcdefghij.rs", Some(6)), "abcde…");
+        assert_eq!(truncate_name("日本語のファイル名", Some(4)), "日本語…");
+        assert_eq!(truncate_name("abcdefghij.rs", None), "abcdefghij.rs");
+    }
+
+    #[test]
+    fn test_wikilink() {
+        assert_eq!(wikilink("notes.md").as_deref(), Some("[[notes]]"));
+        assert_eq!(wikilink("Daily Log.MD").as_deref(), Some("[[Daily Log]]"));
+        assert_eq!(wikilink("v1.2.md").as_deref(), Some("[[v1.2]]"));
+        assert_eq!(wikilink("main.rs"), None);
+        assert_eq!(wikilink("md"), None);
+    }
+
     #[test]
     fn test_format_size() {
         assert_eq!(format_size(0), "0 B");
@@ -189,4 +258,14 @@ mod tests {
         assert_eq!(format_loc_display(1000), "1k+");
         assert_eq!(format_loc_display(5000), "1k+");
     }
+
+    #[test]
+    fn test_format_date() {
+        let at = |secs: u64| format_date(UNIX_EPOCH + std::time::Duration::from_secs(secs));
+        assert_eq!(at(0), "1970-01-01");
+        assert_eq!(at(1_704_153_600), "2024-01-02");
+        // Leap day
+        assert_eq!(at(951_782_400), "2000-02-29");
+        assert_eq!(at(86_399), "1970-01-01");
+    }
 }
@@ -0,0 +1,1144 @@
+mod fixtures;
+
+use fixtures::{p, run_tree2md, FixtureBuilder};
+
+#[test]
+fn test_skipped_files_are_silent_by_default() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("main.rs", "fn main() {}\n")
+        .file("logo.png", "not really a png")
+        .build();
+
+    let (output, _, success) = run_tree2md([p(&root), "-c".into()]);
+    assert!(success);
+
+    assert!(output.contains("## main.rs"));
+    assert!(
+        !output.contains("## logo.png"),
+        "Binary files should have no section without a placeholder: {}",
+        output
+    );
+}
+
+#[test]
+fn test_content_placeholder_for_skipped_files() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("main.rs", "fn main() {}\n")
+        .file("logo.png", "not really a png")
+        .build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--content-placeholder".into(),
+        "[skipped {path}]".into(),
+    ]);
+    assert!(success);
+
+    assert!(
+        output.contains("## logo.png\n\n[skipped logo.png]\n"),
+        "{}",
+        output
+    );
+    // Readable files still get their code block
+    assert!(output.contains("fn main() {}"));
+    assert!(!output.contains("[skipped main.rs]"));
+}
+
+#[test]
+fn test_show_omitted_content_explains_each_omission() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("a.txt", "short\n")
+        .file("logo.png", "not really a png")
+        .file("z_big.txt", &"word ".repeat(400))
+        .build();
+
+    let args = |extra: &[&str]| {
+        let mut args = vec![
+            p(&root),
+            "-c".into(),
+            "--max-tokens".into(),
+            "50".into(),
+            "--stats".into(),
+            "off".into(),
+        ];
+        args.extend(extra.iter().map(|s| s.to_string()));
+        args
+    };
+
+    let (output, stderr, success) = run_tree2md(args(&["--show-omitted-content"]));
+    assert!(success, "{}", stderr);
+    assert!(output.contains("short"), "{}", output);
+    assert!(
+        output.contains("## logo.png\n\n_Contents omitted: binary file_\n"),
+        "{}",
+        output
+    );
+    assert!(
+        output.contains("## z_big.txt\n\n_Contents omitted: over the --max-tokens budget_\n"),
+        "{}",
+        output
+    );
+
+    // Without the flag, both are left out silently (apart from the budget note)
+    let (output, _, success) = run_tree2md(args(&[]));
+    assert!(success);
+    assert!(!output.contains("Contents omitted"), "{}", output);
+    assert!(!output.contains("## logo.png"), "{}", output);
+}
+
+#[test]
+fn test_frontmatter_only_keeps_markdown_front_matter() {
+    let body: String = (1..=40).map(|i| format!("Paragraph {}\n\n", i)).collect();
+    let (_tmp, root) = FixtureBuilder::new()
+        .file(
+            "docs/guide.md",
+            &format!("---\ntitle: Guide\nweight: 2\n---\n# Guide\n\n{}", body),
+        )
+        .file("docs/plain.md", "# No front matter\n")
+        .file("main.go", "package main\n")
+        .build();
+
+    let (output, stderr, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--frontmatter-only".into(),
+        "--stats".into(),
+        "off".into(),
+    ]);
+    assert!(success, "{}", stderr);
+
+    assert!(
+        output.contains("```markdown\n---\ntitle: Guide\nweight: 2\n---\n```"),
+        "{}",
+        output
+    );
+    assert!(!output.contains("# Guide"), "{}", output);
+    assert!(!output.contains("Paragraph"), "{}", output);
+    assert!(!output.contains("# No front matter"), "{}", output);
+    // A document without front matter gets no section, not an empty fence
+    assert!(!output.contains("## docs/plain.md"), "{}", output);
+    // Other files are unaffected
+    assert!(output.contains("package main"), "{}", output);
+}
+
+#[test]
+fn test_split_content_on_markers() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file(
+            "tour.py",
+            "import os\n# --- Setup\nx = 1\n# --- Run\nprint(x)\n",
+        )
+        .file("plain.py", "y = 2\n")
+        .build();
+
+    let (output, stderr, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--split-content-on".into(),
+        "^# ---".into(),
+        "--stats".into(),
+        "off".into(),
+    ]);
+    assert!(success, "{}", stderr);
+
+    assert!(
+        output.contains(
+            "## tour.py\n\n```python\nimport os\n```\n\n\
+             ### # --- Setup\n\n```python\nx = 1\n```\n\n\
+             ### # --- Run\n\n```python\nprint(x)\n```\n"
+        ),
+        "{}",
+        output
+    );
+    // Files without markers keep a single block
+    assert!(
+        output.contains("## plain.py\n\n```python\ny = 2\n```\n"),
+        "{}",
+        output
+    );
+}
+
+#[test]
+fn test_minify_json_compacts_json_files_only() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file(
+            "config.json",
+            "{\n  \"name\": \"my app\",\n  \"ports\": [\n    80,\n    443\n  ]\n}\n",
+        )
+        .file("broken.json", "{\n  \"a\": 1,\n}\n")
+        .file("notes.txt", "keep  my   spacing\n")
+        .build();
+
+    let (output, stderr, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--minify-json".into(),
+        "--stats".into(),
+        "off".into(),
+    ]);
+    assert!(success, "{}", stderr);
+
+    assert!(
+        output.contains("```json\n{\"name\":\"my app\",\"ports\":[80,443]}\n```"),
+        "{}",
+        output
+    );
+    // Invalid JSON is emitted untouched, with a warning
+    assert!(output.contains("{\n  \"a\": 1,\n}\n"), "{}", output);
+    assert!(
+        stderr.contains("'broken.json' is not valid JSON"),
+        "{}",
+        stderr
+    );
+    // Other files are unaffected
+    assert!(output.contains("keep  my   spacing"), "{}", output);
+
+    // Planning a --max-chars budget reads the file too, but warns only once
+    let (_, stderr, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--minify-json".into(),
+        "--max-chars".into(),
+        "100000".into(),
+    ]);
+    assert!(success, "{}", stderr);
+    assert_eq!(stderr.matches("is not valid JSON").count(), 1, "{}", stderr);
+}
+
+#[test]
+fn test_pretty_json_reindents_minified_json() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file(
+            "data.json",
+            "{\"name\":\"app\",\"ports\":[80,443],\"tls\":{}}",
+        )
+        .build();
+
+    let (output, stderr, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--pretty-json".into(),
+        "--stats".into(),
+        "off".into(),
+    ]);
+    assert!(success, "{}", stderr);
+    assert!(
+        output.contains(
+            "```json\n{\n  \"name\": \"app\",\n  \"ports\": [\n    80,\n    443\n  ],\n  \"tls\": {}\n}\n```"
+        ),
+        "{}",
+        output
+    );
+
+    // The two JSON modes cannot be combined
+    let (_, stderr, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--pretty-json".into(),
+        "--minify-json".into(),
+    ]);
+    assert!(!success);
+    assert!(stderr.contains("cannot be used with"), "{}", stderr);
+}
+
+#[test]
+fn test_pretty_json_warns_once_under_max_chars() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("broken.json", "{\"a\": 1,}")
+        .build();
+
+    let (output, stderr, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--pretty-json".into(),
+        "--max-chars".into(),
+        "100000".into(),
+    ]);
+    assert!(success, "{}", stderr);
+    assert!(output.contains("{\"a\": 1,}"), "{}", output);
+    assert_eq!(
+        stderr
+            .matches("--pretty-json: 'broken.json' is not valid JSON")
+            .count(),
+        1,
+        "{}",
+        stderr
+    );
+}
+
+#[test]
+fn test_content_placeholder_with_max_chars() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("a.txt", "a1\na2\na3\n")
+        .file("archive.zip", "PK")
+        .build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--max-chars".into(),
+        "4".into(),
+        "--content-placeholder".into(),
+        "(omitted)".into(),
+    ]);
+    assert!(success);
+
+    // Binary files fall back to the placeholder in budget mode too
+    assert!(
+        output.contains("## archive.zip\n\n(omitted)\n"),
+        "{}",
+        output
+    );
+}
+
+#[test]
+fn test_content_replace_applies_substitutions_in_order() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file(
+            "config.toml",
+            "root = \"/home/alice/project\"\nname = \"demo\"\n",
+        )
+        .build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--content-replace".into(),
+        "/home/[a-z]+/=$ROOT/".into(),
+        "--content-replace".into(),
+        "demo=example".into(),
+    ]);
+    assert!(success);
+
+    assert!(
+        output.contains("root = \"$ROOT/project\""),
+        "Absolute path should be replaced: {}",
+        output
+    );
+    assert!(output.contains("name = \"example\""));
+    assert!(!output.contains("/home/alice"));
+}
+
+#[test]
+fn test_content_replace_rejects_invalid_spec() {
+    let (_tmp, root) = FixtureBuilder::new().file("a.txt", "a").build();
+
+    let (_, stderr, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--content-replace".into(),
+        "missing-separator".into(),
+    ]);
+    assert!(!success);
+    assert!(stderr.contains("REGEX=REPLACEMENT"), "{}", stderr);
+}
+
+#[test]
+fn test_content_range_emits_only_selected_lines() {
+    let body: String = (1..=10).map(|i| format!("line {}\n", i)).collect();
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("src/lib.rs", &body)
+        .file("notes.txt", "untouched\n")
+        .build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--content-range".into(),
+        "src/lib.rs:4-6".into(),
+    ]);
+    assert!(success);
+
+    assert!(
+        output
+            .contains("## src/lib.rs (lines 4-6 of 10)\n\n```rust\nline 4\nline 5\nline 6\n```\n"),
+        "Only lines 4-6 should be emitted: {}",
+        output
+    );
+    assert!(!output.contains("line 3\n"));
+    assert!(!output.contains("line 7\n"));
+    // Files without a range are emitted whole
+    assert!(
+        output.contains("## notes.txt\n\n```\nuntouched\n```\n"),
+        "{}",
+        output
+    );
+}
+
+#[test]
+fn test_content_range_mid_file_notes_total_lines() {
+    let body: String = (1..=200).map(|i| format!("fn f{}() {{}}\n", i)).collect();
+    let (_tmp, root) = FixtureBuilder::new().file("big.rs", &body).build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--content-range".into(),
+        "big.rs:40-80".into(),
+        "--stats".into(),
+        "off".into(),
+    ]);
+    assert!(success);
+
+    assert!(
+        output.contains("## big.rs (lines 40-80 of 200)\n\n```rust\nfn f40() {}\n"),
+        "{}",
+        output
+    );
+    assert!(output.contains("fn f80() {}\n```\n"), "{}", output);
+    assert!(!output.contains("fn f39() {}"), "{}", output);
+    assert!(!output.contains("fn f81() {}"), "{}", output);
+
+    // The total counts the lines read, whatever --loc counts
+    for loc in ["off", "accurate"] {
+        let (output, _, success) = run_tree2md([
+            p(&root),
+            "-c".into(),
+            "--content-range".into(),
+            "big.rs:40-".into(),
+            "--loc".into(),
+            loc.into(),
+            "--stats".into(),
+            "off".into(),
+        ]);
+        assert!(success);
+        assert!(
+            output.contains("## big.rs (lines 40-end of 200)\n"),
+            "{}",
+            output
+        );
+    }
+}
+
+#[test]
+fn test_content_range_total_counts_blank_lines() {
+    let body: String = (1..=10).map(|i| format!("line {}\n\n", i)).collect();
+    let (_tmp, root) = FixtureBuilder::new().file("notes.md", &body).build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--content-range".into(),
+        "notes.md:1-3".into(),
+        "--loc".into(),
+        "accurate".into(),
+        "--stats".into(),
+        "off".into(),
+    ]);
+    assert!(success);
+    assert!(
+        output.contains("## notes.md (lines 1-3 of 20)\n"),
+        "{}",
+        output
+    );
+}
+
+#[test]
+fn test_sniff_content_uses_modeline_filetype() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file(".linterrc", "# vim: set ft=yaml:\nrules:\n  strict: true\n")
+        .build();
+
+    let (output, _, success) = run_tree2md([p(&root), "-c".into(), "--sniff-content".into()]);
+    assert!(success);
+    assert!(
+        output.contains("## .linterrc\n\n```yaml\n"),
+        "Modeline filetype should set the fence language: {}",
+        output
+    );
+
+    // Without the flag the file has no language
+    let (output, _, success) = run_tree2md([p(&root), "-c".into()]);
+    assert!(success);
+    assert!(output.contains("## .linterrc\n\n```\n"), "{}", output);
+}
+
+#[test]
+fn test_preview_larger_than_truncates_only_large_files() {
+    let big: String = (1..=50).map(|i| format!("big {}\n", i)).collect();
+    let small: String = (1..=8).map(|i| format!("small {}\n", i)).collect();
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("big.txt", &big)
+        .file("small.txt", &small)
+        .build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--preview-larger-than".into(),
+        "100".into(),
+        "--preview-lines".into(),
+        "3".into(),
+    ]);
+    assert!(success);
+
+    // big.txt (over 100 bytes) is cut to its first 3 lines with a note
+    assert!(
+        output.contains("```\nbig 1\nbig 2\nbig 3\n... (47 lines omitted)\n```\n"),
+        "Large file should be previewed: {}",
+        output
+    );
+    assert!(!output.contains("big 4\n"));
+
+    // small.txt (under 100 bytes) is shown in full
+    assert!(
+        output.contains(&format!("```\n{}```\n", small)),
+        "Small file should be shown in full: {}",
+        output
+    );
+}
+
+#[test]
+fn test_preview_lines_defaults_to_twenty() {
+    let big: String = (1..=30).map(|i| format!("line {}\n", i)).collect();
+    let (_tmp, root) = FixtureBuilder::new().file("big.txt", &big).build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--preview-larger-than".into(),
+        "10".into(),
+    ]);
+    assert!(success);
+    assert!(
+        output.contains("line 20\n... (10 lines omitted)\n"),
+        "{}",
+        output
+    );
+    assert!(!output.contains("line 21\n"));
+}
+
+#[test]
+fn test_truncate_ext_overrides_global_preview() {
+    let numbered = |prefix: &str, n: usize| -> String {
+        (1..=n).map(|i| format!("{} {}\n", prefix, i)).collect()
+    };
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("data.json", &numbered("json", 30))
+        .file("rows.csv", &numbered("csv", 30))
+        .file("main.go", &numbered("go", 30))
+        .file("notes.txt", &numbered("txt", 30))
+        .build();
+
+    let (output, stderr, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--truncate-ext".into(),
+        ".json=20,.csv=5".into(),
+        "--preview-larger-than".into(),
+        "100".into(),
+        "--preview-lines".into(),
+        "3".into(),
+        "--stats".into(),
+        "off".into(),
+    ]);
+    assert!(success, "{}", stderr);
+
+    // Listed extensions use their own limits
+    assert!(
+        output.contains("json 20\n... (10 lines omitted)\n"),
+        "{}",
+        output
+    );
+    assert!(!output.contains("json 21\n"), "{}", output);
+    assert!(
+        output.contains("csv 5\n... (25 lines omitted)\n"),
+        "{}",
+        output
+    );
+    assert!(!output.contains("csv 6\n"), "{}", output);
+    // Everything else follows the global preview
+    assert!(
+        output.contains("go 3\n... (27 lines omitted)\n"),
+        "{}",
+        output
+    );
+    assert!(
+        output.contains("txt 3\n... (27 lines omitted)\n"),
+        "{}",
+        output
+    );
+
+    // Without a global preview, unlisted files are shown in full
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--truncate-ext".into(),
+        ".json=20".into(),
+        "--stats".into(),
+        "off".into(),
+    ]);
+    assert!(success);
+    assert!(!output.contains("json 21\n"), "{}", output);
+    assert!(output.contains("go 30\n"), "{}", output);
+}
+
+#[test]
+fn test_normalize_indent_mixed_files() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("tabs.go", "func main() {\n\tif x {\n\t\ty()\n\t}\n}\n")
+        .file("two.js", "function f() {\n  if (x) {\n    y();\n  }\n}\n")
+        .file("script.py", "def f():\n  return 1\n")
+        .build();
+
+    let (output, _, success) = run_tree2md([p(&root), "-c".into(), "--normalize-indent".into()]);
+    assert!(success);
+
+    assert!(
+        output.contains("func main() {\n    if x {\n        y()\n    }\n}\n"),
+        "Tabs should become 4 spaces per level: {}",
+        output
+    );
+    assert!(
+        output.contains("function f() {\n    if (x) {\n        y();\n    }\n}\n"),
+        "2-space indent should become 4 spaces per level: {}",
+        output
+    );
+    assert!(
+        output.contains("def f():\n  return 1\n"),
+        "Python must be left untouched: {}",
+        output
+    );
+}
+
+#[test]
+fn test_heading_style_variants() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("src/main.rs", "fn main() {}\n")
+        .build();
+
+    let cases = [
+        ("path", "\n## src/main.rs\n"),
+        ("name", "\n## main.rs\n"),
+        ("name-with-path", "\n## main.rs `src/main.rs`\n"),
+    ];
+    for (style, expected) in cases {
+        let (output, _, success) = run_tree2md([
+            p(&root),
+            "-c".into(),
+            "--heading-style".into(),
+            style.into(),
+        ]);
+        assert!(success);
+        assert!(
+            output.contains(expected),
+            "{}: expected {:?} in {}",
+            style,
+            expected,
+            output
+        );
+    }
+}
+
+#[test]
+fn test_trim_blank_lines_keeps_internal_blanks() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("padded.rs", "\n\n  \nfn a() {}\n\nfn b() {}\n\n\n")
+        .build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--stats".into(),
+        "off".into(),
+        "--trim-blank-lines".into(),
+    ]);
+    assert!(success);
+    assert!(
+        output.contains("```rust\nfn a() {}\n\nfn b() {}\n```"),
+        "Outer blank lines should be stripped: {}",
+        output
+    );
+
+    let (untrimmed, _, _) = run_tree2md([p(&root), "-c".into(), "--stats".into(), "off".into()]);
+    assert!(
+        untrimmed.contains("```rust\n\n\n  \nfn a() {}"),
+        "{}",
+        untrimmed
+    );
+}
+
+#[test]
+fn test_trim_blank_lines_adjusts_truncation_counts() {
+    let body: String = (0..20).map(|i| format!("line {}\n", i)).collect();
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("padded.txt", &format!("\n\n\n\n{}", body))
+        .build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--stats".into(),
+        "off".into(),
+        "--preview-larger-than".into(),
+        "10".into(),
+        "--preview-lines".into(),
+        "8".into(),
+        "--truncation-format".into(),
+        "shown {shownLines} of {totalLines}, {omittedLines} omitted".into(),
+        "--trim-blank-lines".into(),
+    ]);
+    assert!(success);
+    // The preview still takes the first 8 lines of the file on disk
+    assert!(output.contains("line 3\n"), "{}", output);
+    assert!(!output.contains("line 4\n"), "{}", output);
+    assert!(output.contains("shown 4 of 20, 16 omitted"), "{}", output);
+}
+
+#[test]
+fn test_content_if_matches_emits_only_matching_files() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("hit.rs", "fn a() {}\n// TODO: fix\nfn b() {}\n")
+        .file("miss.rs", "fn c() {}\n")
+        .file("also_hit.txt", "todo later? TODO yes\n")
+        .build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--stats".into(),
+        "off".into(),
+        "--content-if-matches".into(),
+        r"TODO\b".into(),
+    ]);
+    assert!(success);
+
+    // The whole file is emitted, not just the matching line
+    assert!(
+        output.contains("```rust\nfn a() {}\n// TODO: fix\nfn b() {}\n```"),
+        "{}",
+        output
+    );
+    assert!(output.contains("TODO yes"), "{}", output);
+    assert!(!output.contains("fn c()"), "{}", output);
+    // The tree still lists every file
+    assert!(output.contains("miss.rs"), "{}", output);
+    assert!(!output.contains("## miss.rs"), "{}", output);
+}
+
+#[test]
+fn test_content_if_matches_rejects_invalid_regex() {
+    let (_tmp, root) = FixtureBuilder::new().file("a.txt", "a\n").build();
+
+    let (_, stderr, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--content-if-matches".into(),
+        "(".into(),
+    ]);
+    assert!(!success);
+    assert!(stderr.contains("--content-if-matches"), "{}", stderr);
+}
+
+#[test]
+fn test_max_line_length_truncates_long_lines() {
+    let minified = format!("(function(){{var a={};}})();", "1".repeat(500));
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("bundle.js", &format!("{}\nshort();\n", minified))
+        .build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--stats".into(),
+        "off".into(),
+        "--max-line-length".into(),
+        "20".into(),
+    ]);
+    assert!(success);
+
+    assert!(
+        output.contains("```javascript\n(function(){var a=11… [line truncated]\nshort();\n```"),
+        "{}",
+        output
+    );
+    // Line counts in the tree describe the full file
+    assert!(output.contains("bundle.js  (2 lines)"), "{}", output);
+}
+
+#[test]
+fn test_content_match_limits_contents_not_tree() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("main.go", "package main\n")
+        .file("pkg/util/util.go", "package util\n")
+        .file("README.md", "# Readme\n")
+        .file("scripts/run.py", "print('run')\n")
+        .build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--stats".into(),
+        "off".into(),
+        "--content-match".into(),
+        "**/*.go".into(),
+    ]);
+    assert!(success);
+
+    // Tree lists every file
+    for name in ["main.go", "util.go", "README.md", "run.py"] {
+        assert!(output.contains(name), "{} missing: {}", name, output);
+    }
+    // Only Go files get code blocks
+    assert!(output.contains("package main"), "{}", output);
+    assert!(output.contains("package util"), "{}", output);
+    assert!(!output.contains("# Readme"), "{}", output);
+    assert!(!output.contains("print('run')"), "{}", output);
+}
+
+#[cfg(unix)]
+#[test]
+fn test_fifo_is_labeled_and_not_read() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("main.rs", "fn main() {}\n")
+        .build();
+    let status = std::process::Command::new("mkfifo")
+        .arg(root.join("pipe"))
+        .status()
+        .expect("mkfifo");
+    assert!(status.success());
+
+    // Opening the FIFO for reading would block forever with no writer
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--sniff-content".into(),
+        "--stats".into(),
+        "off".into(),
+    ]);
+    assert!(success);
+
+    assert!(output.contains("pipe (fifo)"), "{}", output);
+    assert!(output.contains("fn main() {}"), "{}", output);
+    assert!(!output.contains("## pipe"), "{}", output);
+}
+
+#[test]
+fn test_content_paths_emits_only_listed_files() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("a/b.go", "package b\n")
+        .file("a/other.go", "package other\n")
+        .file("c/d.py", "print('d')\n")
+        .file("README.md", "# Readme\n")
+        .build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--stats".into(),
+        "off".into(),
+        "--content-paths".into(),
+        "a/b.go,./c/d.py".into(),
+        // Other content filters are ignored when paths are listed
+        "--content-match".into(),
+        "*.md".into(),
+    ]);
+    assert!(success);
+
+    // Tree lists every file
+    for name in ["b.go", "other.go", "d.py", "README.md"] {
+        assert!(output.contains(name), "{} missing: {}", name, output);
+    }
+    assert!(output.contains("package b"), "{}", output);
+    assert!(output.contains("print('d')"), "{}", output);
+    assert!(!output.contains("package other"), "{}", output);
+    assert!(!output.contains("# Readme"), "{}", output);
+}
+
+#[cfg(unix)]
+#[test]
+fn test_follow_symlinks_reads_link_target() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("shared/util.go", "package shared\n")
+        .dir("lib")
+        .build();
+    std::os::unix::fs::symlink("../shared/util.go", root.join("lib/util.go")).unwrap();
+    std::os::unix::fs::symlink("../missing.go", root.join("lib/broken.go")).unwrap();
+
+    let args = |follow: bool| {
+        let mut args = vec![p(&root), "-c".into(), "--stats".into(), "off".into()];
+        if follow {
+            args.push("--follow-symlinks".into());
+        }
+        args
+    };
+
+    // Without the flag, symlinks are skipped
+    let (output, _, success) = run_tree2md(args(false));
+    assert!(success);
+    assert!(!output.contains("lib/util.go"), "{}", output);
+    assert!(!output.contains("broken.go"), "{}", output);
+
+    let (output, _, success) = run_tree2md(args(true));
+    assert!(success);
+    assert!(
+        output.contains("## lib/util.go -> ../shared/util.go\n\n```go\npackage shared\n```"),
+        "{}",
+        output
+    );
+    // A broken link is listed but never read
+    assert!(output.contains("broken.go"), "{}", output);
+    assert!(!output.contains("## lib/broken.go"), "{}", output);
+}
+
+#[test]
+fn test_normalize_eol_converts_crlf() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("win.txt", "first\r\nsecond\r\n")
+        .build();
+
+    let args = |normalize: bool| {
+        let mut args = vec![p(&root), "-c".into(), "--stats".into(), "off".into()];
+        if normalize {
+            args.push("--normalize-eol".into());
+        }
+        args
+    };
+
+    let (output, _, success) = run_tree2md(args(false));
+    assert!(success);
+    assert!(output.contains("first\r\nsecond\r\n"), "{:?}", output);
+
+    let (output, _, success) = run_tree2md(args(true));
+    assert!(success);
+    assert!(output.contains("```\nfirst\nsecond\n```"), "{:?}", output);
+    assert!(!output.contains('\r'), "{:?}", output);
+}
+
+#[test]
+fn test_heading_meta_shows_lines_and_size() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("src/main.go", "package main\n\nfunc main() {}\n")
+        .build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--stats".into(),
+        "off".into(),
+        "--heading-meta".into(),
+    ]);
+    assert!(success);
+    assert!(
+        output.contains("## src/main.go (3 lines, 29 B)\n"),
+        "{}",
+        output
+    );
+
+    // Truncated sections still describe the whole file
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--stats".into(),
+        "off".into(),
+        "--heading-meta".into(),
+        "--max-chars".into(),
+        "10".into(),
+    ]);
+    assert!(success);
+    assert!(
+        output.contains("## src/main.go (3 lines, 29 B)\n"),
+        "{}",
+        output
+    );
+}
+
+#[test]
+fn test_content_depth_and_level_are_independent() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("top.txt", "level one\n")
+        .file("a/mid.txt", "level two\n")
+        .file("a/b/deep.txt", "level three\n")
+        .file("a/b/c/deeper.txt", "level four\n")
+        .build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--stats".into(),
+        "off".into(),
+        "-L".into(),
+        "3".into(),
+        "--content-depth".into(),
+        "2".into(),
+    ]);
+    assert!(success);
+
+    // The tree goes three levels deep
+    for name in ["top.txt", "mid.txt", "deep.txt"] {
+        assert!(output.contains(name), "{} missing: {}", name, output);
+    }
+    assert!(!output.contains("deeper.txt"), "{}", output);
+    // Contents stop at level two
+    assert!(output.contains("level one"), "{}", output);
+    assert!(output.contains("level two"), "{}", output);
+    assert!(!output.contains("level three"), "{}", output);
+    // Files elided by -L get no contents, whatever --content-depth allows
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--stats".into(),
+        "off".into(),
+        "-L".into(),
+        "1".into(),
+        "--content-depth".into(),
+        "4".into(),
+    ]);
+    assert!(success);
+    assert!(output.contains("level one"), "{}", output);
+    assert!(!output.contains("level two"), "{}", output);
+    assert!(!output.contains("level four"), "{}", output);
+}
+
+#[cfg(unix)]
+#[test]
+fn test_strict_fails_on_unreadable_file() {
+    use std::os::unix::fs::PermissionsExt;
+
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("main.rs", "fn main() {}\n")
+        .file("secret.txt", "hidden\n")
+        .build();
+    let secret = root.join("secret.txt");
+    std::fs::set_permissions(&secret, std::fs::Permissions::from_mode(0o000)).unwrap();
+    // Permission bits do not apply to root; nothing to test there
+    if std::fs::read(&secret).is_ok() {
+        return;
+    }
+
+    let lenient = run_tree2md([p(&root), "-c".into(), "--stats".into(), "off".into()]);
+    assert!(lenient.2, "{}", lenient.1);
+    assert!(lenient.0.contains("fn main() {}"), "{}", lenient.0);
+
+    let (output, stderr, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--strict".into(),
+        "--show-omitted-content".into(),
+        "--stats".into(),
+        "off".into(),
+    ]);
+    assert!(!success);
+    // The rest of the output is still written, with a placeholder
+    assert!(output.contains("fn main() {}"), "{}", output);
+    assert!(
+        output.contains("## secret.txt\n\n_Contents omitted: unreadable_"),
+        "{}",
+        output
+    );
+    assert!(
+        stderr.contains("Error: cannot read 'secret.txt'"),
+        "{}",
+        stderr
+    );
+    assert!(stderr.contains("1 path(s) could not be read"), "{}", stderr);
+}
+
+#[test]
+fn test_note_bom_flags_bom_without_altering_contents() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("data.csv", "\u{feff}id,name\n1,a\n")
+        .file("plain.csv", "id,name\n")
+        .build();
+
+    let (output, stderr, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--note-bom".into(),
+        "--stats".into(),
+        "off".into(),
+    ]);
+    assert!(success, "{}", stderr);
+    assert!(
+        output.contains("```\n\u{feff}id,name\n1,a\n[UTF-8 BOM detected]\n```"),
+        "{:?}",
+        output
+    );
+    assert_eq!(
+        output.matches("[UTF-8 BOM detected]").count(),
+        1,
+        "{}",
+        output
+    );
+}
+
+#[test]
+fn test_truncate_at_boundary_ends_on_closing_brace() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file(
+            "main.go",
+            "package main\n\nfunc a() {\n\tone()\n\ttwo()\n}\n\nfunc b() {\n\tthree()\n\tfour()\n\tfive()\n}\n\nfunc c() {}\n",
+        )
+        .build();
+
+    let run = |boundary: bool| {
+        let mut args = vec![
+            p(&root),
+            "-c".into(),
+            "--truncate-ext".into(),
+            ".go=10".into(),
+            "--stats".into(),
+            "off".into(),
+        ];
+        if boundary {
+            args.push("--truncate-at-boundary".into());
+        }
+        let (output, stderr, success) = run_tree2md(args);
+        assert!(success, "{}", stderr);
+        output
+    };
+
+    // A plain cut lands inside b()
+    let output = run(false);
+    assert!(
+        output.contains("\tfour()\n... (4 lines omitted)\n"),
+        "{}",
+        output
+    );
+
+    // The boundary cut finishes b() instead
+    let output = run(true);
+    assert!(
+        output.contains("\tfive()\n}\n... (2 lines omitted)\n"),
+        "{}",
+        output
+    );
+    assert!(!output.contains("func c"), "{}", output);
+}
+
+#[test]
+fn test_truncate_at_boundary_stays_within_max_chars() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file(
+            "main.go",
+            "package main\n\nfunc a() {\n\tone()\n\ttwo()\n\tthree()\n}\n\nfunc c() {}\n",
+        )
+        .build();
+
+    // 45 chars fit the first 5 lines; the nearest boundary is the closing
+    // brace two lines later, which would overshoot
+    let (output, stderr, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--max-chars".into(),
+        "45".into(),
+        "--truncate-at-boundary".into(),
+        "--stats".into(),
+        "off".into(),
+    ]);
+    assert!(success, "{}", stderr);
+
+    let block = output
+        .split("```go\n")
+        .nth(1)
+        .and_then(|rest| rest.split("```").next())
+        .expect("a go code block");
+    let kept: String = block
+        .lines()
+        .filter(|line| !line.ends_with("lines omitted)"))
+        .map(|line| format!("{}\n", line))
+        .collect();
+    assert!(kept.len() <= 45, "{} chars: {}", kept.len(), output);
+    assert_eq!(kept, "package main\n", "{}", output);
+}
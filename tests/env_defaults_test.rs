@@ -0,0 +1,101 @@
+mod fixtures;
+
+use fixtures::{p, run_tree2md, run_tree2md_with_env, FixtureBuilder};
+
+#[test]
+fn test_env_contents_enables_contents() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("main.rs", "fn main() {}\n")
+        .build();
+
+    let (output, _, success) = run_tree2md_with_env([p(&root)], [("TREE2MD_CONTENTS", "1")]);
+    assert!(success);
+    assert!(output.contains("## main.rs"), "{}", output);
+
+    let (output, _, success) = run_tree2md_with_env([p(&root)], [("TREE2MD_CONTENTS", "0")]);
+    assert!(success);
+    assert!(!output.contains("## main.rs"), "{}", output);
+}
+
+#[test]
+fn test_env_level_used_when_flag_absent() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("top.txt", "top")
+        .file("a/b/deep.txt", "deep")
+        .build();
+
+    let (output, _, success) = run_tree2md([p(&root)]);
+    assert!(success);
+    assert!(output.contains("deep.txt"));
+
+    let (output, _, success) = run_tree2md_with_env([p(&root)], [("TREE2MD_LEVEL", "1")]);
+    assert!(success);
+    assert!(output.contains("a/"));
+    assert!(!output.contains("b/"), "Env level should apply: {}", output);
+}
+
+#[test]
+fn test_flag_overrides_env() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("top.txt", "top")
+        .file("a/b/deep.txt", "deep")
+        .build();
+
+    let (output, _, success) = run_tree2md_with_env(
+        [p(&root), "-L".into(), "3".into()],
+        [("TREE2MD_LEVEL", "1")],
+    );
+    assert!(success);
+    assert!(output.contains("deep.txt"), "Flag should win: {}", output);
+}
+
+#[test]
+fn test_env_satisfies_contents_requirement() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("a.txt", "one\ntwo\nthree\nfour\nfive\n")
+        .build();
+
+    let (output, _, success) = run_tree2md_with_env(
+        [p(&root)],
+        [("TREE2MD_CONTENTS", "true"), ("TREE2MD_MAX_CHARS", "8")],
+    );
+    assert!(success);
+    assert!(output.contains("lines omitted)"), "{}", output);
+}
+
+#[test]
+fn test_no_recurse_overrides_env_level() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("top.txt", "top")
+        .file("a/b/deep.txt", "deep")
+        .build();
+
+    let (output, stderr, success) =
+        run_tree2md_with_env([p(&root), "-R".into()], [("TREE2MD_LEVEL", "2")]);
+    assert!(success, "{}", stderr);
+    assert!(output.contains("a/"));
+    assert!(!output.contains("b/"), "-R should win: {}", output);
+}
+
+#[test]
+fn test_env_max_chars_without_contents_is_ignored() {
+    let (_tmp, root) = FixtureBuilder::new().file("a.txt", "one\n").build();
+
+    let (output, stderr, success) =
+        run_tree2md_with_env([p(&root)], [("TREE2MD_MAX_CHARS", "8000")]);
+    assert!(success, "{}", stderr);
+    assert!(output.contains("a.txt"));
+    assert!(!output.contains("## a.txt"), "{}", output);
+}
+
+#[test]
+fn test_false_env_contents_does_not_satisfy_requirement() {
+    let (_tmp, root) = FixtureBuilder::new().file("a.txt", "one\n").build();
+
+    let (_, stderr, success) = run_tree2md_with_env(
+        [p(&root), "--max-chars".into(), "8".into()],
+        [("TREE2MD_CONTENTS", "0")],
+    );
+    assert!(!success);
+    assert!(stderr.contains("--contents"), "{}", stderr);
+}
@@ -123,3 +123,38 @@ fn test_exclude_dotfile_pattern() {
         "Should not include .gitignore"
     );
 }
+
+#[test]
+fn test_exclude_path_removes_only_the_exact_path() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("src/generated/big.go", "package generated\n")
+        .file("src/generated/big_test.go", "package generated\n")
+        .file("lib/generated/big.go", "package lib\n")
+        .file("tools/only.go", "package tools\n")
+        .dir("empty")
+        .build();
+
+    let (output, stderr, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--stats".into(),
+        "off".into(),
+        "--exclude-path".into(),
+        "src/generated/big.go".into(),
+        "--exclude-path".into(),
+        "./tools/only.go".into(),
+    ]);
+    assert!(success, "{}", stderr);
+
+    assert!(!output.contains("## src/generated/big.go\n"), "{}", output);
+    // Similarly named files elsewhere stay
+    assert!(
+        output.contains("## src/generated/big_test.go\n"),
+        "{}",
+        output
+    );
+    assert!(output.contains("## lib/generated/big.go\n"), "{}", output);
+    // A directory left empty is pruned; one that was already empty stays
+    assert!(!output.contains("tools"), "{}", output);
+    assert!(output.contains("empty/"), "{}", output);
+}
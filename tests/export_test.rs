@@ -0,0 +1,47 @@
+mod fixtures;
+
+use fixtures::{p, run_tree2md, FixtureBuilder};
+
+#[test]
+fn test_export_dir_mirrors_tree_with_linked_index() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("src/main.rs", "fn main() {}\n")
+        .file("src/util/helpers.go", "package util\n")
+        .file("README.md", "# Project\n")
+        .file("logo.png", "not really a png")
+        .build();
+    let out = tempfile::TempDir::new().unwrap();
+    let out_dir = out.path().join("site");
+
+    let (stdout, stderr, success) = run_tree2md([
+        p(&root),
+        "--export-dir".into(),
+        p(&out_dir),
+        "--stats".into(),
+        "off".into(),
+    ]);
+    assert!(success, "{}", stderr);
+    assert!(stdout.is_empty(), "nothing is printed: {}", stdout);
+    assert!(stderr.contains("Exported 3 page(s)"), "{}", stderr);
+
+    // One page per text file, mirroring the source layout
+    let page = std::fs::read_to_string(out_dir.join("src/main.rs.md")).unwrap();
+    assert_eq!(page, "# src/main.rs\n\n```rust\nfn main() {}\n```\n");
+    let page = std::fs::read_to_string(out_dir.join("src/util/helpers.go.md")).unwrap();
+    assert!(page.contains("```go\npackage util\n```"), "{}", page);
+    assert!(out_dir.join("README.md.md").is_file());
+    // Binary files get no page
+    assert!(!out_dir.join("logo.png.md").exists());
+
+    let index = std::fs::read_to_string(out_dir.join("index.md")).unwrap();
+    assert_eq!(
+        index,
+        "# Index\n\n\
+         - src/\n  \
+           - util/\n    \
+             - [helpers.go](src/util/helpers.go.md)\n  \
+           - [main.rs](src/main.rs.md)\n\
+         - [README.md](README.md.md)\n\
+         - logo.png\n"
+    );
+}
@@ -289,3 +289,163 @@ fn test_complex_precedence_scenario() {
     // Normal files included
     assert!(output.contains("main.rs"));
 }
+
+#[test]
+fn test_keep_empty_dirs_preserves_emptied_directories() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("src/main.rs", "fn main() {}")
+        .file("docs/guide.md", "# Guide")
+        .file("docs/api/index.md", "# API")
+        .build();
+
+    // By default, directories emptied by -I are pruned
+    let (output, _, success) = run_tree2md([p(&root), "-I".into(), "*.rs".into()]);
+    assert!(success);
+    assert!(output.contains("main.rs"));
+    assert!(
+        !output.contains("docs/"),
+        "docs/ should be pruned: {}",
+        output
+    );
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "-I".into(),
+        "*.rs".into(),
+        "--keep-empty-dirs".into(),
+    ]);
+    assert!(success);
+    assert!(output.contains("main.rs"));
+    assert!(output.contains("docs/"), "docs/ should be kept: {}", output);
+    assert!(
+        output.contains("api/"),
+        "docs/api/ should be kept: {}",
+        output
+    );
+    assert!(!output.contains("guide.md"));
+}
+
+#[test]
+fn test_include_ext_expands_aliases() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("src/app.ts", "export {}")
+        .file("src/view.tsx", "export {}")
+        .file("src/legacy.js", "module.exports = {}")
+        .file("README.md", "# Readme")
+        .build();
+
+    let (output, _, success) = run_tree2md([p(&root), "--include-ext".into(), "ts".into()]);
+    assert!(success);
+    assert!(output.contains("app.ts"), "{}", output);
+    assert!(output.contains("view.tsx"), "ts should also match .tsx");
+    assert!(!output.contains("legacy.js"));
+    assert!(!output.contains("README.md"));
+
+    // A user alias replaces the built-in family
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "--include-ext".into(),
+        "ts".into(),
+        "--ext-alias".into(),
+        "ts=ts".into(),
+    ]);
+    assert!(success);
+    assert!(output.contains("app.ts"));
+    assert!(!output.contains("view.tsx"), "{}", output);
+}
+
+#[test]
+fn test_include_ext_wildcard_with_negation() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("src/main.go", "package main")
+        .file("src/app.ts", "export {}")
+        .file("docs/guide.md", "# Guide")
+        .file("README.md", "# Readme")
+        .file("Makefile", "all:")
+        .build();
+
+    // Everything except Markdown, including files without an extension
+    let (output, _, success) = run_tree2md([p(&root), "--include-ext".into(), "*,!.md".into()]);
+    assert!(success);
+    assert!(output.contains("main.go"), "{}", output);
+    assert!(output.contains("app.ts"), "{}", output);
+    assert!(output.contains("Makefile"), "{}", output);
+    assert!(!output.contains("guide.md"), "{}", output);
+    assert!(!output.contains("README.md"), "{}", output);
+
+    // Mixed allow/deny: the negation wins over the allowed entry
+    let (output, _, success) = run_tree2md([p(&root), "--include-ext".into(), "go,md,!md".into()]);
+    assert!(success);
+    assert!(output.contains("main.go"), "{}", output);
+    assert!(!output.contains("app.ts"), "{}", output);
+    assert!(!output.contains("Makefile"), "{}", output);
+    assert!(!output.contains(".md"), "{}", output);
+
+    // Negating everything that was listed is an error, not "allow all"
+    let (output, stderr, success) =
+        run_tree2md([p(&root), "--include-ext".into(), "go,!go".into()]);
+    assert!(!success, "{}", output);
+    assert!(stderr.contains("excludes every extension"), "{}", stderr);
+}
+
+#[test]
+fn test_vcs_metadata_skipped_unless_include_vcs() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file(".git/HEAD", "ref: refs/heads/main\n")
+        .file(".git/objects/ab/cdef", "blob")
+        .file("lib/.hg/store", "hg")
+        .file("lib/.svn/entries", "svn")
+        .file("main.rs", "fn main() {}\n")
+        .build();
+
+    let (output, _, success) = run_tree2md([p(&root), "--stats".into(), "off".into()]);
+    assert!(success);
+    assert!(output.contains("main.rs"), "{}", output);
+    for hidden in [".git/", ".hg/", ".svn/", "HEAD", "cdef"] {
+        assert!(!output.contains(hidden), "{} shown: {}", hidden, output);
+    }
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "--stats".into(),
+        "off".into(),
+        "--include-vcs".into(),
+    ]);
+    assert!(success);
+    for shown in [".git/", "HEAD", "cdef", ".hg/", ".svn/"] {
+        assert!(output.contains(shown), "{} missing: {}", shown, output);
+    }
+}
+
+#[cfg(unix)]
+#[test]
+fn test_strict_exits_nonzero_on_unreadable_directory() {
+    use std::os::unix::fs::PermissionsExt;
+
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("visible.txt", "ok")
+        .file("locked/inside.txt", "hidden")
+        .build();
+    let locked = root.join("locked");
+    std::fs::set_permissions(&locked, std::fs::Permissions::from_mode(0o000)).unwrap();
+    // Permission bits do not apply to root; nothing to test there
+    let readable = std::fs::read_dir(&locked).is_ok();
+    if readable {
+        std::fs::set_permissions(&locked, std::fs::Permissions::from_mode(0o755)).unwrap();
+        return;
+    }
+
+    let (output, stderr, success) = run_tree2md([p(&root), "--stats".into(), "off".into()]);
+    assert!(success, "lenient by default: {}", stderr);
+    assert!(output.contains("visible.txt"), "{}", output);
+
+    let (output, stderr, success) =
+        run_tree2md([p(&root), "--strict".into(), "--stats".into(), "off".into()]);
+    // The partial tree is still printed before the failing exit
+    assert!(!success);
+    assert!(output.contains("visible.txt"), "{}", output);
+    assert!(stderr.contains("could not be read"), "{}", stderr);
+
+    // Let the temp dir clean up
+    std::fs::set_permissions(&locked, std::fs::Permissions::from_mode(0o755)).unwrap();
+}
@@ -9,8 +9,29 @@ pub fn run_tree2md<I, S>(args: I) -> (String, String, bool)
 where
     I: IntoIterator<Item = S>,
     S: AsRef<std::ffi::OsStr>,
+{
+    run_tree2md_with_env(args, std::iter::empty::<(&str, &str)>())
+}
+
+/// Run tree2md with given arguments and environment variables.
+/// TREE2MD_* variables inherited from the test environment are cleared first.
+pub fn run_tree2md_with_env<I, S, E, K, V>(args: I, envs: E) -> (String, String, bool)
+where
+    I: IntoIterator<Item = S>,
+    S: AsRef<std::ffi::OsStr>,
+    E: IntoIterator<Item = (K, V)>,
+    K: AsRef<std::ffi::OsStr>,
+    V: AsRef<std::ffi::OsStr>,
 {
     let mut cmd = Command::cargo_bin("tree2md").expect("tree2md binary not found");
+    for (key, _) in std::env::vars_os() {
+        if key.to_string_lossy().starts_with("TREE2MD_") {
+            cmd.env_remove(key);
+        }
+    }
+    for (key, value) in envs {
+        cmd.env(key, value);
+    }
     cmd.args(args);
 
     let Output {
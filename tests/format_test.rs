@@ -0,0 +1,429 @@
+mod fixtures;
+
+use fixtures::{p, run_tree2md, FixtureBuilder};
+
+#[test]
+fn test_format_toml_round_trip() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("src/main.rs", "fn main() {}\n")
+        .file("README.md", "# Title\n")
+        .build();
+
+    let (output, _, success) = run_tree2md([p(&root), "--format".into(), "toml".into()]);
+    assert!(success);
+
+    let parsed: toml::Table = toml::from_str(&output).expect("output should be valid TOML");
+    let files = parsed["file"].as_array().expect("[[file]] array");
+
+    let main = files
+        .iter()
+        .find(|f| f["path"].as_str() == Some("src/main.rs"))
+        .expect("src/main.rs entry");
+    assert_eq!(main["type"].as_str(), Some("file"));
+    assert_eq!(main["size"].as_integer(), Some(13));
+    assert_eq!(main["lang"].as_str(), Some("rust"));
+
+    let src = files
+        .iter()
+        .find(|f| f["path"].as_str() == Some("src"))
+        .expect("src entry");
+    assert_eq!(src["type"].as_str(), Some("dir"));
+    assert!(src.get("size").is_none());
+}
+
+fn find_node<'a>(node: &'a serde_json::Value, path: &str) -> Option<&'a serde_json::Value> {
+    if node["path"].as_str() == Some(path) {
+        return Some(node);
+    }
+    node["children"]
+        .as_array()?
+        .iter()
+        .find_map(|child| find_node(child, path))
+}
+
+#[test]
+fn test_format_json_truncation_metadata() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("long.txt", "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n")
+        .file("short.txt", "a\n")
+        .build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "--format".into(),
+        "json".into(),
+        "-c".into(),
+        "--max-chars".into(),
+        "8".into(),
+    ]);
+    assert!(success);
+
+    let tree: serde_json::Value = serde_json::from_str(&output).expect("valid JSON");
+
+    let long = find_node(&tree, "long.txt").expect("long.txt node");
+    let truncation = &long["truncation"];
+    assert_eq!(truncation["truncated"].as_bool(), Some(true));
+    assert_eq!(truncation["type"].as_str(), Some("head"));
+    assert_eq!(truncation["totalLines"].as_u64(), Some(10));
+    assert_eq!(truncation["shownLines"].as_u64(), Some(3));
+    assert_eq!(truncation["totalBytes"].as_u64(), Some(21));
+    assert_eq!(long["content"].as_str(), Some("1\n2\n3"));
+
+    let short = find_node(&tree, "short.txt").expect("short.txt node");
+    assert_eq!(short["truncation"]["truncated"].as_bool(), Some(false));
+    assert_eq!(short["content"].as_str(), Some("a\n"));
+}
+
+#[test]
+fn test_format_json_notes_why_contents_are_omitted() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("a.txt", &"word ".repeat(200))
+        .file("b.txt", &"word ".repeat(200))
+        .build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "--format".into(),
+        "json".into(),
+        "-c".into(),
+        "--max-tokens".into(),
+        "300".into(),
+    ]);
+    assert!(success);
+
+    let tree: serde_json::Value = serde_json::from_str(&output).expect("valid JSON");
+
+    let a = find_node(&tree, "a.txt").expect("a.txt node");
+    assert!(a["content"].is_string());
+    assert!(a.get("omitted").is_none());
+    let b = find_node(&tree, "b.txt").expect("b.txt node");
+    assert!(b["content"].is_null());
+    assert_eq!(b["omitted"].as_str(), Some("over the --max-tokens budget"));
+}
+
+#[test]
+fn test_compact_json_omits_empty_fields() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("src/main.rs", "fn main() {}\n")
+        .file("data.unknownext", "?\n")
+        .build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "--format".into(),
+        "json".into(),
+        "--stats".into(),
+        "off".into(),
+        "--compact-json".into(),
+    ]);
+    assert!(success);
+
+    let tree: serde_json::Value = serde_json::from_str(&output).expect("valid JSON");
+
+    let src = find_node(&tree, "src").expect("src node");
+    let src = src.as_object().unwrap();
+    assert!(src.contains_key("children"));
+    for key in ["content", "language", "lines", "size"] {
+        assert!(!src.contains_key(key), "dir has {}: {:?}", key, src);
+    }
+
+    let main = find_node(&tree, "src/main.rs").expect("src/main.rs node");
+    let main = main.as_object().unwrap();
+    assert!(!main.contains_key("children"), "{:?}", main);
+    // No -c, so no content
+    assert!(!main.contains_key("content"), "{:?}", main);
+    assert_eq!(main.get("language").and_then(|v| v.as_str()), Some("rust"));
+    assert_eq!(main.get("size").and_then(|v| v.as_u64()), Some(13));
+
+    let data = find_node(&tree, "data.unknownext").expect("data node");
+    assert!(data.get("language").is_none(), "{:?}", data);
+}
+
+#[test]
+fn test_format_json_without_limits_has_no_truncation() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("src/main.rs", "fn main() {}\n")
+        .build();
+
+    let (output, _, success) =
+        run_tree2md([p(&root), "--format".into(), "json".into(), "-c".into()]);
+    assert!(success);
+
+    let tree: serde_json::Value = serde_json::from_str(&output).expect("valid JSON");
+    assert_eq!(tree["type"].as_str(), Some("dir"));
+
+    let src = find_node(&tree, "src").expect("src node");
+    assert_eq!(src["type"].as_str(), Some("dir"));
+
+    let main = find_node(&tree, "src/main.rs").expect("src/main.rs node");
+    assert_eq!(main["type"].as_str(), Some("file"));
+    assert_eq!(main["language"].as_str(), Some("rust"));
+    assert_eq!(main["content"].as_str(), Some("fn main() {}\n"));
+    assert!(main.get("truncation").is_none());
+}
+
+/// A JSON tree fed back through --from-json renders the same Markdown as
+/// the original scan, without touching the files it describes.
+#[test]
+fn test_from_json_round_trips_to_same_markdown() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("src/main.rs", "fn main() {}\n")
+        .file("src/lib/util.rs", "pub fn util() {}\n")
+        .file("README.md", "# Title\n")
+        .build();
+
+    let (markdown, stderr, success) = run_tree2md([p(&root), "-c".into()]);
+    assert!(success, "{}", stderr);
+    let (json, stderr, success) =
+        run_tree2md([p(&root), "--format".into(), "json".into(), "-c".into()]);
+    assert!(success, "{}", stderr);
+
+    // Replace the sources with the JSON alone
+    std::fs::remove_dir_all(root.join("src")).unwrap();
+    std::fs::remove_file(root.join("README.md")).unwrap();
+    let tree_path = root.join("tree.json");
+    std::fs::write(&tree_path, json).unwrap();
+
+    let (replayed, stderr, success) =
+        run_tree2md(["--from-json".into(), p(&tree_path), "-c".into()]);
+    assert!(success, "{}", stderr);
+    assert_eq!(replayed, markdown);
+
+    // A .tree2mdignore in TARGET does not apply to a JSON tree
+    std::fs::write(root.join(".tree2mdignore"), "!content:*.rs\n").unwrap();
+    let (replayed, stderr, success) =
+        run_tree2md([p(&root), "--from-json".into(), p(&tree_path), "-c".into()]);
+    assert!(success, "{}", stderr);
+    assert_eq!(replayed, markdown);
+
+    // Options that read the files themselves are rejected
+    let (_, stderr, success) = run_tree2md([
+        "--from-json".into(),
+        p(&tree_path),
+        "--duplicates-report".into(),
+    ]);
+    assert!(!success);
+    assert!(stderr.contains("cannot be used with"), "{}", stderr);
+    let (_, stderr, success) = run_tree2md([
+        "--from-json".into(),
+        p(&tree_path),
+        "-X".into(),
+        "*.rs".into(),
+    ]);
+    assert!(!success);
+    assert!(stderr.contains("cannot be used with"), "{}", stderr);
+
+    // Paths that would escape --export-dir are refused
+    let escaping = root.join("escaping.json");
+    std::fs::write(
+        &escaping,
+        r#"{"name": ".", "path": ".", "type": "dir", "children": [
+            {"name": "x", "path": "../../x", "type": "file", "size": 1, "content": "x"}
+        ]}"#,
+    )
+    .unwrap();
+    let export_dir = root.join("export");
+    let (_, stderr, success) = run_tree2md([
+        "--from-json".into(),
+        p(&escaping),
+        "-c".into(),
+        "--export-dir".into(),
+        p(&export_dir),
+    ]);
+    assert!(!success);
+    assert!(stderr.contains("must be relative"), "{}", stderr);
+}
+
+#[test]
+fn test_format_json_stats_object() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("src/main.rs", "fn main() {\n    run();\n}\n")
+        .file("src/lib.rs", "pub fn run() {}\n")
+        .file("README.md", "# Demo\n")
+        .build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "--format".into(),
+        "json".into(),
+        "--stats".into(),
+        "min".into(),
+        "--loc".into(),
+        "fast".into(),
+    ]);
+    assert!(success);
+
+    let tree: serde_json::Value = serde_json::from_str(&output).expect("valid JSON");
+    let stats = &tree["stats"];
+    assert_eq!(stats["directories"].as_u64(), Some(2));
+    assert_eq!(stats["files"].as_u64(), Some(3));
+    assert_eq!(stats["totalSize"].as_u64(), Some(48));
+    assert_eq!(stats["lines"].as_u64(), Some(5));
+
+    let rust = &stats["languages"]["Rust"];
+    assert_eq!(rust["files"].as_u64(), Some(2));
+    assert_eq!(rust["lines"].as_u64(), Some(4));
+    assert_eq!(stats["languages"]["Markdown"]["files"].as_u64(), Some(1));
+
+    // No stats object with --stats off
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "--format".into(),
+        "json".into(),
+        "--stats".into(),
+        "off".into(),
+    ]);
+    assert!(success);
+    let tree: serde_json::Value = serde_json::from_str(&output).expect("valid JSON");
+    assert!(tree.get("stats").is_none());
+}
+
+/// Minimal XML element tree, enough to check the --format xml structure
+#[derive(Debug, Default)]
+struct XmlElement {
+    name: String,
+    attrs: std::collections::HashMap<String, String>,
+    children: Vec<XmlElement>,
+    text: String,
+}
+
+fn unescape_xml(s: &str) -> String {
+    s.replace("&lt;", "<")
+        .replace("&gt;", ">")
+        .replace("&quot;", "\"")
+        .replace("&apos;", "'")
+        .replace("&#10;", "\n")
+        .replace("&#9;", "\t")
+        .replace("&amp;", "&")
+}
+
+/// Parse one element starting at `input[*pos]`, panicking on malformed XML
+fn parse_xml_element(input: &str, pos: &mut usize) -> XmlElement {
+    let rest = &input[*pos..];
+    assert!(rest.starts_with('<'), "expected element at: {}", rest);
+    let tag_end = rest.find('>').expect("unterminated tag");
+    let self_closing = rest[..tag_end].ends_with('/');
+    let tag = rest[1..tag_end].trim_end_matches('/');
+    *pos += tag_end + 1;
+
+    let mut element = XmlElement::default();
+    let (name, mut attrs) = tag.split_once(' ').unwrap_or((tag, ""));
+    element.name = name.to_string();
+    while let Some(eq) = attrs.find("=\"") {
+        let key = attrs[..eq].trim().to_string();
+        let value_end = attrs[eq + 2..].find('"').expect("unterminated attribute");
+        let value = unescape_xml(&attrs[eq + 2..eq + 2 + value_end]);
+        element.attrs.insert(key, value);
+        attrs = &attrs[eq + 2 + value_end + 1..];
+    }
+    if self_closing {
+        return element;
+    }
+
+    let close = format!("</{}>", element.name);
+    loop {
+        let rest = &input[*pos..];
+        if rest.starts_with(&close) {
+            *pos += close.len();
+            return element;
+        } else if let Some(cdata) = rest.strip_prefix("<![CDATA[") {
+            let end = cdata.find("]]>").expect("unterminated CDATA");
+            element.text.push_str(&cdata[..end]);
+            *pos += "<![CDATA[".len() + end + 3;
+        } else if rest.starts_with('<') {
+            element.children.push(parse_xml_element(input, pos));
+        } else {
+            let next = rest.find('<').expect("unterminated element");
+            assert!(rest[..next].trim().is_empty(), "unexpected text");
+            *pos += next;
+        }
+    }
+}
+
+fn parse_xml(input: &str) -> XmlElement {
+    let body = input
+        .strip_prefix("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+        .expect("XML declaration");
+    let mut pos = 0;
+    let root = parse_xml_element(body, &mut pos);
+    assert!(body[pos..].trim().is_empty(), "trailing data after root");
+    root
+}
+
+#[test]
+fn test_format_xml_nested_elements_with_cdata() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file(
+            "src/main.rs",
+            "fn main() { let a = [[1]]; let b = a[0]; }\n",
+        )
+        .file("src/tricky.md", "ends ]]> early & <b>\n")
+        .file("notes.txt", "plain\n")
+        .build();
+
+    let (output, _, success) =
+        run_tree2md([p(&root), "--format".into(), "xml".into(), "-c".into()]);
+    assert!(success);
+
+    let tree = parse_xml(&output);
+    assert_eq!(tree.name, "directory");
+    assert_eq!(tree.attrs["name"], ".");
+
+    let src = tree
+        .children
+        .iter()
+        .find(|c| c.name == "directory" && c.attrs["name"] == "src")
+        .expect("src directory");
+    let main = src
+        .children
+        .iter()
+        .find(|c| c.attrs["name"] == "main.rs")
+        .expect("main.rs file");
+    assert_eq!(main.name, "file");
+    assert_eq!(main.attrs["path"], "src/main.rs");
+    assert_eq!(main.attrs["lang"], "rust");
+    assert_eq!(main.attrs["size"], "43");
+    assert_eq!(main.text, "fn main() { let a = [[1]]; let b = a[0]; }\n");
+
+    // A literal "]]>" is split across CDATA sections and survives intact
+    let tricky = src
+        .children
+        .iter()
+        .find(|c| c.attrs["name"] == "tricky.md")
+        .expect("tricky.md file");
+    assert_eq!(tricky.text, "ends ]]> early & <b>\n");
+
+    let notes = tree
+        .children
+        .iter()
+        .find(|c| c.attrs["name"] == "notes.txt")
+        .expect("notes.txt file");
+    assert!(!notes.attrs.contains_key("lang"));
+    assert_eq!(notes.text, "plain\n");
+}
+
+#[test]
+fn test_format_xml_notes_why_contents_are_omitted() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("logo.png", "not really a png")
+        .file("main.rs", "fn main() {}\n")
+        .build();
+
+    let (output, _, success) =
+        run_tree2md([p(&root), "--format".into(), "xml".into(), "-c".into()]);
+    assert!(success);
+
+    let tree = parse_xml(&output);
+    let logo = tree
+        .children
+        .iter()
+        .find(|c| c.attrs["name"] == "logo.png")
+        .expect("logo.png file");
+    assert_eq!(logo.attrs["omitted"], "binary file");
+    let main = tree
+        .children
+        .iter()
+        .find(|c| c.attrs["name"] == "main.rs")
+        .expect("main.rs file");
+    assert!(!main.attrs.contains_key("omitted"));
+}
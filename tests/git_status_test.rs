@@ -0,0 +1,262 @@
+mod fixtures;
+
+use fixtures::{p, run_tree2md, FixtureBuilder};
+use std::fs;
+use std::path::Path;
+use std::process::Command;
+
+/// Run git in `dir`, returning false if git is unavailable or fails
+fn git(dir: &Path, args: &[&str]) -> bool {
+    Command::new("git")
+        .arg("-C")
+        .arg(dir)
+        .args(["-c", "user.name=test", "-c", "user.email=test@example.com"])
+        .args(args)
+        .output()
+        .map(|o| o.status.success())
+        .unwrap_or(false)
+}
+
+#[test]
+fn test_git_status_marks_changed_entries() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("tracked.txt", "one\n")
+        .file("clean.txt", "same\n")
+        .build();
+
+    if !git(&root, &["init", "-q"])
+        || !git(&root, &["add", "."])
+        || !git(&root, &["commit", "-q", "-m", "init"])
+    {
+        eprintln!("git unavailable, skipping");
+        return;
+    }
+    fs::write(root.join("tracked.txt"), "two\n").unwrap();
+    fs::write(root.join("staged.txt"), "new\n").unwrap();
+    assert!(git(&root, &["add", "staged.txt"]));
+    fs::create_dir(root.join("scratch")).unwrap();
+    fs::write(root.join("scratch/notes.txt"), "todo\n").unwrap();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "--stats".into(),
+        "off".into(),
+        "--git-status".into(),
+    ]);
+    assert!(success);
+
+    assert!(output.contains("[M] tracked.txt"), "{}", output);
+    assert!(output.contains("[A] staged.txt"), "{}", output);
+    assert!(output.contains("[?] scratch/"), "{}", output);
+    assert!(output.contains("[?] notes.txt"), "{}", output);
+    assert!(output.contains("── clean.txt"), "{}", output);
+}
+
+#[test]
+fn test_git_status_outside_repo_is_skipped() {
+    let (_tmp, root) = FixtureBuilder::new().file("a.txt", "a\n").build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "--stats".into(),
+        "off".into(),
+        "--git-status".into(),
+    ]);
+    assert!(success);
+    assert!(output.contains("── a.txt"), "{}", output);
+    assert!(!output.contains('['), "{}", output);
+}
+
+#[test]
+fn test_changed_in_limits_tree_to_range() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("src/keep.rs", "fn keep() {}\n")
+        .file("src/edit.rs", "fn edit() {}\n")
+        .file("old_name.txt", "rename me\n")
+        .file("gone.txt", "delete me\n")
+        .build();
+
+    if !git(&root, &["init", "-q"])
+        || !git(&root, &["add", "."])
+        || !git(&root, &["commit", "-q", "-m", "base"])
+        || !git(&root, &["tag", "base"])
+    {
+        eprintln!("git unavailable, skipping");
+        return;
+    }
+    fs::write(root.join("src/edit.rs"), "fn edit() { changed() }\n").unwrap();
+    fs::create_dir(root.join("docs")).unwrap();
+    fs::write(root.join("docs/added.md"), "# New\n").unwrap();
+    assert!(git(&root, &["mv", "old_name.txt", "new_name.txt"]));
+    assert!(git(&root, &["rm", "-q", "gone.txt"]));
+    assert!(git(&root, &["add", "."]));
+    assert!(git(&root, &["commit", "-q", "-m", "change"]));
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "--stats".into(),
+        "off".into(),
+        "-c".into(),
+        "--changed-in".into(),
+        "base..HEAD".into(),
+    ]);
+    assert!(success);
+
+    assert!(output.contains("edit.rs"), "{}", output);
+    assert!(output.contains("fn edit() { changed() }"), "{}", output);
+    assert!(output.contains("added.md"), "{}", output);
+    assert!(output.contains("new_name.txt"), "{}", output);
+    assert!(!output.contains("keep.rs"), "{}", output);
+    assert!(!output.contains("old_name.txt"), "{}", output);
+    assert!(!output.contains("gone.txt"), "{}", output);
+}
+
+#[test]
+fn test_changed_in_outside_repo_is_noop() {
+    let (_tmp, root) = FixtureBuilder::new().file("a.txt", "a\n").build();
+
+    let (output, stderr, success) = run_tree2md([
+        p(&root),
+        "--stats".into(),
+        "off".into(),
+        "--changed-in".into(),
+        "main..HEAD".into(),
+    ]);
+    assert!(success);
+    assert!(output.contains("a.txt"), "{}", output);
+    assert!(stderr.contains("--changed-in ignored"), "{}", stderr);
+}
+
+#[test]
+fn test_git_blame_prefixes_content_lines() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("main.rs", "fn main() {\n}\n")
+        .build();
+
+    if !git(&root, &["init", "-q"])
+        || !git(&root, &["add", "."])
+        || !git(&root, &["commit", "-q", "-m", "init"])
+    {
+        eprintln!("git unavailable, skipping");
+        return;
+    }
+    fs::write(root.join("untracked.rs"), "fn other() {}\n").unwrap();
+    let head = Command::new("git")
+        .arg("-C")
+        .arg(&root)
+        .args(["rev-parse", "--short=7", "HEAD"])
+        .output()
+        .unwrap();
+    let head = String::from_utf8(head.stdout).unwrap().trim().to_string();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--stats".into(),
+        "off".into(),
+        "--git-blame".into(),
+    ]);
+    assert!(success);
+
+    assert!(
+        output.contains(&format!(
+            "```rust\n{head} test | fn main() {{\n{head} test | }}\n```"
+        )),
+        "{}",
+        output
+    );
+    // Untracked files are emitted unannotated
+    assert!(output.contains("```rust\nfn other() {}\n```"), "{}", output);
+}
+
+#[test]
+fn test_git_blame_annotates_lines_left_after_cutting() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file(
+            "main.rs",
+            "\n\nfn main() {\n    let a = 1;\n    let b = 2;\n}\n",
+        )
+        .build();
+
+    if !git(&root, &["init", "-q"])
+        || !git(&root, &["add", "."])
+        || !git(&root, &["commit", "-q", "-m", "init"])
+    {
+        eprintln!("git unavailable, skipping");
+        return;
+    }
+    let head = Command::new("git")
+        .arg("-C")
+        .arg(&root)
+        .args(["rev-parse", "--short=7", "HEAD"])
+        .output()
+        .unwrap();
+    let head = String::from_utf8(head.stdout).unwrap().trim().to_string();
+
+    let (output, stderr, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--stats".into(),
+        "off".into(),
+        "--git-blame".into(),
+        "--contents-mode".into(),
+        "nest".into(),
+        "--max-chars".into(),
+        "120".into(),
+        "--trim-blank-lines".into(),
+    ]);
+    assert!(success, "{}", stderr);
+
+    // Nest collapses the indented lines, which the blame prefix no longer
+    // hides, and the marker gets a blank prefix
+    assert!(
+        output.contains(&format!(
+            "```rust\n{head} test | fn main() {{\n{blank}| ... (2 lines)\n{head} test | }}\n... (2 lines omitted)\n```",
+            blank = " ".repeat(13)
+        )),
+        "{}",
+        output
+    );
+}
+
+#[test]
+fn test_git_blame_skipped_when_lines_are_rewritten() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("data.json", "{\"a\": 1, \"b\": [1, 2]}\n")
+        .file("main.rs", "fn main() {\n}\n")
+        .build();
+
+    if !git(&root, &["init", "-q"])
+        || !git(&root, &["add", "."])
+        || !git(&root, &["commit", "-q", "-m", "init"])
+    {
+        eprintln!("git unavailable, skipping");
+        return;
+    }
+
+    let (output, stderr, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--stats".into(),
+        "off".into(),
+        "--git-blame".into(),
+        "--pretty-json".into(),
+        "--max-chars".into(),
+        "100000".into(),
+    ]);
+    assert!(success, "{}", stderr);
+
+    // Pretty-printed JSON no longer lines up with the blame
+    assert!(!output.contains("test | {"), "{}", output);
+    assert!(output.contains("```json\n{\n"), "{}", output);
+    assert_eq!(
+        stderr
+            .matches("--git-blame skipped for 'data.json'")
+            .count(),
+        1,
+        "{}",
+        stderr
+    );
+    // Other files are still annotated
+    assert!(output.contains(" test | fn main() {"), "{}", output);
+}
@@ -67,6 +67,34 @@ fn test_deeply_nested_gitignore() {
     assert!(output.contains("keep.txt"));
 }
 
+/// A nested negation re-includes a file its parent .gitignore ignored,
+/// within the nested file's directory only; a deeper .gitignore can
+/// ignore it again, as git does.
+#[test]
+fn test_nested_negation_reincludes_parent_ignore() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .dir(".git")
+        .file(".gitignore", "*.draft\n")
+        .file("debug.draft", "root")
+        .file("sub/.gitignore", "!debug.draft\n")
+        .file("sub/debug.draft", "sub")
+        .file("sub/other.draft", "other")
+        .file("sub/inner/debug.draft", "inner")
+        .file("sub/deeper/.gitignore", "debug.draft\n")
+        .file("sub/deeper/debug.draft", "deeper")
+        .build();
+
+    // Content headings carry the full path of every listed file
+    let (output, _, success) = run_tree2md([p(&root), "-c".into(), "--stats".into(), "off".into()]);
+    assert!(success);
+
+    assert!(output.contains("## sub/debug.draft\n"), "{}", output);
+    assert!(output.contains("## sub/inner/debug.draft\n"), "{}", output);
+    assert!(!output.contains("## debug.draft\n"), "{}", output);
+    assert!(!output.contains("other.draft"), "{}", output);
+    assert!(!output.contains("## sub/deeper/debug.draft"), "{}", output);
+}
+
 /// Auto-detection should find .git in ancestor directories.
 /// Running tree2md on a subdirectory of a git repo should still respect gitignore.
 #[test]
@@ -147,3 +175,248 @@ fn test_never_mode_ignores_nested_gitignore() {
     );
     assert!(output.contains("file.txt"));
 }
+
+#[test]
+fn test_respect_npmignore_falls_back_to_gitignore() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file(".gitignore", "*.log\n")
+        .file("index.js", "module.exports = {}")
+        .file("debug.log", "log")
+        .build();
+
+    // Without .npmignore, npm uses .gitignore
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "--use-gitignore".into(),
+        "never".into(),
+        "--respect-npmignore".into(),
+    ]);
+    assert!(success);
+    assert!(output.contains("index.js"));
+    assert!(
+        !output.contains("debug.log"),
+        ".gitignore should apply as fallback: {}",
+        output
+    );
+}
+
+#[test]
+fn test_respect_npmignore_replaces_gitignore_per_directory() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file(".gitignore", "docs/\n")
+        .file(".npmignore", "test/\n")
+        .file("docs/guide.md", "guide")
+        .file("test/index.test.js", "test")
+        .file("lib/.gitignore", "*.tmp\n")
+        .file("lib/main.js", "main")
+        .file("lib/cache.tmp", "tmp")
+        .build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "--use-gitignore".into(),
+        "never".into(),
+        "--respect-npmignore".into(),
+    ]);
+    assert!(success);
+
+    // Root .npmignore wins over root .gitignore: docs/ is published, test/ is not
+    assert!(
+        output.contains("guide.md"),
+        "docs/ should be kept: {}",
+        output
+    );
+    assert!(!output.contains("index.test.js"), "test/ should be ignored");
+
+    // lib/ has no .npmignore, so its .gitignore applies
+    assert!(output.contains("main.js"));
+    assert!(!output.contains("cache.tmp"), "lib/.gitignore should apply");
+}
+
+/// A directory with a .npmignore is filtered by it alone, even when
+/// .gitignore is respected too: npm publishes what .gitignore hides.
+#[test]
+fn test_respect_npmignore_does_not_stack_on_gitignore() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file(".gitignore", "dist/\n")
+        .file(".npmignore", "test/\n")
+        .file("dist/index.js", "bundle")
+        .file("test/index.test.js", "test")
+        .file("src/index.js", "src")
+        .build();
+
+    let (output, stderr, success) = run_tree2md([
+        p(&root),
+        "--use-gitignore".into(),
+        "always".into(),
+        "--respect-npmignore".into(),
+        // The safety preset hides dist/ on its own
+        "--unsafe".into(),
+    ]);
+    assert!(success, "{}", stderr);
+
+    assert!(
+        output.contains("dist/"),
+        "dist/ should be listed: {}",
+        output
+    );
+    assert!(output.contains("src/"), "{}", output);
+    assert!(!output.contains("index.test.js"), "{}", output);
+}
+
+#[test]
+fn test_gitignore_debug_reports_deciding_pattern() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file(".gitignore", "*.out\n!keep.out\nbuild-cache/\n")
+        .file("app.out", "log")
+        .file("keep.out", "log")
+        .file("build-cache/data.bin", "data")
+        .file("main.rs", "fn main() {}")
+        .build();
+
+    let (output, stderr, success) = run_tree2md([
+        p(&root),
+        "--use-gitignore".into(),
+        "always".into(),
+        "--gitignore-debug".into(),
+    ]);
+    assert!(success);
+    assert!(!output.contains("app.out"));
+    assert!(output.contains("keep.out"));
+
+    assert!(
+        stderr.contains("gitignore-debug: excluded 'app.out' (pattern '*.out' in "),
+        "Excluded file should name its pattern: {}",
+        stderr
+    );
+    assert!(
+        stderr.contains("gitignore-debug: excluded 'build-cache' (pattern 'build-cache/' in "),
+        "{}",
+        stderr
+    );
+    assert!(
+        stderr.contains("gitignore-debug: kept 'keep.out' (negated pattern '!keep.out' in "),
+        "Negation should be reported: {}",
+        stderr
+    );
+    assert!(stderr.contains(".gitignore)"), "{}", stderr);
+    assert!(!stderr.contains("main.rs"), "{}", stderr);
+
+    // Nothing is logged without the flag
+    let (_, stderr, success) = run_tree2md([p(&root), "--use-gitignore".into(), "always".into()]);
+    assert!(success);
+    assert!(!stderr.contains("gitignore-debug"));
+}
+
+/// Paths listed by `--format toml`, which prints one `path = "..."` per entry
+fn listed_paths(root: &std::path::Path) -> Vec<String> {
+    let (output, _, success) = run_tree2md([
+        p(root),
+        "--use-gitignore".into(),
+        "always".into(),
+        "--format".into(),
+        "toml".into(),
+    ]);
+    assert!(success);
+    output
+        .lines()
+        .filter_map(|l| l.strip_prefix("path = \""))
+        .map(|l| l.trim_end_matches('"').to_string())
+        .collect()
+}
+
+/// Patterns whose meaning often trips up hand-written matchers, checked
+/// against what `git check-ignore` reports for the same layout.
+#[test]
+fn test_gitignore_matches_git_semantics_for_tricky_patterns() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file(
+            ".gitignore",
+            "/anchored.txt\n\
+             docs/*.md\n\
+             **/tmpdir\n\
+             a/**/b.txt\n\
+             \\#hash.txt\n\
+             out/\n\
+             cache/\n\
+             !cache/keep.txt\n",
+        )
+        .file("anchored.txt", "")
+        .file("sub/anchored.txt", "")
+        .file("docs/guide.md", "")
+        .file("docs/deep/guide.md", "")
+        .file("sub/docs/guide.md", "")
+        .file("x/y/tmpdir/file.txt", "")
+        .file("a/b.txt", "")
+        .file("a/x/y/b.txt", "")
+        .file("a/x/c.txt", "")
+        .file("#hash.txt", "")
+        .file("out/file.txt", "")
+        .file("sub/out", "")
+        .file("cache/keep.txt", "")
+        .build();
+
+    let paths = listed_paths(&root);
+    let listed = |path: &str| paths.iter().any(|p| p == path);
+
+    // A leading slash anchors to the .gitignore's directory
+    assert!(!listed("anchored.txt"), "{:?}", paths);
+    assert!(listed("sub/anchored.txt"), "{:?}", paths);
+
+    // A slash in the middle also anchors, and `*` does not cross directories
+    assert!(!listed("docs/guide.md"), "{:?}", paths);
+    assert!(listed("docs/deep/guide.md"), "{:?}", paths);
+    assert!(listed("sub/docs/guide.md"), "{:?}", paths);
+
+    // Leading `**/` matches at any depth
+    assert!(!listed("x/y/tmpdir"), "{:?}", paths);
+
+    // `/**/` matches zero or more directories
+    assert!(!listed("a/b.txt"), "{:?}", paths);
+    assert!(!listed("a/x/y/b.txt"), "{:?}", paths);
+    assert!(listed("a/x/c.txt"), "{:?}", paths);
+
+    // An escaped `#` is a literal, not a comment
+    assert!(!listed("#hash.txt"), "{:?}", paths);
+
+    // A trailing slash only matches directories
+    assert!(!listed("out"), "{:?}", paths);
+    assert!(listed("sub/out"), "{:?}", paths);
+
+    // A file can't be re-included when its parent directory is excluded
+    assert!(!listed("cache/keep.txt"), "{:?}", paths);
+}
+
+/// Repeated --ignore flags are applied in order, so a later negation
+/// re-includes what an earlier pattern ignored, but not the other way round.
+#[test]
+fn test_ignore_flags_respect_negation_order() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("reports/keep.out", "keep")
+        .file("reports/drop.out", "drop")
+        .file("notes.txt", "notes")
+        .build();
+
+    let run = |args: &[&str]| {
+        let mut full = vec![p(&root), "--stats".into(), "off".into()];
+        full.extend(args.iter().map(|a| a.to_string()));
+        let (output, _, success) = run_tree2md(full);
+        assert!(success);
+        output
+    };
+
+    let output = run(&["--ignore", "reports/*", "--ignore", "!reports/keep.out"]);
+    assert!(output.contains("keep.out"), "{}", output);
+    assert!(!output.contains("drop.out"), "{}", output);
+    assert!(output.contains("notes.txt"), "{}", output);
+
+    // Reversed: the later ignore wins over the earlier negation
+    let output = run(&["--ignore", "!reports/keep.out", "--ignore", "reports/*"]);
+    assert!(!output.contains("keep.out"), "{}", output);
+    assert!(!output.contains("drop.out"), "{}", output);
+
+    // Comma-separated lists keep the same order
+    let output = run(&["--ignore", "reports/*,!reports/keep.out"]);
+    assert!(output.contains("keep.out"), "{}", output);
+    assert!(!output.contains("drop.out"), "{}", output);
+}
@@ -333,3 +333,55 @@ fn test_exclude_specific_extension_in_directory() {
         "main.py should not be included"
     );
 }
+
+#[test]
+fn test_filter_file_later_include_overrides_exclude() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file(
+            "filters.txt",
+            "# no text files but the important one\n- *.txt\n+ important.txt\n",
+        )
+        .file("main.go", "package main\n")
+        .file("docs/notes.txt", "notes\n")
+        .file("docs/important.txt", "important\n")
+        .build();
+
+    let (output, stderr, success) = run_tree2md([
+        p(&root),
+        "--filter-file".into(),
+        p(&root.join("filters.txt")),
+    ]);
+    assert!(success, "{}", stderr);
+
+    assert!(output.contains("important.txt"), "{}", output);
+    assert!(output.contains("main.go"), "{}", output);
+    assert!(!output.contains("notes.txt"), "{}", output);
+    // The filter file is itself a .txt file
+    assert!(!output.contains("filters.txt"), "{}", output);
+}
+
+#[test]
+fn test_filter_file_include_leaves_directories_to_gitignore_and_vcs() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("filters.txt", "+ build/\n+ .git/\n- docs/\n")
+        .file(".gitignore", "build/\n")
+        .file("build/out.rs", "fn out() {}\n")
+        .file(".git/config", "[core]\n")
+        .file("docs/guide.rs", "fn guide() {}\n")
+        .file("main.rs", "fn main() {}\n")
+        .build();
+
+    let (output, stderr, success) = run_tree2md([
+        p(&root),
+        "--filter-file".into(),
+        p(&root.join("filters.txt")),
+    ]);
+    assert!(success, "{}", stderr);
+
+    assert!(output.contains("main.rs"), "{}", output);
+    // `+` does not override .gitignore or VCS pruning
+    assert!(!output.contains("out.rs"), "{}", output);
+    assert!(!output.contains("config"), "{}", output);
+    // `-` still prunes
+    assert!(!output.contains("guide.rs"), "{}", output);
+}
@@ -56,6 +56,36 @@ fn test_include_extension_pattern() {
     );
 }
 
+#[test]
+fn test_include_extension_pattern_matches_dotfiles() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file(".eslintrc.json", "{}")
+        .file("config/.babelrc.json", "{}")
+        .file("package.json", "{}")
+        .file(".eslintrc", "root: true")
+        .file("main.js", "main")
+        .build();
+
+    // Dotfiles are listed by default, so extension patterns see them too
+    let (output, _, success) = run_tree2md([p(&root), "-I".into(), "*.json".into()]);
+    assert!(success);
+
+    let names: Vec<&str> = output
+        .lines()
+        .filter_map(|l| l.split("── ").nth(1))
+        .map(|n| n.split("  (").next().unwrap())
+        .collect();
+
+    // A dotfile without an extension is not a .json file, and its
+    // .json sibling must not be mistaken for it
+    assert_eq!(
+        names,
+        vec!["config/", ".babelrc.json", ".eslintrc.json", "package.json"],
+        "{}",
+        output
+    );
+}
+
 #[test]
 fn test_include_directory_name_pattern() {
     let (_tmp, root) = FixtureBuilder::new()
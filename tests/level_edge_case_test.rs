@@ -113,3 +113,68 @@ fn test_level_absolute_depth_enforcement() {
         "Should NOT show sub1.rs at depth 4"
     );
 }
+
+#[test]
+fn test_no_recurse_lists_single_level() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("root.txt", "root")
+        .file("src/main.rs", "fn main() {}")
+        .dir("empty")
+        .build();
+
+    for flag in ["--no-recurse", "-R"] {
+        let (output, _, success) = run_tree2md([p(&root), flag.into()]);
+        assert!(success);
+        assert!(output.contains("root.txt"), "{}: {}", flag, output);
+        assert!(output.contains("src/"), "{}: Should show src/", flag);
+        assert!(output.contains("empty/"), "{}: Should show empty/", flag);
+        assert!(
+            !output.contains("main.rs"),
+            "{}: Should NOT descend into src/",
+            flag
+        );
+    }
+}
+
+#[test]
+fn test_no_recurse_conflicts_with_level() {
+    let (_tmp, root) = FixtureBuilder::new().file("a.txt", "a").build();
+
+    let (_, _, success) = run_tree2md([p(&root), "-R".into(), "-L".into(), "2".into()]);
+    assert!(!success, "-R and -L should not be combined");
+}
+
+#[test]
+fn test_collapse_below_marks_hidden_entries() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("root.txt", "root")
+        .file("src/main.rs", "fn main() {}")
+        .file("src/deep/a.rs", "a")
+        .file("src/deep/more/b.rs", "b")
+        .file("src/deep/more/c.rs", "c")
+        .file("docs/guide.md", "guide")
+        .dir("src/empty")
+        .build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "--stats".into(),
+        "off".into(),
+        "--collapse-below".into(),
+        "2".into(),
+    ]);
+    assert!(success);
+
+    // Shallow files stay; each deep subtree becomes one marker with a count
+    assert!(output.contains("root.txt"), "{}", output);
+    assert!(output.contains("main.rs"), "{}", output);
+    assert!(output.contains("guide.md"), "{}", output);
+    assert!(output.contains("deep/ […] (4 hidden)\n"), "{}", output);
+    assert!(!output.contains("a.rs"), "{}", output);
+    assert!(!output.contains("more/"), "{}", output);
+    // Nothing is hidden under an empty directory
+    assert!(output.contains("empty/\n"), "{}", output);
+
+    let (_, _, success) = run_tree2md([p(&root), "--collapse-below".into(), "0".into()]);
+    assert!(!success, "--collapse-below must be at least 1");
+}
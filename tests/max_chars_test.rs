@@ -160,3 +160,80 @@ fn test_contents_mode_default_is_head() {
     // Head mode keeps from the beginning
     assert!(output.contains("line1"));
 }
+
+#[test]
+fn test_truncation_format_custom_template() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("a.txt", "one\ntwo\nthree\nfour\n")
+        .file("b.txt", "one\ntwo\nthree\nfour\n")
+        .build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--max-chars".into(),
+        "16".into(),
+        "--truncation-format".into(),
+        "[{type} {shownLines}/{totalLines} lines, {shownBytes}/{totalBytes} bytes]".into(),
+    ]);
+    assert!(success);
+
+    assert_eq!(
+        output.matches("[head 2/4 lines, 7/19 bytes]\n```").count(),
+        2,
+        "Custom truncation message expected for both files: {}",
+        output
+    );
+    assert!(!output.contains("lines omitted)"));
+}
+
+#[test]
+fn test_max_tokens_stops_emission_at_budget() {
+    // 39 bytes each: an estimated 10 tokens per file
+    let line = "0123456789012345678901234567890123456\n";
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("a.txt", &format!("a{}", line))
+        .file("b.txt", &format!("b{}", line))
+        .file("c.txt", &format!("c{}", line))
+        .file("d.txt", &format!("d{}", line))
+        .build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--stats".into(),
+        "off".into(),
+        "--max-tokens".into(),
+        "25".into(),
+    ]);
+    assert!(success);
+
+    assert!(output.contains("a0123"), "{}", output);
+    assert!(output.contains("b0123"), "{}", output);
+    assert!(!output.contains("c0123"), "{}", output);
+    assert!(!output.contains("d0123"), "{}", output);
+    assert!(
+        output
+            .contains("_2 file(s) omitted to stay within --max-tokens:_\n\n- `c.txt`\n- `d.txt`\n"),
+        "{}",
+        output
+    );
+}
+
+#[test]
+fn test_max_tokens_large_enough_emits_everything() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("a.txt", "alpha\n")
+        .file("b.txt", "beta\n")
+        .build();
+
+    let (output, _, success) =
+        run_tree2md([p(&root), "-c".into(), "--max-tokens".into(), "1000".into()]);
+    assert!(success);
+    assert!(
+        output.contains("alpha") && output.contains("beta"),
+        "{}",
+        output
+    );
+    assert!(!output.contains("omitted to stay within"), "{}", output);
+}
@@ -187,3 +187,641 @@ fn test_pipe_stats_present() {
         "Should show stats by default"
     );
 }
+
+#[test]
+fn test_pipe_depth_markers() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("src/models/user.rs", "struct User {}")
+        .file("Cargo.toml", "[package]")
+        .build();
+
+    let (output, _, success) = run_tree2md([p(&root), "--depth-markers".into()]);
+    assert!(success);
+
+    let lines: Vec<&str> = output.lines().collect();
+    assert_eq!(lines[0], "[0] .");
+    assert!(lines.contains(&"[1] ├── src/"), "{}", output);
+    assert!(lines.contains(&"[2] │   └── models/"), "{}", output);
+    assert!(
+        lines.contains(&"[3] │       └── user.rs  (1 lines)"),
+        "{}",
+        output
+    );
+    assert!(
+        lines.contains(&"[1] └── Cargo.toml  (1 lines)"),
+        "{}",
+        output
+    );
+}
+
+#[test]
+fn test_pipe_no_depth_markers_by_default() {
+    let (_tmp, root) = FixtureBuilder::new().file("a.txt", "a").build();
+
+    let (output, _, success) = run_tree2md([p(&root)]);
+    assert!(success);
+    assert!(!output.contains("[0]"));
+    assert!(!output.contains("[1]"));
+}
+
+#[test]
+fn test_single_file_target() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("main.go", "package main\n\nfunc main() {}\n")
+        .file("other.go", "package main\n")
+        .build();
+
+    let (output, _, success) = run_tree2md([p(root.join("main.go")), "-c".into()]);
+    assert!(success);
+
+    // The file is rendered as a file entry, not a directory
+    assert!(output.contains("└── main.go  (3 lines)"), "{}", output);
+    assert!(!output.contains("main.go/"));
+    assert!(!output.contains("other.go"));
+
+    // Its contents are emitted under -c
+    assert!(output.contains("## main.go"), "{}", output);
+    assert!(output.contains("```go\npackage main\n\nfunc main() {}\n```"));
+}
+
+#[test]
+fn test_sort_by_extension_groups_files() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("b.md", "b")
+        .file("main.go", "package main")
+        .file("a.md", "a")
+        .file("util.go", "package main")
+        .file("cmd/run.go", "package cmd")
+        .build();
+
+    let (output, _, success) = run_tree2md([p(&root), "--sort".into(), "ext".into()]);
+    assert!(success);
+
+    let names: Vec<&str> = output
+        .lines()
+        .filter_map(|l| l.split("── ").nth(1))
+        .map(|n| n.split("  (").next().unwrap())
+        .collect();
+    assert_eq!(
+        names,
+        vec!["cmd/", "run.go", "main.go", "util.go", "a.md", "b.md"],
+        "{}",
+        output
+    );
+
+    // `extension` is accepted as an alias
+    let (alias_output, _, success) = run_tree2md([p(&root), "--sort".into(), "extension".into()]);
+    assert!(success);
+    assert_eq!(alias_output, output);
+}
+
+#[test]
+fn test_content_prefix_and_suffix_bracket_output() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("main.rs", "fn main() {}\n")
+        .build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--content-prefix".into(),
+        "<!-- BEGIN TREE2MD -->".into(),
+        "--content-suffix".into(),
+        "<!-- END TREE2MD -->".into(),
+    ]);
+    assert!(success);
+
+    assert!(
+        output.starts_with("<!-- BEGIN TREE2MD -->\n.\n"),
+        "Prefix should come first: {}",
+        output
+    );
+    assert!(
+        output.ends_with("```\n<!-- END TREE2MD -->\n"),
+        "Suffix should come last: {}",
+        output
+    );
+    assert_eq!(output.matches("TREE2MD -->").count(), 2);
+}
+
+#[test]
+fn test_prefix_applies_to_every_line() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("src/main.rs", "fn main() {\n\n    run();\n}\n")
+        .file("README.md", "# Title\n")
+        .build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--stats".into(),
+        "min".into(),
+        "--prefix".into(),
+        "> ".into(),
+    ]);
+    assert!(success);
+
+    assert!(output.contains("> ```rust\n"), "{}", output);
+    for line in output.lines() {
+        assert!(
+            line.starts_with("> "),
+            "Unprefixed line {:?} in: {}",
+            line,
+            output
+        );
+    }
+}
+
+#[test]
+fn test_update_replaces_block_and_preserves_document() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("src/main.rs", "fn main() {}\n")
+        .build();
+    let doc_dir = tempfile::TempDir::new().unwrap();
+    let readme = doc_dir.path().join("README.md");
+    std::fs::write(
+        &readme,
+        "# Project\n\nIntro text.\n\n<!-- BEGIN TREE2MD -->\nstale tree\n<!-- END TREE2MD -->\n\n## License\nMIT\n",
+    )
+    .unwrap();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "--stats".into(),
+        "off".into(),
+        "--update".into(),
+        p(&readme),
+    ]);
+    assert!(success);
+    assert!(output.is_empty(), "Nothing should be printed: {}", output);
+
+    let updated = std::fs::read_to_string(&readme).unwrap();
+    assert!(updated.starts_with("# Project\n\nIntro text.\n\n<!-- BEGIN TREE2MD -->\n.\n"));
+    assert!(updated.contains("└── main.rs"), "{}", updated);
+    assert!(!updated.contains("stale tree"));
+    assert!(updated.ends_with("<!-- END TREE2MD -->\n\n## License\nMIT\n"));
+
+    // Running again is idempotent
+    let (_, _, success) = run_tree2md([
+        p(&root),
+        "--stats".into(),
+        "off".into(),
+        "--update".into(),
+        p(&readme),
+    ]);
+    assert!(success);
+    assert_eq!(std::fs::read_to_string(&readme).unwrap(), updated);
+}
+
+#[test]
+fn test_sort_ignorecase_orders_mixed_case_names() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("Zebra.txt", "z")
+        .file("apple.txt", "a")
+        .file("Mango.txt", "m")
+        .file("banana.txt", "b")
+        .build();
+
+    let names = |output: &str| -> Vec<String> {
+        output
+            .lines()
+            .filter_map(|l| l.split("── ").nth(1))
+            .map(|n| n.split("  (").next().unwrap().to_string())
+            .collect()
+    };
+
+    // Default is byte order: uppercase first
+    let (output, _, success) = run_tree2md([p(&root)]);
+    assert!(success);
+    assert_eq!(
+        names(&output),
+        vec!["Mango.txt", "Zebra.txt", "apple.txt", "banana.txt"]
+    );
+
+    let (output, _, success) = run_tree2md([p(&root), "--sort-ignorecase".into()]);
+    assert!(success);
+    assert_eq!(
+        names(&output),
+        vec!["apple.txt", "banana.txt", "Mango.txt", "Zebra.txt"]
+    );
+}
+
+#[test]
+fn test_sort_dirsize_puts_largest_directory_first() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("aaa/tiny.txt", "x\n")
+        .file("mmm/nested/huge.txt", &"x".repeat(4096))
+        .file("zzz/medium.txt", &"x".repeat(512))
+        .file("readme.txt", "hello\n")
+        .build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "--stats".into(),
+        "off".into(),
+        "--sort".into(),
+        "dirsize".into(),
+    ]);
+    assert!(success);
+
+    let pos = |name: &str| {
+        output
+            .find(name)
+            .unwrap_or_else(|| panic!("{} missing", name))
+    };
+    assert!(pos("mmm/") < pos("zzz/"), "{}", output);
+    assert!(pos("zzz/") < pos("aaa/"), "{}", output);
+    assert!(pos("aaa/") < pos("readme.txt"), "{}", output);
+}
+
+#[test]
+fn test_sort_dirsize_orders_collapsed_directories() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("aaa/tiny.txt", "x\n")
+        .file("mmm/nested/huge.txt", &"x".repeat(4096))
+        .file("zzz/medium.txt", &"x".repeat(512))
+        .build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "--stats".into(),
+        "off".into(),
+        "--sort".into(),
+        "dirsize".into(),
+        "--collapse-below".into(),
+        "1".into(),
+    ]);
+    assert!(success);
+
+    // Sizes count the files --collapse-below hides
+    let pos = |name: &str| {
+        output
+            .find(name)
+            .unwrap_or_else(|| panic!("{} missing", name))
+    };
+    assert!(!output.contains("huge.txt"), "{}", output);
+    assert!(pos("mmm/") < pos("zzz/"), "{}", output);
+    assert!(pos("zzz/") < pos("aaa/"), "{}", output);
+}
+
+#[test]
+fn test_sort_filecount_puts_busiest_directory_first() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("aaa/one.txt", "1\n")
+        .file("mmm/nested/a.txt", "a\n")
+        .file("mmm/nested/b.txt", "b\n")
+        .file("mmm/c.txt", "c\n")
+        .file("zzz/x.txt", &"x".repeat(4096))
+        .file("zzz/y.txt", "y\n")
+        .file("readme.txt", "hello\n")
+        .build();
+
+    let order = |extra: &[&str]| {
+        let mut args = vec![
+            p(&root),
+            "--stats".into(),
+            "off".into(),
+            "--sort".into(),
+            "filecount".into(),
+        ];
+        args.extend(extra.iter().map(|a| a.to_string()));
+        let (output, _, success) = run_tree2md(args);
+        assert!(success);
+        let pos = |name: &str| {
+            output
+                .find(name)
+                .unwrap_or_else(|| panic!("{} missing", name))
+        };
+        let mut dirs = vec!["aaa/", "mmm/", "zzz/"];
+        dirs.sort_by_key(|d| pos(d));
+        assert!(pos(dirs[2]) < pos("readme.txt"), "{}", output);
+        dirs
+    };
+
+    assert_eq!(order(&[]), vec!["mmm/", "zzz/", "aaa/"]);
+    assert_eq!(order(&["--sort-reverse"]), vec!["aaa/", "zzz/", "mmm/"]);
+}
+
+#[test]
+fn test_output_encoding_utf16le_round_trips() {
+    let (_tmp, root) = FixtureBuilder::new().file("café.txt", "hello\n").build();
+
+    let output = assert_cmd::Command::cargo_bin("tree2md")
+        .expect("tree2md binary not found")
+        .args([
+            p(&root),
+            "--stats".into(),
+            "off".into(),
+            "--output-encoding".into(),
+            "utf-16le".into(),
+        ])
+        .output()
+        .expect("failed to run tree2md");
+    assert!(output.status.success());
+
+    let bytes = output.stdout;
+    assert_eq!(&bytes[..2], &[0xFF, 0xFE], "missing UTF-16LE BOM");
+    assert_eq!(bytes.len() % 2, 0);
+    let units: Vec<u16> = bytes[2..]
+        .chunks_exact(2)
+        .map(|pair| u16::from_le_bytes([pair[0], pair[1]]))
+        .collect();
+    let decoded = String::from_utf16(&units).expect("invalid UTF-16LE output");
+
+    let (expected, _, _) = run_tree2md([p(&root), "--stats".into(), "off".into()]);
+    assert_eq!(decoded, expected);
+    assert!(decoded.contains("café.txt"));
+}
+
+#[test]
+fn test_root_full_path_label() {
+    let (_tmp, root) = FixtureBuilder::new().file("a.txt", "a\n").build();
+    let full = root.canonicalize().unwrap();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "--stats".into(),
+        "off".into(),
+        "--root-full-path".into(),
+    ]);
+    assert!(success);
+    assert_eq!(
+        output.lines().next(),
+        Some(full.to_string_lossy().as_ref()),
+        "{}",
+        output
+    );
+    assert!(output.contains("└── a.txt"), "{}", output);
+
+    let (plain, _, _) = run_tree2md([p(&root), "--stats".into(), "off".into()]);
+    assert_eq!(plain.lines().next(), Some("."));
+}
+
+#[test]
+fn test_show_lang_labels_files() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("main.go", "package main\n")
+        .file("tools/gen.py", "print('hi')\n")
+        .file("data.unknownext", "?\n")
+        .build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "--stats".into(),
+        "off".into(),
+        "--show-lang".into(),
+    ]);
+    assert!(success);
+
+    assert!(output.contains("main.go  (1 lines, go)"), "{}", output);
+    assert!(output.contains("gen.py  (1 lines, python)"), "{}", output);
+    assert!(
+        output.contains("data.unknownext  (1 lines)\n"),
+        "{}",
+        output
+    );
+}
+
+#[test]
+fn test_combined_annotations_share_one_group() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("main.go", "package main\n")
+        .build();
+    // 2024-01-02T12:00:00Z
+    let modified = std::time::UNIX_EPOCH + std::time::Duration::from_secs(1_704_196_800);
+    std::fs::File::options()
+        .write(true)
+        .open(root.join("main.go"))
+        .unwrap()
+        .set_modified(modified)
+        .unwrap();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "--stats".into(),
+        "off".into(),
+        // Flag order does not change the annotation order
+        "--show-lang".into(),
+        "--show-mtime".into(),
+        "--show-size".into(),
+    ]);
+    assert!(success);
+
+    assert!(
+        output.contains("└── main.go  (1 lines, 13 B, 2024-01-02, go)\n"),
+        "{}",
+        output
+    );
+}
+
+#[test]
+fn test_prefix_on_tree_lines() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("src/main.rs", "fn main() {}\n")
+        .file("README.md", "# Title\n")
+        .build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "--stats".into(),
+        "off".into(),
+        "--prefix".into(),
+        "# ".into(),
+    ]);
+    assert!(success);
+    assert_eq!(
+        output,
+        "# .\n# ├── src/\n# │   └── main.rs  (1 lines)\n# └── README.md  (1 lines)\n"
+    );
+}
+
+#[test]
+fn test_prefix_applies_to_json_format() {
+    let (_tmp, root) = FixtureBuilder::new().file("a.txt", "a\n").build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "--format".into(),
+        "json".into(),
+        "--prefix".into(),
+        "// ".into(),
+    ]);
+    assert!(success);
+    assert!(
+        output.lines().all(|line| line.starts_with("// ")),
+        "{}",
+        output
+    );
+
+    let unprefixed: String = output
+        .lines()
+        .map(|line| format!("{}\n", &line[3..]))
+        .collect();
+    serde_json::from_str::<serde_json::Value>(&unprefixed).expect(&unprefixed);
+}
+
+#[test]
+fn test_max_name_length_truncates_tree_names_only() {
+    let long_name = format!("{}.generated.ts", "a".repeat(80));
+    let (_tmp, root) = FixtureBuilder::new()
+        .file(&long_name, "export {};\n")
+        .file("short.ts", "export {};\n")
+        .build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--stats".into(),
+        "off".into(),
+        "--max-name-length".into(),
+        "12".into(),
+    ]);
+    assert!(success);
+
+    assert!(
+        output.contains(&format!("── {}…  (1 lines)", "a".repeat(11))),
+        "{}",
+        output
+    );
+    assert!(output.contains("── short.ts  (1 lines)"), "{}", output);
+    // Content headings keep the full name
+    assert!(output.contains(&format!("## {}", long_name)), "{}", output);
+}
+
+#[test]
+fn test_pipe_wikilinks() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("notes/Daily Log.md", "# Today\n")
+        .file("README.md", "# Readme\n")
+        .file("main.rs", "fn main() {}\n")
+        .build();
+
+    let (output, stderr, success) = run_tree2md([
+        p(&root),
+        "--wikilinks".into(),
+        "--stats".into(),
+        "off".into(),
+    ]);
+    assert!(success, "{}", stderr);
+
+    assert!(output.contains("[[Daily Log]]"), "{}", output);
+    assert!(output.contains("[[README]]"), "{}", output);
+    assert!(!output.contains("README.md"), "{}", output);
+    assert!(output.contains("main.rs"), "{}", output);
+    assert!(!output.contains("[[main]]"), "{}", output);
+}
+
+#[test]
+fn test_sort_natural_orders_numbered_files() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("img10.png", "")
+        .file("img2.png", "")
+        .file("img1.png", "")
+        .file("img9.png", "")
+        .build();
+
+    let names = |output: &str| -> Vec<String> {
+        output
+            .lines()
+            .filter_map(|l| l.split("── ").nth(1))
+            .map(|n| n.split("  (").next().unwrap().to_string())
+            .collect()
+    };
+
+    // Byte order puts img10 before img2
+    let (output, _, success) = run_tree2md([p(&root)]);
+    assert!(success);
+    assert_eq!(
+        names(&output),
+        vec!["img1.png", "img10.png", "img2.png", "img9.png"]
+    );
+
+    let (output, _, success) = run_tree2md([p(&root), "--sort".into(), "natural".into()]);
+    assert!(success);
+    assert_eq!(
+        names(&output),
+        vec!["img1.png", "img2.png", "img9.png", "img10.png"]
+    );
+}
+
+#[test]
+fn test_list_languages() {
+    let (output, stderr, success) = run_tree2md(["--list-languages".to_string()]);
+    assert!(success, "{}", stderr);
+
+    let line = |ext: &str| {
+        output
+            .lines()
+            .find(|l| l.split_whitespace().next() == Some(ext))
+            .map(|l| l.split_whitespace().nth(1).unwrap_or("").to_string())
+    };
+    assert_eq!(line(".rs").as_deref(), Some("rust"), "{}", output);
+    assert_eq!(line(".go").as_deref(), Some("go"), "{}", output);
+    assert_eq!(line(".yml").as_deref(), Some("yaml"), "{}", output);
+    assert_eq!(line(".py").as_deref(), Some("python"), "{}", output);
+    // Nothing else is printed, not even a tree
+    assert!(!output.contains("──"), "{}", output);
+}
+
+#[test]
+fn test_dir_language_labels_plurality_language() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("services/api/main.go", "package main\n")
+        .file("services/api/handler.go", "package main\n")
+        .file("services/worker/worker.go", "package worker\n")
+        .file("services/scripts/deploy.py", "print('deploy')\n")
+        .file("web/app.ts", "export {}\n")
+        .file("web/util.js", "export {}\n")
+        .file("docs/notes.txt", "notes\n")
+        .build();
+
+    let (output, stderr, success) = run_tree2md([
+        p(&root),
+        "--dir-language".into(),
+        "--stats".into(),
+        "off".into(),
+    ]);
+    assert!(success, "{}", stderr);
+
+    // Descendants count, not just direct children
+    assert!(output.contains("services/ [mostly go]"), "{}", output);
+    assert!(output.contains("scripts/ [mostly python]"), "{}", output);
+    // One javascript, one typescript: the tie goes to the first name
+    assert!(output.contains("web/ [mostly javascript]"), "{}", output);
+    // No known language, no label
+    assert!(output.contains("docs/\n"), "{}", output);
+
+    let (output, _, success) = run_tree2md([p(&root), "--stats".into(), "off".into()]);
+    assert!(success);
+    assert!(!output.contains("[mostly"), "{}", output);
+}
+
+#[test]
+fn test_dir_summary_line_beneath_directory() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("services/api/main.go", "package main\n")
+        .file("services/api/handler.go", "package main\n")
+        .file("services/deploy.py", "print('deploy')\n")
+        .file("services/notes.txt", "some notes\n")
+        .build();
+
+    let (output, stderr, success) = run_tree2md([
+        p(&root),
+        "--dir-summary".into(),
+        "--stats".into(),
+        "off".into(),
+    ]);
+    assert!(success, "{}", stderr);
+
+    // 13 + 13 + 16 + 11 bytes, two of the four files in Go
+    assert!(
+        output.contains("└── services/\n    (4 files, 53 B, mostly go)\n"),
+        "{}",
+        output
+    );
+    assert!(
+        output.contains("├── api/\n    │   (2 files, 26 B, mostly go)\n"),
+        "{}",
+        output
+    );
+}
@@ -321,3 +321,173 @@ fn test_package_lock_files_included() {
     assert!(output.contains("Cargo.lock"));
     assert!(output.contains("yarn.lock"));
 }
+
+#[test]
+fn test_filesystem_root_requires_force() {
+    // Scanning "/" without --force must fail before walking anything
+    let (output, stderr, success) = run_tree2md(["/"]);
+    assert!(!success, "Scanning / without --force should fail");
+    assert!(output.is_empty(), "No tree should be printed: {}", output);
+    assert!(
+        stderr.contains("--force"),
+        "Error should mention --force: {}",
+        stderr
+    );
+}
+
+#[test]
+fn test_timeout_prints_partial_tree_with_warning() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("src/main.rs", "fn main() {}\n")
+        .file("README.md", "# Project\n")
+        .build();
+
+    // A zero timeout expires before the first entry is walked
+    let (output, stderr, success) = run_tree2md([p(&root), "--timeout".into(), "0s".into()]);
+    assert!(success);
+    assert!(output.starts_with(".\n"), "{}", output);
+    assert!(!output.contains("main.rs"), "{}", output);
+    assert!(stderr.contains("timed out"), "{}", stderr);
+    assert!(
+        output.contains("_[Partial tree: walk timed out after 0ns]_"),
+        "{}",
+        output
+    );
+
+    let (output, stderr, success) = run_tree2md([p(&root), "--timeout".into(), "1m".into()]);
+    assert!(success);
+    assert!(output.contains("main.rs"));
+    assert!(!stderr.contains("timed out"));
+    assert!(!output.contains("Partial tree"), "{}", output);
+
+    // Durations too large for a deadline are rejected or never expire,
+    // without panicking
+    let (_, stderr, success) =
+        run_tree2md([p(&root), "--timeout".into(), "99999999999999999999".into()]);
+    assert!(!success);
+    assert!(stderr.contains("out of range"), "{}", stderr);
+    let (output, stderr, success) =
+        run_tree2md([p(&root), "--timeout".into(), "10000000000000000000".into()]);
+    assert!(success, "{}", stderr);
+    assert!(output.contains("main.rs"), "{}", output);
+
+    let (_, stderr, success) = run_tree2md([p(&root), "--timeout".into(), "soon".into()]);
+    assert!(!success);
+    assert!(stderr.contains("invalid duration"), "{}", stderr);
+}
+
+#[test]
+fn test_max_total_dirs_stops_at_directory_limit() {
+    let mut builder = FixtureBuilder::new();
+    for i in 0..6 {
+        builder = builder.file(&format!("pkg{}/mod.go", i), "package pkg\n");
+    }
+    let (_tmp, root) = builder.build();
+    let dir_lines = |output: &str| output.lines().filter(|l| l.ends_with('/')).count();
+
+    let (output, stderr, success) = run_tree2md([
+        p(&root),
+        "--max-total-dirs".into(),
+        "2".into(),
+        "--stats".into(),
+        "off".into(),
+    ]);
+    assert!(success, "{}", stderr);
+    assert_eq!(dir_lines(&output), 2, "{}", output);
+    assert!(stderr.contains("--max-total-dirs limit of 2"), "{}", stderr);
+    assert!(
+        output.ends_with("\n_[Partial tree: walk stopped at --max-total-dirs 2]_\n"),
+        "{}",
+        output
+    );
+
+    // Directories the filters leave out still count toward the limit
+    let (output, stderr, success) = run_tree2md([
+        p(&root),
+        "-X".into(),
+        "pkg0".into(),
+        "-X".into(),
+        "pkg1".into(),
+        "--max-total-dirs".into(),
+        "2".into(),
+        "--stats".into(),
+        "off".into(),
+    ]);
+    assert!(success, "{}", stderr);
+    assert_eq!(dir_lines(&output), 0, "{}", output);
+    assert!(stderr.contains("--max-total-dirs limit of 2"), "{}", stderr);
+
+    // Under the limit nothing changes
+    let (output, stderr, success) = run_tree2md([
+        p(&root),
+        "--max-total-dirs".into(),
+        "6".into(),
+        "--stats".into(),
+        "off".into(),
+    ]);
+    assert!(success);
+    assert_eq!(dir_lines(&output), 6, "{}", output);
+    assert!(!stderr.contains("--max-total-dirs"), "{}", stderr);
+}
+
+#[test]
+fn test_max_output_bytes_caps_output_with_footer() {
+    let body: String = (1..=200).map(|i| format!("line {} ✓\n", i)).collect();
+    let (_tmp, root) = FixtureBuilder::new().file("long.txt", &body).build();
+
+    let (output, stderr, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--max-output-bytes".into(),
+        "300".into(),
+        "--stats".into(),
+        "off".into(),
+    ]);
+    assert!(success, "{}", stderr);
+    assert!(output.len() <= 300, "{} bytes: {}", output.len(), output);
+    assert!(
+        output.ends_with("\n_[Output truncated: --max-output-bytes 300 reached]_\n"),
+        "{}",
+        output
+    );
+    // Cut between lines, never inside one
+    let before_footer =
+        output.trim_end_matches("\n_[Output truncated: --max-output-bytes 300 reached]_\n");
+    assert!(before_footer.ends_with(" ✓\n"), "{}", output);
+    assert!(output.contains("line 1 ✓\n"), "{}", output);
+    assert!(!output.contains("line 200"), "{}", output);
+
+    // Small outputs are untouched
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "--max-output-bytes".into(),
+        "300".into(),
+        "--stats".into(),
+        "off".into(),
+    ]);
+    assert!(success);
+    assert!(!output.contains("Output truncated"), "{}", output);
+}
+
+#[test]
+fn test_max_output_bytes_notes_truncation_on_stderr_for_structured_formats() {
+    let body: String = (1..=200).map(|i| format!("line {}\n", i)).collect();
+    let (_tmp, root) = FixtureBuilder::new().file("long.txt", &body).build();
+
+    let (output, stderr, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--format".into(),
+        "json".into(),
+        "--max-output-bytes".into(),
+        "300".into(),
+    ]);
+    assert!(success, "{}", stderr);
+    assert!(output.len() <= 300, "{} bytes: {}", output.len(), output);
+    assert!(!output.contains("Output truncated"), "{}", output);
+    assert!(
+        stderr.contains("output truncated: --max-output-bytes 300 reached"),
+        "{}",
+        stderr
+    );
+}
@@ -277,3 +277,192 @@ fn test_stats_directory_count() {
         "Should count files correctly"
     );
 }
+
+#[test]
+fn test_summary_json_sidecar() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("main.go", "package main\n")
+        .file("pkg/util/util.go", "package util\n")
+        .file("README.md", "# Hi\n")
+        .file("Makefile", "all:\n")
+        .build();
+    let out_dir = tempfile::TempDir::new().unwrap();
+    let summary_path = out_dir.path().join("summary.json");
+
+    let (output, _, success) = run_tree2md([p(&root), "--summary-json".into(), p(&summary_path)]);
+    assert!(success);
+
+    // The main output still goes to stdout
+    assert!(output.contains("main.go"));
+
+    let summary: serde_json::Value =
+        serde_json::from_str(&std::fs::read_to_string(&summary_path).unwrap()).unwrap();
+    assert_eq!(summary["dirs"].as_u64(), Some(2));
+    assert_eq!(summary["files"].as_u64(), Some(4));
+    assert_eq!(summary["totalBytes"].as_u64(), Some(13 + 13 + 5 + 5));
+    assert_eq!(summary["maxDepth"].as_u64(), Some(3));
+    assert_eq!(summary["extensions"]["go"].as_u64(), Some(2));
+    assert_eq!(summary["extensions"]["md"].as_u64(), Some(1));
+    assert_eq!(summary["extensions"]["(no ext)"].as_u64(), Some(1));
+}
+
+#[test]
+fn test_stderr_summary_line() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("main.go", "package main\n")
+        .file("pkg/util/util.go", "package util\n")
+        .file("README.md", "# Hi\n")
+        .build();
+
+    let (output, stderr, success) = run_tree2md([
+        p(&root),
+        "--stats".into(),
+        "off".into(),
+        "--stderr-summary".into(),
+    ]);
+    assert!(success);
+
+    assert!(
+        stderr.contains("tree2md: 3 files, 2 dirs, 31 B total\n"),
+        "{}",
+        stderr
+    );
+    // stdout carries only the tree
+    assert!(!output.contains("tree2md:"), "{}", output);
+}
+
+#[test]
+fn test_lang_stats_aggregates_per_language() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("src/main.rs", &"x".repeat(600))
+        .file("src/lib.rs", &"x".repeat(200))
+        .file("tools/gen.py", &"x".repeat(100))
+        .file("data.unknownext", &"x".repeat(100))
+        .build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "--stats".into(),
+        "off".into(),
+        "--lang-stats".into(),
+    ]);
+    assert!(success);
+
+    assert!(output.contains("**Languages**"), "{}", output);
+    let rust = output.find("| rust | 2 | 800 B | 80.0% |").expect(&output);
+    let python = output
+        .find("| python | 1 | 100 B | 10.0% |")
+        .expect(&output);
+    let other = output.find("| other | 1 | 100 B | 10.0% |").expect(&output);
+    assert!(rust < python && python < other, "{}", output);
+}
+
+#[test]
+fn test_summary_only_prints_just_stats() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("src/main.rs", "fn main() {}\n")
+        .file("README.md", "# Title\n")
+        .build();
+
+    let (full, _, _) = run_tree2md([p(&root), "--stats".into(), "min".into()]);
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--stats".into(),
+        "min".into(),
+        "--summary-only".into(),
+    ]);
+    assert!(success);
+
+    // Exactly the stats block that follows the tree in normal output
+    assert!(full.ends_with(&output), "{}\n---\n{}", full, output);
+    assert!(!output.contains("main.rs"), "{}", output);
+    assert!(!output.contains("```"), "{}", output);
+    assert!(!output.starts_with('\n'), "{:?}", output);
+}
+
+#[test]
+fn test_summary_only_json_prints_stats_object() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("src/main.rs", "fn main() {}\n")
+        .file("README.md", "# Title\n")
+        .build();
+
+    let (output, _, success) = run_tree2md([
+        p(&root),
+        "--format".into(),
+        "json".into(),
+        "--summary-only".into(),
+    ]);
+    assert!(success);
+
+    let json: serde_json::Value = serde_json::from_str(&output).expect(&output);
+    assert_eq!(json["files"], 2);
+    assert_eq!(json["directories"], 2);
+    assert!(json.get("children").is_none(), "{}", output);
+}
+
+#[test]
+fn test_duplicates_report_lists_groups_by_waste() {
+    let big = "x".repeat(2048);
+    let (_tmp, root) = FixtureBuilder::new()
+        .file("assets/logo.svg", &big)
+        .file("backup/logo.svg", &big)
+        .file("a.txt", "same words\n")
+        .file("docs/b.txt", "same words\n")
+        .file("c.txt", "same size!!\n")
+        .file("unique.txt", "nothing like it\n")
+        .build();
+
+    let (output, stderr, success) = run_tree2md([
+        p(&root),
+        "--duplicates-report".into(),
+        "--stats".into(),
+        "off".into(),
+    ]);
+    assert!(success, "{}", stderr);
+
+    assert!(
+        output.contains(
+            "**Duplicates**\n\n\
+             - Group 1 (2 copies, 2.0 KB wasted): assets/logo.svg, backup/logo.svg\n\
+             - Group 2 (2 copies, 11 B wasted): a.txt, docs/b.txt\n"
+        ),
+        "{}",
+        output
+    );
+    // Same size, different contents: not a duplicate
+    assert!(!output.contains("c.txt,"), "{}", output);
+    assert!(!output.contains("Group 3"), "{}", output);
+}
+
+#[test]
+fn test_validate_utf8_lists_malformed_files() {
+    let (_tmp, root) = FixtureBuilder::new().file("good.txt", "héllo\n").build();
+    // "ok," then a lone continuation byte
+    std::fs::write(root.join("data.csv"), b"ok,\x80bad\n").unwrap();
+
+    let (output, stderr, success) = run_tree2md([
+        p(&root),
+        "-c".into(),
+        "--validate-utf8".into(),
+        "--stats".into(),
+        "off".into(),
+    ]);
+    assert!(success, "{}", stderr);
+
+    assert!(
+        output.contains("**Invalid UTF-8**\n\n- data.csv (invalid UTF-8 at byte 3)\n"),
+        "{}",
+        output
+    );
+    assert!(!output.contains("- good.txt"), "{}", output);
+    // Still emitted under -c, with a replacement character
+    assert!(output.contains("ok,\u{fffd}bad\n"), "{}", output);
+
+    // Without the flag the file is treated as binary
+    let (output, _, success) = run_tree2md([p(&root), "-c".into(), "--stats".into(), "off".into()]);
+    assert!(success);
+    assert!(!output.contains("Invalid UTF-8"), "{}", output);
+    assert!(!output.contains("ok,"), "{}", output);
+}
@@ -0,0 +1,105 @@
+mod fixtures;
+
+use fixtures::{p, run_tree2md, FixtureBuilder};
+
+fn run_with_contents(root: &std::path::Path) -> String {
+    let (output, stderr, success) =
+        run_tree2md([p(root), "-c".into(), "--stats".into(), "off".into()]);
+    assert!(success, "{}", stderr);
+    output
+}
+
+/// Plain lines filter the tree like .gitignore, negations included.
+#[test]
+fn test_plain_patterns_filter_tree() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file(".tree2mdignore", "reports/\n*.draft\n!keep.draft\n")
+        .file("main.go", "package main\n")
+        .file("reports/q1.txt", "q1\n")
+        .file("old.draft", "old\n")
+        .file("keep.draft", "keep\n")
+        .build();
+
+    let output = run_with_contents(&root);
+
+    assert!(output.contains("main.go"), "{}", output);
+    assert!(output.contains("keep.draft"), "{}", output);
+    assert!(!output.contains("q1.txt"), "{}", output);
+    assert!(!output.contains("old.draft"), "{}", output);
+}
+
+/// `!content:PATTERN` keeps files in the tree but never emits their contents.
+#[test]
+fn test_skip_content_directive() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file(".tree2mdignore", "!content:pkg/*.go\n")
+        .file("main.go", "package main\n")
+        .file("pkg/util.go", "package util\n")
+        .build();
+
+    let output = run_with_contents(&root);
+
+    assert!(output.contains("util.go"), "{}", output);
+    assert!(!output.contains("package util"), "{}", output);
+    assert!(output.contains("package main"), "{}", output);
+}
+
+/// `content-only:PATTERN` limits contents to matching files; the tree is whole.
+#[test]
+fn test_content_only_directive() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file(".tree2mdignore", "content-only:*.md\n")
+        .file("main.go", "package main\n")
+        .file("docs/guide.md", "# Guide\n")
+        .build();
+
+    let output = run_with_contents(&root);
+
+    assert!(output.contains("main.go"), "{}", output);
+    assert!(output.contains("guide.md"), "{}", output);
+    assert!(output.contains("# Guide"), "{}", output);
+    assert!(!output.contains("package main"), "{}", output);
+}
+
+/// Content directives combine: `!content:` carves files out of `content-only:`.
+#[test]
+fn test_content_directives_combine() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file(
+            ".tree2mdignore",
+            "# contents for Go sources, except generated ones\n\
+             content-only:*.go\n\
+             !content:*_gen.go\n",
+        )
+        .file("main.go", "package main\n")
+        .file("model_gen.go", "package generated\n")
+        .file("README.md", "# Readme\n")
+        .build();
+
+    let output = run_with_contents(&root);
+
+    for name in ["main.go", "model_gen.go", "README.md"] {
+        assert!(output.contains(name), "{} missing: {}", name, output);
+    }
+    assert!(output.contains("package main"), "{}", output);
+    assert!(!output.contains("package generated"), "{}", output);
+    assert!(!output.contains("# Readme"), "{}", output);
+}
+
+/// A content directive with an invalid glob fails the run instead of
+/// silently dropping every directive.
+#[test]
+fn test_invalid_content_directive_is_an_error() {
+    let (_tmp, root) = FixtureBuilder::new()
+        .file(".tree2mdignore", "content-only:*.go\n!content:src/[\n")
+        .file("main.go", "package main\n")
+        .build();
+
+    let (output, stderr, success) = run_tree2md([p(&root), "-c".into()]);
+    assert!(!success, "{}", output);
+    assert!(
+        stderr.contains("Invalid .tree2mdignore pattern 'src/['"),
+        "{}",
+        stderr
+    );
+}